@@ -0,0 +1,755 @@
+// Package config reads and validates the server configuration from the
+// environment.
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/korjavin/claude_connector/tools"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Transports selectable through MCP_TRANSPORT.
+const (
+	TransportHTTP  = "http"
+	TransportSSE   = "sse"
+	TransportStdio = "stdio"
+)
+
+// Tail strategies selectable through CSV_TAIL_STRATEGY.
+const (
+	TailStrategyScan = "scan"
+	TailStrategySeek = "seek"
+)
+
+// Authentication modes selectable through AUTH_MODE.
+const (
+	AuthModeJWT        = "jwt"
+	AuthModeIntrospect = "introspect"
+	AuthModeHMAC       = "hmac"
+)
+
+// minSharedSecretLength is the shortest JWT_SHARED_SECRET accepted; shorter
+// HMAC keys are open to brute force.
+const minSharedSecretLength = 32
+
+// Config is the complete server configuration. Every field has already been
+// validated and defaulted by Load.
+type Config struct {
+	// Port is the TCP port the HTTP server listens on.
+	Port string
+	// LogFormat is "text" or "json".
+	LogFormat string
+	// Transport is TransportHTTP, TransportSSE or TransportStdio.
+	Transport string
+
+	// CSVFilePath, when set, is the CSV file of the default dataset.
+	CSVFilePath string
+	// Datasets maps the dataset names given in CSV_FILES to CSV file paths.
+	Datasets map[string]string
+	// DataDir, when set, is a directory whose CSV files are registered as
+	// further datasets.
+	DataDir string
+
+	// Delimiter, Encoding and DateLayout control how the CSV files are parsed.
+	Delimiter  rune
+	Encoding   encoding.Encoding
+	DateLayout string
+	// Comment, when not zero, starts lines that are skipped as comments.
+	Comment rune
+	// OpenRetries is how often a transient failure to open a file is retried.
+	OpenRetries int
+	// MaxFileBytes is the largest file that is sorted or cached in memory.
+	// Zero disables the limit.
+	MaxFileBytes int64
+	// FixedWidth, when not empty, is the column layout of fixed-width files,
+	// which are then read instead of CSV.
+	FixedWidth []tools.FixedWidthColumn
+	// LazyQuotes tolerates bare quotes in fields.
+	LazyQuotes bool
+	// TrimSpace strips white space around every cell.
+	TrimSpace bool
+	// Redact maps the columns to mask to their tools redaction mode.
+	Redact map[string]string
+	// Aliases maps header names to the names the tools report them under.
+	Aliases map[string]string
+	// QueryableColumns, when not empty, is the set of the only columns tools
+	// may filter, sort and aggregate on.
+	QueryableColumns map[string]bool
+	// Cache keeps parsed CSV files in memory.
+	Cache bool
+	// TailStrategy is TailStrategyScan or TailStrategySeek.
+	TailStrategy string
+	// Strict makes get_last_n_records fail on malformed rows.
+	Strict bool
+	// Writable enables the append_record tool.
+	Writable bool
+	// MaxRecords caps the number of records a single tool call returns.
+	MaxRecords int
+	// DefaultRecordCount is the count used when a tool call omits it.
+	DefaultRecordCount int
+	// SkipStartupCheck skips scanning the datasets at startup, for
+	// deployments where the files only appear later.
+	SkipStartupCheck bool
+	// ServerName is the name the MCP server reports to clients.
+	ServerName string
+	// ToolTimeout bounds how long a single tool call may run.
+	ToolTimeout time.Duration
+	// IdempotencyCacheSize and IdempotencyTTL bound how many append_record
+	// idempotency keys are remembered and for how long.
+	IdempotencyCacheSize int
+	IdempotencyTTL       time.Duration
+	// MaxDataAge, when positive, is the age beyond which a dataset file is
+	// reported as stale.
+	MaxDataAge time.Duration
+	// AuditEnabled writes an audit entry for every tool call, to AuditLogFile
+	// when it is set and to standard output otherwise.
+	AuditEnabled bool
+	AuditLogFile string
+	// PprofEnabled serves the net/http/pprof handlers on PprofAddr, a
+	// listener separate from the public one.
+	PprofEnabled bool
+	PprofAddr    string
+
+	// AuthMode is AuthModeJWT, AuthModeIntrospect or AuthModeHMAC. The JWKS
+	// settings apply to the first, the Introspection settings to the second
+	// and JWTSharedSecret to the last.
+	AuthMode                  string
+	JWKSURL                   string
+	JWKSCacheTTL              time.Duration
+	JWKSRetries               int
+	JWKSTimeout               time.Duration
+	IntrospectionURL          string
+	IntrospectionClientID     string
+	IntrospectionClientSecret string
+	IntrospectionCacheTTL     time.Duration
+	Audience                  string
+	Issuer                    string
+	Leeway                    time.Duration
+	// JWTAlgorithms lists the accepted signing algorithms. Empty leaves the
+	// choice to the authentication middleware, which also checks that the
+	// names suit AuthMode.
+	JWTAlgorithms   []string
+	JWTSharedSecret []byte
+	RequiredScope   string
+	WriteScope      string
+	AdminScope      string
+
+	// TLSCertFile and TLSKeyFile are either both set or both empty.
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSMinVersion uint16
+
+	// RateLimitRPS of zero disables rate limiting.
+	RateLimitRPS       float64
+	RateLimitBurst     int
+	MaxBodyBytes       int64
+	CORSAllowedOrigins []string
+	ShutdownTimeout    time.Duration
+	// OTLPEndpoint, when set, is where trace spans are exported to.
+	OTLPEndpoint string
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies whose
+	// X-Forwarded-For headers decide the client IP. Empty trusts none.
+	TrustedProxies []string
+}
+
+// Load reads the configuration from the environment and from the file named
+// by ENV_FILE, DefaultEnvFile by default. Variables set in the environment take
+// precedence over the file, and an empty ENV_FILE disables it. The returned
+// error names the variable that is missing or invalid.
+func Load() (*Config, error) {
+	path, explicit := os.LookupEnv("ENV_FILE")
+	if !explicit {
+		path = DefaultEnvFile
+	}
+	var fileValues map[string]string
+	if path != "" {
+		var err error
+		fileValues, err = readEnvFile(path)
+		if err != nil && (explicit || !errors.Is(err, fs.ErrNotExist)) {
+			return nil, fmt.Errorf("invalid ENV_FILE: %w", err)
+		}
+	}
+
+	return load(func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := fileValues[key]
+		return value, ok
+	})
+}
+
+// load reads the configuration through lookup, which behaves like
+// os.LookupEnv.
+func load(lookup func(string) (string, bool)) (*Config, error) {
+	getenv := func(key string) string {
+		value, _ := lookup(key)
+		return value
+	}
+
+	var err error
+	cfg := &Config{
+		Port:               getenv("MCP_SERVER_PORT"),
+		LogFormat:          getenv("LOG_FORMAT"),
+		Transport:          getenv("MCP_TRANSPORT"),
+		DateLayout:         getenv("DATE_LAYOUT"),
+		TailStrategy:       getenv("CSV_TAIL_STRATEGY"),
+		AuthMode:           getenv("AUTH_MODE"),
+		JWKSURL:            getenv("JWKS_URL"),
+		IntrospectionURL:   getenv("INTROSPECTION_URL"),
+		Audience:           getenv("EXPECTED_AUDIENCE"),
+		Issuer:             getenv("EXPECTED_ISSUER"),
+		TLSCertFile:        getenv("TLS_CERT_FILE"),
+		TLSKeyFile:         getenv("TLS_KEY_FILE"),
+		CORSAllowedOrigins: parseList(getenv("CORS_ALLOWED_ORIGINS")),
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = "text"
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("LOG_FORMAT must be text or json, got %q", cfg.LogFormat)
+	}
+
+	switch cfg.Transport {
+	case "":
+		cfg.Transport = TransportHTTP
+	case TransportHTTP, TransportSSE, TransportStdio:
+	default:
+		return nil, fmt.Errorf("MCP_TRANSPORT must be %q, %q or %q, got %q", TransportHTTP, TransportSSE, TransportStdio, cfg.Transport)
+	}
+
+	cfg.Datasets, err = parseDatasets(getenv("CSV_FILES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_FILES: %w", err)
+	}
+	cfg.CSVFilePath = getenv("CSV_FILE_PATH")
+	cfg.DataDir = getenv("CSV_DIR")
+	if cfg.DataDir != "" {
+		info, err := os.Stat(cfg.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV_DIR: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid CSV_DIR: %s is not a directory", cfg.DataDir)
+		}
+	}
+	if cfg.CSVFilePath == "" && len(cfg.Datasets) == 0 && cfg.DataDir == "" {
+		return nil, errors.New("none of CSV_FILE_PATH, CSV_FILES and CSV_DIR is set")
+	}
+
+	cfg.Delimiter, err = parseDelimiter(getenv("CSV_DELIMITER"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_DELIMITER: %w", err)
+	}
+	cfg.Comment, err = parseComment(getenv("CSV_COMMENT"), cfg.Delimiter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_COMMENT: %w", err)
+	}
+	cfg.OpenRetries = tools.DefaultOpenRetries
+	if v := getenv("CSV_OPEN_RETRIES"); v != "" {
+		cfg.OpenRetries, err = strconv.Atoi(v)
+		if err != nil || cfg.OpenRetries < 0 {
+			return nil, fmt.Errorf("CSV_OPEN_RETRIES must be a non-negative integer, got %q", v)
+		}
+	}
+	cfg.MaxFileBytes = 1 << 30
+	if v := getenv("MAX_FILE_BYTES"); v != "" {
+		cfg.MaxFileBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cfg.MaxFileBytes < 0 {
+			return nil, fmt.Errorf("MAX_FILE_BYTES must be a non-negative integer, got %q", v)
+		}
+	}
+	cfg.Encoding, err = parseEncoding(getenv("CSV_ENCODING"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_ENCODING: %w", err)
+	}
+	cfg.Cache, err = parseBool(getenv("CSV_CACHE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_CACHE: %w", err)
+	}
+
+	switch cfg.TailStrategy {
+	case "":
+		cfg.TailStrategy = TailStrategyScan
+	case TailStrategyScan, TailStrategySeek:
+	default:
+		return nil, fmt.Errorf("CSV_TAIL_STRATEGY must be %q or %q, got %q", TailStrategyScan, TailStrategySeek, cfg.TailStrategy)
+	}
+
+	cfg.LazyQuotes, err = parseBool(getenv("CSV_LAZY_QUOTES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_LAZY_QUOTES: %w", err)
+	}
+	cfg.TrimSpace, err = parseBool(getenv("CSV_TRIM_SPACE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_TRIM_SPACE: %w", err)
+	}
+	cfg.Redact, err = parseRedactions(getenv("REDACT_COLUMNS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDACT_COLUMNS: %w", err)
+	}
+	for _, column := range parseList(getenv("QUERYABLE_COLUMNS")) {
+		if cfg.QueryableColumns == nil {
+			cfg.QueryableColumns = make(map[string]bool)
+		}
+		cfg.QueryableColumns[column] = true
+	}
+	cfg.Aliases, err = parseAliases(getenv("COLUMN_ALIASES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid COLUMN_ALIASES: %w", err)
+	}
+	cfg.Strict, err = parseBool(getenv("CSV_STRICT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_STRICT: %w", err)
+	}
+	cfg.Writable, err = parseBool(getenv("CSV_WRITABLE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV_WRITABLE: %w", err)
+	}
+
+	switch format := getenv("FILE_FORMAT"); format {
+	case "", "csv":
+		if getenv("FIXED_WIDTH_COLUMNS") != "" {
+			return nil, errors.New("FIXED_WIDTH_COLUMNS is set but FILE_FORMAT is not fixedwidth")
+		}
+	case "fixedwidth":
+		cfg.FixedWidth, err = parseFixedWidth(getenv("FIXED_WIDTH_COLUMNS"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid FIXED_WIDTH_COLUMNS: %w", err)
+		}
+		if cfg.Writable {
+			return nil, errors.New("CSV_WRITABLE cannot be used with fixed-width files")
+		}
+	default:
+		return nil, fmt.Errorf("FILE_FORMAT must be %q or %q, got %q", "csv", "fixedwidth", format)
+	}
+
+	cfg.AuditEnabled, err = parseBool(getenv("AUDIT_ENABLED"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_ENABLED: %w", err)
+	}
+	cfg.AuditLogFile = getenv("AUDIT_LOG_FILE")
+
+	cfg.PprofEnabled, err = parseBool(getenv("ENABLE_PPROF"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ENABLE_PPROF: %w", err)
+	}
+	cfg.PprofAddr = getenv("PPROF_ADDR")
+	if cfg.PprofAddr == "" {
+		cfg.PprofAddr = "localhost:6060"
+	}
+
+	cfg.MaxRecords = 1000
+	if v := getenv("MAX_RECORDS"); v != "" {
+		cfg.MaxRecords, err = strconv.Atoi(v)
+		if err != nil || cfg.MaxRecords < 1 {
+			return nil, fmt.Errorf("MAX_RECORDS must be a positive integer, got %q", v)
+		}
+	}
+	cfg.DefaultRecordCount = 10
+	if v := getenv("DEFAULT_RECORD_COUNT"); v != "" {
+		cfg.DefaultRecordCount, err = strconv.Atoi(v)
+		if err != nil || cfg.DefaultRecordCount < 1 {
+			return nil, fmt.Errorf("DEFAULT_RECORD_COUNT must be a positive integer, got %q", v)
+		}
+	}
+
+	cfg.SkipStartupCheck, err = parseBool(getenv("SKIP_STARTUP_CHECK"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SKIP_STARTUP_CHECK: %w", err)
+	}
+
+	cfg.ServerName = getenv("MCP_SERVER_NAME")
+	if cfg.ServerName == "" {
+		cfg.ServerName = "claude-connector"
+	}
+
+	cfg.ToolTimeout = 30 * time.Second
+	if v := getenv("TOOL_TIMEOUT"); v != "" {
+		cfg.ToolTimeout, err = time.ParseDuration(v)
+		if err != nil || cfg.ToolTimeout <= 0 {
+			return nil, fmt.Errorf("TOOL_TIMEOUT must be a positive duration such as 30s, got %q", v)
+		}
+	}
+
+	cfg.IdempotencyCacheSize = 1000
+	if v := getenv("IDEMPOTENCY_CACHE_SIZE"); v != "" {
+		cfg.IdempotencyCacheSize, err = strconv.Atoi(v)
+		if err != nil || cfg.IdempotencyCacheSize < 1 {
+			return nil, fmt.Errorf("IDEMPOTENCY_CACHE_SIZE must be a positive integer, got %q", v)
+		}
+	}
+	cfg.IdempotencyTTL = time.Hour
+	if v := getenv("IDEMPOTENCY_TTL"); v != "" {
+		cfg.IdempotencyTTL, err = time.ParseDuration(v)
+		if err != nil || cfg.IdempotencyTTL <= 0 {
+			return nil, fmt.Errorf("IDEMPOTENCY_TTL must be a positive duration such as 1h, got %q", v)
+		}
+	}
+
+	switch cfg.AuthMode {
+	case "":
+		cfg.AuthMode = AuthModeJWT
+	case AuthModeJWT, AuthModeIntrospect, AuthModeHMAC:
+	default:
+		return nil, fmt.Errorf("AUTH_MODE must be %q, %q or %q, got %q", AuthModeJWT, AuthModeIntrospect, AuthModeHMAC, cfg.AuthMode)
+	}
+
+	if v := getenv("MAX_DATA_AGE"); v != "" {
+		cfg.MaxDataAge, err = time.ParseDuration(v)
+		if err != nil || cfg.MaxDataAge < 0 {
+			return nil, fmt.Errorf("MAX_DATA_AGE must be a non-negative duration such as 24h, got %q", v)
+		}
+	}
+
+	cfg.MaxBodyBytes = 1 << 20
+	if v := getenv("MAX_BODY_BYTES"); v != "" {
+		cfg.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cfg.MaxBodyBytes < 1 {
+			return nil, fmt.Errorf("MAX_BODY_BYTES must be a positive integer, got %q", v)
+		}
+	}
+
+	// The stdio transport is process-local and does not authenticate.
+	if cfg.Transport != TransportStdio {
+		if cfg.AuthMode == AuthModeJWT && cfg.JWKSURL == "" {
+			return nil, errors.New("JWKS_URL is not set")
+		}
+		if cfg.AuthMode == AuthModeIntrospect && cfg.IntrospectionURL == "" {
+			return nil, errors.New("INTROSPECTION_URL is not set")
+		}
+	}
+	if cfg.AuthMode == AuthModeHMAC {
+		cfg.JWTSharedSecret = []byte(getenv("JWT_SHARED_SECRET"))
+		if len(cfg.JWTSharedSecret) < minSharedSecretLength && cfg.Transport != TransportStdio {
+			return nil, fmt.Errorf("JWT_SHARED_SECRET must be at least %d bytes in hmac mode", minSharedSecretLength)
+		}
+	}
+	if cfg.JWKSURL != "" {
+		if err := validateURL(cfg.JWKSURL); err != nil {
+			return nil, fmt.Errorf("invalid JWKS_URL: %w", err)
+		}
+	}
+
+	if cfg.IntrospectionURL != "" {
+		if err := validateURL(cfg.IntrospectionURL); err != nil {
+			return nil, fmt.Errorf("invalid INTROSPECTION_URL: %w", err)
+		}
+	}
+	cfg.IntrospectionClientID = getenv("INTROSPECTION_CLIENT_ID")
+	cfg.IntrospectionClientSecret = getenv("INTROSPECTION_CLIENT_SECRET")
+	cfg.IntrospectionCacheTTL = 30 * time.Second
+	if v := getenv("INTROSPECTION_CACHE_TTL"); v != "" {
+		cfg.IntrospectionCacheTTL, err = time.ParseDuration(v)
+		if err != nil || cfg.IntrospectionCacheTTL < 0 {
+			return nil, fmt.Errorf("INTROSPECTION_CACHE_TTL must be a non-negative duration such as 30s, got %q", v)
+		}
+	}
+
+	cfg.JWKSCacheTTL = 15 * time.Minute
+	if v := getenv("JWKS_CACHE_TTL"); v != "" {
+		cfg.JWKSCacheTTL, err = time.ParseDuration(v)
+		if err != nil || cfg.JWKSCacheTTL <= 0 {
+			return nil, fmt.Errorf("JWKS_CACHE_TTL must be a positive duration such as 15m, got %q", v)
+		}
+	}
+
+	cfg.JWKSRetries = 2
+	if v := getenv("JWKS_FETCH_RETRIES"); v != "" {
+		cfg.JWKSRetries, err = strconv.Atoi(v)
+		if err != nil || cfg.JWKSRetries < 0 {
+			return nil, fmt.Errorf("JWKS_FETCH_RETRIES must be a non-negative integer, got %q", v)
+		}
+	}
+
+	cfg.JWKSTimeout = 5 * time.Second
+	if v := getenv("JWKS_TIMEOUT"); v != "" {
+		cfg.JWKSTimeout, err = time.ParseDuration(v)
+		if err != nil || cfg.JWKSTimeout <= 0 {
+			return nil, fmt.Errorf("JWKS_TIMEOUT must be a positive duration such as 5s, got %q", v)
+		}
+	}
+
+	cfg.JWTAlgorithms = parseList(getenv("JWT_ALGS"))
+
+	cfg.Leeway = 30 * time.Second
+	if v := getenv("JWT_LEEWAY_SECONDS"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("JWT_LEEWAY_SECONDS must be a non-negative integer, got %q", v)
+		}
+		cfg.Leeway = time.Duration(seconds) * time.Second
+	}
+
+	// An explicitly empty scope disables the check, so unset and empty differ.
+	var ok bool
+	cfg.RequiredScope, ok = lookup("REQUIRED_SCOPE")
+	if !ok {
+		cfg.RequiredScope = "records:read"
+	}
+	cfg.WriteScope, ok = lookup("WRITE_SCOPE")
+	if !ok {
+		cfg.WriteScope = "records:write"
+	}
+	// An empty admin scope disables the admin endpoints instead, as they must
+	// never be open to every authenticated caller.
+	cfg.AdminScope, ok = lookup("ADMIN_SCOPE")
+	if !ok {
+		cfg.AdminScope = "admin"
+	}
+
+	cfg.ShutdownTimeout = 10 * time.Second
+	if v := getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		cfg.ShutdownTimeout, err = time.ParseDuration(v)
+		if err != nil || cfg.ShutdownTimeout <= 0 {
+			return nil, fmt.Errorf("SHUTDOWN_TIMEOUT must be a positive duration such as 10s, got %q", v)
+		}
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	cfg.TLSMinVersion, err = parseTLSVersion(getenv("TLS_MIN_VERSION"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS_MIN_VERSION: %w", err)
+	}
+
+	cfg.RateLimitRPS = 10
+	if v := getenv("RATE_LIMIT_RPS"); v != "" {
+		cfg.RateLimitRPS, err = strconv.ParseFloat(v, 64)
+		if err != nil || cfg.RateLimitRPS < 0 {
+			return nil, fmt.Errorf("RATE_LIMIT_RPS must be a non-negative number, got %q", v)
+		}
+	}
+	cfg.RateLimitBurst = 20
+	if v := getenv("RATE_LIMIT_BURST"); v != "" {
+		cfg.RateLimitBurst, err = strconv.Atoi(v)
+		if err != nil || cfg.RateLimitBurst < 1 {
+			return nil, fmt.Errorf("RATE_LIMIT_BURST must be a positive integer, got %q", v)
+		}
+	}
+
+	cfg.OTLPEndpoint = getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if cfg.OTLPEndpoint != "" {
+		if u, err := url.Parse(cfg.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT must be an http or https URL, got %q", cfg.OTLPEndpoint)
+		}
+	}
+
+	cfg.TrustedProxies = parseList(getenv("TRUSTED_PROXIES"))
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entries must be IP addresses or CIDR ranges, got %q", proxy)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseDelimiter parses the CSV_DELIMITER value, which must be exactly one
+// rune. An empty value selects a comma, and the escape sequence \t selects a
+// tab since a literal tab is awkward to set in most environments.
+func parseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return ',', nil
+	case `\t`:
+		return '\t', nil
+	}
+
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("must be exactly one character, got %q", value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	if !tools.ValidDelimiter(r) {
+		return 0, fmt.Errorf("%q cannot be used as a delimiter", value)
+	}
+	return r, nil
+}
+
+// parseComment parses the CSV_COMMENT value, which must be empty, disabling
+// comment lines, or exactly one rune other than the delimiter.
+func parseComment(value string, delimiter rune) (rune, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("must be exactly one character, got %q", value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	if !tools.ValidDelimiter(r) {
+		return 0, fmt.Errorf("%q cannot be used as a comment character", value)
+	}
+	if r == delimiter {
+		return 0, fmt.Errorf("%q is also the delimiter", value)
+	}
+	return r, nil
+}
+
+// parseEncoding parses the CSV_ENCODING value. An empty value or utf-8 selects
+// UTF-8, which needs no decoding and is returned as nil.
+func parseEncoding(value string) (encoding.Encoding, error) {
+	switch strings.ToLower(value) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252, nil
+	case "latin1", "iso-8859-1":
+		return charmap.ISO8859_1, nil
+	}
+	return nil, fmt.Errorf("must be utf-8, windows-1252 or latin1, got %q", value)
+}
+
+// parseBool parses an optional boolean environment value; an empty value is
+// false.
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// validateURL checks that value is an absolute http or https URL.
+func validateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", value)
+	}
+	return nil
+}
+
+// parseTLSVersion maps a TLS_MIN_VERSION value to a crypto/tls version
+// constant. An empty value selects TLS 1.2.
+func parseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("must be 1.2 or 1.3, got %q", value)
+}
+
+// parseList splits a comma-separated environment value into its trimmed,
+// non-empty elements.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRedactions parses a REDACT_COLUMNS value of comma-separated column
+// names, each optionally followed by :last4 to keep its last four characters.
+func parseRedactions(value string) (map[string]string, error) {
+	redact := map[string]string{}
+	for _, entry := range parseList(value) {
+		column, mode, ok := strings.Cut(entry, ":")
+		if !ok {
+			mode = tools.RedactFull
+		}
+		if column == "" || !tools.ValidRedaction(mode) {
+			return nil, fmt.Errorf("entry %q must be a column name, optionally followed by :%s", entry, tools.RedactLast4)
+		}
+		redact[column] = mode
+	}
+	return redact, nil
+}
+
+// parseAliases parses a COLUMN_ALIASES value, either a JSON object or
+// comma-separated original=alias pairs. No two columns may share an alias.
+func parseAliases(value string) (map[string]string, error) {
+	aliases := map[string]string{}
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		if err := json.Unmarshal([]byte(value), &aliases); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, pair := range parseList(value) {
+			original, alias, _ := strings.Cut(pair, "=")
+			aliases[strings.TrimSpace(original)] = strings.TrimSpace(alias)
+		}
+	}
+
+	columns := make(map[string]string, len(aliases))
+	for original, alias := range aliases {
+		if original == "" || alias == "" {
+			return nil, fmt.Errorf("expected original=alias, got %q=%q", original, alias)
+		}
+		if other, ok := columns[alias]; ok {
+			return nil, fmt.Errorf("alias %q is given to both %q and %q", alias, other, original)
+		}
+		columns[alias] = original
+	}
+	return aliases, nil
+}
+
+// parseFixedWidth parses a FIXED_WIDTH_COLUMNS value of comma-separated
+// name:start:width entries, where start counts characters from 1.
+func parseFixedWidth(value string) ([]tools.FixedWidthColumn, error) {
+	var columns []tools.FixedWidthColumn
+	for _, entry := range parseList(value) {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("entry %q must be name:start:width", entry)
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("entry %q has a start that is not an integer", entry)
+		}
+		width, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil {
+			return nil, fmt.Errorf("entry %q has a width that is not an integer", entry)
+		}
+		columns = append(columns, tools.FixedWidthColumn{Name: strings.TrimSpace(parts[0]), Start: start, Width: width})
+	}
+	if err := tools.ValidateFixedWidth(columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// parseDatasets parses a CSV_FILES value of comma-separated name=path pairs.
+func parseDatasets(value string) (map[string]string, error) {
+	datasets := map[string]string{}
+	for _, pair := range parseList(value) {
+		name, path, ok := strings.Cut(pair, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("expected name=path, got %q", pair)
+		}
+		if _, exists := datasets[name]; exists {
+			return nil, fmt.Errorf("dataset %q is defined more than once", name)
+		}
+		datasets[name] = path
+	}
+	return datasets, nil
+}
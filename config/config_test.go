@@ -0,0 +1,138 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lookupIn returns a lookup function over env, for load.
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// baseEnv returns the smallest environment load accepts, with overrides
+// applied. An override to the empty string unsets the variable.
+func baseEnv(overrides map[string]string) map[string]string {
+	env := map[string]string{
+		"CSV_FILE_PATH": "records.csv",
+		"JWKS_URL":      "https://auth.example.com/.well-known/jwks.json",
+	}
+	for key, value := range overrides {
+		if value == "" {
+			delete(env, key)
+			continue
+		}
+		env[key] = value
+	}
+	return env
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(lookupIn(baseEnv(nil)))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.CSVFilePath != "records.csv" || len(cfg.Datasets) != 0 {
+		t.Errorf("CSVFilePath = %q, Datasets = %v; want records.csv and none", cfg.CSVFilePath, cfg.Datasets)
+	}
+	if cfg.Port != "8080" || cfg.Transport != TransportHTTP || cfg.AuthMode != AuthModeJWT || cfg.TailStrategy != TailStrategyScan {
+		t.Errorf("port, transport, auth mode, tail strategy = %q, %q, %q, %q", cfg.Port, cfg.Transport, cfg.AuthMode, cfg.TailStrategy)
+	}
+	if cfg.JWKSCacheTTL != 15*time.Minute || cfg.JWKSRetries != 2 || cfg.JWKSTimeout != 5*time.Second || cfg.Leeway != 30*time.Second {
+		t.Errorf("JWKS TTL, retries, timeout, leeway = %s, %d, %s, %s", cfg.JWKSCacheTTL, cfg.JWKSRetries, cfg.JWKSTimeout, cfg.Leeway)
+	}
+	if cfg.MaxBodyBytes != 1<<20 || cfg.IdempotencyCacheSize != 1000 || cfg.IdempotencyTTL != time.Hour || cfg.IntrospectionCacheTTL != 30*time.Second {
+		t.Errorf("body limit, idempotency size and TTL, introspection TTL = %d, %d, %s, %s", cfg.MaxBodyBytes, cfg.IdempotencyCacheSize, cfg.IdempotencyTTL, cfg.IntrospectionCacheTTL)
+	}
+	if cfg.PprofEnabled || cfg.PprofAddr != "localhost:6060" {
+		t.Errorf("pprof enabled = %t on %q, want disabled and on the loopback interface", cfg.PprofEnabled, cfg.PprofAddr)
+	}
+	if cfg.JWTAlgorithms != nil {
+		t.Errorf("JWTAlgorithms = %q, want none so that the middleware picks", cfg.JWTAlgorithms)
+	}
+	if cfg.DefaultRecordCount != 10 {
+		t.Errorf("DefaultRecordCount = %d, want 10", cfg.DefaultRecordCount)
+	}
+}
+
+func TestLoadDatasets(t *testing.T) {
+	cfg, err := load(lookupIn(baseEnv(map[string]string{
+		"CSV_FILE_PATH": "",
+		"CSV_FILES":     "labs=labs.csv, visits = visits.csv",
+	})))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := map[string]string{"labs": "labs.csv", "visits": "visits.csv"}
+	if cfg.CSVFilePath != "" || !reflect.DeepEqual(cfg.Datasets, want) {
+		t.Errorf("CSVFilePath = %q, Datasets = %v; want none and %v", cfg.CSVFilePath, cfg.Datasets, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		// wantErr is a substring of the error, which names the variable.
+		wantErr string
+	}{
+		{name: "no dataset", overrides: map[string]string{"CSV_FILE_PATH": ""}, wantErr: "none of CSV_FILE_PATH, CSV_FILES and CSV_DIR is set"},
+		{name: "no JWKS URL", overrides: map[string]string{"JWKS_URL": ""}, wantErr: "JWKS_URL is not set"},
+		{name: "no introspection URL", overrides: map[string]string{"AUTH_MODE": AuthModeIntrospect}, wantErr: "INTROSPECTION_URL is not set"},
+		{name: "short shared secret", overrides: map[string]string{"AUTH_MODE": AuthModeHMAC, "JWT_SHARED_SECRET": "short"}, wantErr: "JWT_SHARED_SECRET"},
+		{name: "unknown transport", overrides: map[string]string{"MCP_TRANSPORT": "grpc"}, wantErr: "MCP_TRANSPORT"},
+		{name: "unknown auth mode", overrides: map[string]string{"AUTH_MODE": "basic"}, wantErr: "AUTH_MODE"},
+		{name: "unknown tail strategy", overrides: map[string]string{"CSV_TAIL_STRATEGY": "mmap"}, wantErr: "CSV_TAIL_STRATEGY"},
+		{name: "malformed CSV_FILES", overrides: map[string]string{"CSV_FILES": "labs"}, wantErr: "invalid CSV_FILES"},
+		{name: "duplicate CSV_FILES", overrides: map[string]string{"CSV_FILES": "labs=a.csv,labs=b.csv"}, wantErr: "invalid CSV_FILES"},
+		{name: "missing CSV_DIR", overrides: map[string]string{"CSV_DIR": "/does/not/exist"}, wantErr: "invalid CSV_DIR"},
+		{name: "negative body limit", overrides: map[string]string{"MAX_BODY_BYTES": "-1"}, wantErr: "MAX_BODY_BYTES"},
+		{name: "zero JWKS TTL", overrides: map[string]string{"JWKS_CACHE_TTL": "0s"}, wantErr: "JWKS_CACHE_TTL"},
+		{name: "malformed timeout", overrides: map[string]string{"TOOL_TIMEOUT": "soon"}, wantErr: "TOOL_TIMEOUT"},
+		{name: "malformed leeway", overrides: map[string]string{"JWT_LEEWAY_SECONDS": "1m"}, wantErr: "JWT_LEEWAY_SECONDS"},
+		{name: "long comment", overrides: map[string]string{"CSV_COMMENT": "//"}, wantErr: "invalid CSV_COMMENT"},
+		{name: "comment is the delimiter", overrides: map[string]string{"CSV_DELIMITER": ";", "CSV_COMMENT": ";"}, wantErr: "invalid CSV_COMMENT"},
+		{name: "newline comment", overrides: map[string]string{"CSV_COMMENT": "\n"}, wantErr: "invalid CSV_COMMENT"},
+		{name: "malformed ENABLE_PPROF", overrides: map[string]string{"ENABLE_PPROF": "sometimes"}, wantErr: "invalid ENABLE_PPROF"},
+		{name: "zero default count", overrides: map[string]string{"DEFAULT_RECORD_COUNT": "0"}, wantErr: "DEFAULT_RECORD_COUNT"},
+		{name: "malformed default count", overrides: map[string]string{"DEFAULT_RECORD_COUNT": "ten"}, wantErr: "DEFAULT_RECORD_COUNT"},
+		{name: "malformed JWKS URL", overrides: map[string]string{"JWKS_URL": "not a url"}, wantErr: "invalid JWKS_URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(lookupIn(baseEnv(tt.overrides)))
+			if err == nil {
+				t.Fatalf("load succeeded, want an error mentioning %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("load error = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadComment(t *testing.T) {
+	cfg, err := load(lookupIn(baseEnv(map[string]string{"CSV_COMMENT": "#"})))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Comment != '#' {
+		t.Errorf("Comment = %q, want '#'", cfg.Comment)
+	}
+}
+
+func TestLoadStdioNeedsNoAuth(t *testing.T) {
+	cfg, err := load(lookupIn(baseEnv(map[string]string{"JWKS_URL": "", "MCP_TRANSPORT": TransportStdio})))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Transport != TransportStdio {
+		t.Errorf("Transport = %q, want %q", cfg.Transport, TransportStdio)
+	}
+}
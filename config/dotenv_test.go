@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeEnvFile writes content to a file in a temporary directory and returns
+// its path.
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing env file: %v", err)
+	}
+	return path
+}
+
+func TestReadEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "plain values",
+			content: "A=1\nB = two words \n",
+			want:    map[string]string{"A": "1", "B": "two words"},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# a comment\n\n  # indented comment\nA=1\n",
+			want:    map[string]string{"A": "1"},
+		},
+		{
+			name:    "export prefix",
+			content: "export A=1\n",
+			want:    map[string]string{"A": "1"},
+		},
+		{
+			name:    "quoting",
+			content: "A=\"double # quoted\"\nB='single'\nC=\"unmatched'\nD=\"\"\nE=a=b\n",
+			want:    map[string]string{"A": "double # quoted", "B": "single", "C": "\"unmatched'", "D": "", "E": "a=b"},
+		},
+		{
+			name:    "later lines win",
+			content: "A=1\nA=2\n",
+			want:    map[string]string{"A": "2"},
+		},
+		{name: "missing equals sign", content: "A=1\nJUST_A_KEY\n", wantErr: true},
+		{name: "missing key", content: "=1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readEnvFile(writeEnvFile(t, tt.content))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readEnvFile = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readEnvFile: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readEnvFile = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := writeEnvFile(t, "CSV_FILE_PATH=/from/file.csv\nJWKS_URL=https://file.example.com/jwks.json\nMCP_SERVER_PORT=9000\n")
+	t.Setenv("ENV_FILE", path)
+	t.Setenv("MCP_SERVER_PORT", "7000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CSVFilePath != "/from/file.csv" || cfg.JWKSURL != "https://file.example.com/jwks.json" {
+		t.Errorf("CSVFilePath, JWKSURL = %q, %q; want the file values", cfg.CSVFilePath, cfg.JWKSURL)
+	}
+	if cfg.Port != "7000" {
+		t.Errorf("Port = %q, want the environment to beat the file", cfg.Port)
+	}
+}
+
+func TestLoadEnvFileMissing(t *testing.T) {
+	t.Setenv("ENV_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	if _, err := Load(); err == nil {
+		t.Error("Load succeeded with a missing ENV_FILE")
+	}
+}
+
+func TestLoadDefaultEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	// t.Setenv restores the variables afterwards; they are then unset for
+	// the test itself.
+	for _, key := range []string{"ENV_FILE", "MCP_SERVER_PORT"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Setenv("CSV_FILE_PATH", "/from/env.csv")
+	t.Setenv("JWKS_URL", "https://env.example.com/jwks.json")
+
+	// Without a .env file in the working directory nothing is read.
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load without a .env file: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, DefaultEnvFile), []byte("MCP_SERVER_PORT=9000\n"), 0o600); err != nil {
+		t.Fatalf("writing .env: %v", err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("Port = %q, want the value from .env", cfg.Port)
+	}
+
+	t.Setenv("ENV_FILE", "")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load with an empty ENV_FILE: %v", err)
+	}
+	if cfg.Port == "9000" {
+		t.Error("Load with an empty ENV_FILE read .env")
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/tools"
+)
+
+// reloadSummary is the JSON shape returned by ReloadHandler.
+type reloadSummary struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Datasets []string `json:"datasets"`
+}
+
+// ReloadHandler rescans the dataset directory and empties the record cache,
+// so that new files can be queried and changed ones are read afresh without a
+// restart. It responds with the datasets that were added and removed.
+func ReloadHandler(registry *DatasetRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		added, removed, err := registry.Reload()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		tools.ClearCache()
+
+		c.JSON(http.StatusOK, reloadSummary{
+			Added:    added,
+			Removed:  removed,
+			Datasets: registry.Snapshot().Names(),
+		})
+	}
+}
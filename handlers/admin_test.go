@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReloadHandler(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("labs.csv", "id\n1\n")
+	write("notes.txt", "not a dataset")
+	static := writeFixture(t, "records.csv", "id\n1\n")
+
+	registry, err := NewDatasetRegistry(Datasets{DefaultDataset: static}, dir)
+	if err != nil {
+		t.Fatalf("NewDatasetRegistry: %v", err)
+	}
+	router := gin.New()
+	router.POST("/admin/reload", ReloadHandler(registry))
+	reload := func() reloadSummary {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("reload status = %d: %s", w.Code, w.Body)
+		}
+		var summary reloadSummary
+		if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+		return summary
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		want   reloadSummary
+	}{
+		{
+			name:   "nothing changed",
+			change: func() {},
+			want:   reloadSummary{Added: []string{}, Removed: []string{}, Datasets: []string{"default", "labs"}},
+		},
+		{
+			name: "files added",
+			change: func() {
+				write("visits.csv", "id,date\n1,2024-01-01\n")
+				write("archive.csv.gz", "")
+			},
+			want: reloadSummary{Added: []string{"archive", "visits"}, Removed: []string{}, Datasets: []string{"archive", "default", "labs", "visits"}},
+		},
+		{
+			name: "file removed",
+			change: func() {
+				if err := os.Remove(filepath.Join(dir, "labs.csv")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: reloadSummary{Added: []string{}, Removed: []string{"labs"}, Datasets: []string{"archive", "default", "visits"}},
+		},
+		{
+			name:   "file shadowing a static dataset",
+			change: func() { write("default.csv", "id\n") },
+			want:   reloadSummary{Added: []string{}, Removed: []string{}, Datasets: []string{"archive", "default", "visits"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			if got := reload(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reload = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if path, err := registry.Resolve(DefaultDataset); err != nil || path != static {
+		t.Errorf("Resolve(default) = %q, %v; want the static file %q", path, err, static)
+	}
+	cfg := Config{Datasets: registry, DefaultCount: 10}
+	text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{"dataset": "visits", "count": 1})
+	if isError {
+		t.Fatalf("get_last_n_records on the added dataset failed: %s", text)
+	}
+	if got := decodeRecords(t, text).Records; len(got) != 1 || got[0]["date"] != "2024-01-01" {
+		t.Errorf("records of the added dataset = %v", got)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/korjavin/claude_connector/tools"
+)
+
+// AuditLogger writes a JSON line for every tool invocation recording who
+// called which tool with which arguments and how many rows it returned.
+// Argument values that would reveal redacted columns are masked.
+type AuditLogger struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewAuditLogger returns an AuditLogger writing to w.
+func NewAuditLogger(w io.Writer) *AuditLogger {
+	return &AuditLogger{encoder: json.NewEncoder(w)}
+}
+
+// auditEntry is the JSON shape of one audit log line. Rows is the number of
+// records returned, zero for tools that do not return records.
+type auditEntry struct {
+	Time      time.Time      `json:"time"`
+	Subject   string         `json:"subject"`
+	RequestID string         `json:"request_id,omitempty"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments"`
+	Rows      int            `json:"rows"`
+	Result    string         `json:"result"`
+}
+
+// log writes entry. Write failures are logged but do not fail the tool call.
+func (l *AuditLogger) log(entry auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.encoder.Encode(entry); err != nil {
+		slog.Error("failed to write audit log entry", slog.String("tool", entry.Tool), slog.String("error", err.Error()))
+	}
+}
+
+// auditArguments returns the tool arguments as logged: a JSON object with the
+// values compared against or written to redacted columns masked.
+func auditArguments(args any) map[string]any {
+	var arguments map[string]any
+	body, err := json.Marshal(args)
+	if err == nil {
+		err = json.Unmarshal(body, &arguments)
+	}
+	if err != nil {
+		return map[string]any{}
+	}
+
+	redactComparison(arguments)
+	if conditions, ok := arguments["conditions"].([]any); ok {
+		for _, condition := range conditions {
+			if condition, ok := condition.(map[string]any); ok {
+				redactComparison(condition)
+			}
+		}
+	}
+	if record, ok := arguments["record"].(map[string]any); ok {
+		for name := range record {
+			if tools.Redacted(name) {
+				record[name] = tools.RedactedPlaceholder
+			}
+		}
+	}
+	// Positional fields cannot be matched to columns without reading the
+	// header, so they are only logged when nothing is redacted.
+	if fields, ok := arguments["fields"].([]any); ok && tools.RedactsAny() {
+		for i := range fields {
+			fields[i] = tools.RedactedPlaceholder
+		}
+	}
+	return arguments
+}
+
+// redactComparison masks the value a column argument is compared with when
+// the column is redacted.
+func redactComparison(arguments map[string]any) {
+	for column, value := range map[string]string{"column": "value", "id_column": "id"} {
+		if name, ok := arguments[column].(string); ok && tools.Redacted(name) {
+			if _, ok := arguments[value]; ok {
+				arguments[value] = tools.RedactedPlaceholder
+			}
+		}
+	}
+}
+
+// auditRowsKey is the context key under which registerTool stores the row
+// count of the current tool call.
+type auditRowsKey struct{}
+
+// reportRows records that the current tool call returned n records, for the
+// audit log and the tool's trace span.
+func reportRows(ctx context.Context, n int) {
+	if rows, ok := ctx.Value(auditRowsKey{}).(*int); ok {
+		*rows = n
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/korjavin/claude_connector/middleware"
+	"github.com/korjavin/claude_connector/tools"
+)
+
+// withSubject runs handler with the claims of a token for sub, as
+// AuthMiddleware would have stored them.
+func withSubject(sub string, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(middleware.ClaimsKey, jwt.MapClaims{"sub": sub})
+		handler(c)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	setReaderOptions(t, tools.ReaderOptions{Redact: map[string]string{"ssn": tools.RedactFull}})
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]any
+		wantRows int
+		want     string
+		wantArgs map[string]any
+	}{
+		{
+			name: "success", tool: "get_last_n_records", args: map[string]any{"count": 2},
+			wantRows: 2, want: "success", wantArgs: map[string]any{"count": float64(2)},
+		},
+		{
+			name: "error", tool: "get_last_n_records", args: map[string]any{"count": -1},
+			want: "error", wantArgs: map[string]any{"count": float64(-1)},
+		},
+		{
+			name: "redacted comparison", tool: "get_records_where", args: map[string]any{"column": "ssn", "value": "123-45-6789"},
+			want: "success", wantArgs: map[string]any{"column": "ssn", "value": tools.RedactedPlaceholder},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log bytes.Buffer
+			cfg := toolConfig(t, "id,ssn\n1,123-45-6789\n2,987-65-4321\n3,555-55-5555\n")
+			cfg.Audit = NewAuditLogger(&log)
+
+			callToolOn(t, withSubject("clinician-7", MCPHandler(cfg)), tt.tool, tt.args)
+
+			lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("audit log has %d lines, want 1: %s", len(lines), log.String())
+			}
+			var entry auditEntry
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatalf("decoding audit entry %s: %v", lines[0], err)
+			}
+			if entry.Subject != "clinician-7" || entry.Tool != tt.tool || entry.Rows != tt.wantRows || entry.Result != tt.want {
+				t.Errorf("entry = %+v, want subject clinician-7, tool %s, %d rows and result %s", entry, tt.tool, tt.wantRows, tt.want)
+			}
+			for name, want := range tt.wantArgs {
+				if got := entry.Arguments[name]; got != want {
+					t.Errorf("argument %s = %v, want %v", name, got, want)
+				}
+			}
+			if entry.Time.IsZero() {
+				t.Error("entry has no time")
+			}
+		})
+	}
+}
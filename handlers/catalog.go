@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/invopop/jsonschema"
+)
+
+// schemaReflector derives tool input schemas from argument structs with the
+// same settings mcp-golang uses for tools/list, so the catalog matches what
+// MCP clients see.
+var schemaReflector = jsonschema.Reflector{
+	Anonymous:                  true,
+	AllowAdditionalProperties:  true,
+	RequiredFromJSONSchemaTags: true,
+	DoNotReference:             true,
+	ExpandedStruct:             true,
+}
+
+// toolInfo describes one registered tool in the catalog.
+type toolInfo struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	InputSchema *jsonschema.Schema `json:"inputSchema"`
+}
+
+// ToolsHandler lists the tools selected by cfg with their descriptions and
+// input schemas, for operators and clients that want to see what is available
+// without an MCP handshake.
+func ToolsHandler(cfg Config) gin.HandlerFunc {
+	r := &toolRegistrar{}
+	registerTools(r, cfg)
+
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tools": r.tools})
+	}
+}
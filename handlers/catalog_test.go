@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestToolsHandler(t *testing.T) {
+	router := gin.New()
+	router.GET("/tools", ToolsHandler(toolConfig(t, "id,name\n1,a\n")))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tools", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var catalog struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			InputSchema struct {
+				Type       string                    `json:"type"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &catalog); err != nil {
+		t.Fatalf("decoding catalog %s: %v", w.Body, err)
+	}
+
+	var names []string
+	for _, tool := range catalog.Tools {
+		names = append(names, tool.Name)
+		if tool.Name != "get_last_n_records" {
+			continue
+		}
+		if tool.Description == "" || tool.InputSchema.Type != "object" {
+			t.Errorf("get_last_n_records = %+v, want a description and an object schema", tool)
+		}
+		count, ok := tool.InputSchema.Properties["count"]
+		if !ok || count["type"] != "integer" {
+			t.Errorf("get_last_n_records properties = %v, want an integer count", tool.InputSchema.Properties)
+		}
+	}
+	if !reflect.DeepEqual(names, readOnlyTools) {
+		t.Errorf("catalog tools = %q, want %q", names, readOnlyTools)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// errCursorStale is returned by decodeCursor when the file changed after the
+// cursor was issued, so its offset no longer points at the next chunk.
+var errCursorStale = errors.New("the file changed since the cursor was issued; repeat the call without a cursor to start again")
+
+// resultCursor is the content of the opaque cursor a tool returns with a
+// chunk of results when more remain. Offset is the number of matching
+// records already returned, and Fingerprint identifies the version of the
+// file they came from.
+type resultCursor struct {
+	Offset      int    `json:"offset"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// encodeCursor returns the cursor that continues after offset matching
+// records of the file with the given fingerprint.
+func encodeCursor(offset int, fingerprint string) string {
+	data, _ := json.Marshal(resultCursor{Offset: offset, Fingerprint: fingerprint})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the offset a cursor continues from. The cursor must
+// have been issued for the file with the given fingerprint, which holds rows
+// data rows; no cursor issued for it can point past them, so a larger offset
+// is refused rather than left to overflow the limits computed from it.
+func decodeCursor(cursor, fingerprint string, rows int) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	var c resultCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Offset < 0 || c.Offset > rows {
+		return 0, errors.New("invalid cursor")
+	}
+	if c.Fingerprint != fingerprint {
+		return 0, errCursorStale
+	}
+	return c.Offset, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pageThrough calls tool with args, following next_cursor until no more
+// chunks remain, and returns the ids of every chunk.
+func pageThrough(t *testing.T, cfg Config, tool string, args map[string]any) [][]string {
+	t.Helper()
+	var chunks [][]string
+	for cursor := ""; ; {
+		args["cursor"] = cursor
+		text, isError := callTool(t, cfg, tool, args)
+		if isError {
+			t.Fatalf("%s failed after %d chunks: %s", tool, len(chunks), text)
+		}
+		resp := decodeRecords(t, text)
+		chunks = append(chunks, recordIDs(resp.Records))
+		if resp.NextCursor == "" {
+			return chunks
+		}
+		if len(chunks) > 10 {
+			t.Fatalf("%s did not stop paging", tool)
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+func TestCursorPaging(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,B\n3,A\n4,A\n5,B\n6,A\n7,A\n")
+	want := [][]string{{"1", "3"}, {"4", "6"}, {"7"}}
+
+	tests := []struct {
+		tool string
+		args map[string]any
+	}{
+		{tool: "query_records", args: map[string]any{
+			"conditions": []map[string]any{{"column": "ward", "operator": "eq", "value": "A"}}, "limit": 2,
+		}},
+		{tool: "search_records", args: map[string]any{"query": "A", "limit": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			if got := pageThrough(t, cfg, tt.tool, tt.args); !reflect.DeepEqual(got, want) {
+				t.Errorf("chunks = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCursorInvalidatedByChange(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,A\n3,A\n")
+	path, err := cfg.Datasets.Resolve("")
+	if err != nil {
+		t.Fatalf("resolving dataset: %v", err)
+	}
+
+	text, isError := callTool(t, cfg, "search_records", map[string]any{"query": "A", "limit": 2})
+	if isError {
+		t.Fatalf("search_records failed: %s", text)
+	}
+	cursor := decodeRecords(t, text).NextCursor
+	if cursor == "" {
+		t.Fatal("first chunk has no next_cursor")
+	}
+
+	if err := os.WriteFile(path, []byte("id,ward\n1,A\n2,A\n3,A\n4,A\n"), 0o600); err != nil {
+		t.Fatalf("rewriting fixture: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("touching fixture: %v", err)
+	}
+
+	text, isError = callTool(t, cfg, "search_records", map[string]any{"query": "A", "limit": 2, "cursor": cursor})
+	if !isError || !strings.Contains(text, errCursorStale.Error()) {
+		t.Errorf("search_records with a stale cursor = %q, want the stale cursor error", text)
+	}
+}
+
+func TestCursorRejectsCraftedOffsets(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,A\n3,A\n")
+	path, err := cfg.Datasets.Resolve("")
+	if err != nil {
+		t.Fatalf("resolving dataset: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat fixture: %v", err)
+	}
+	fingerprint := fmt.Sprintf("%x-%x", info.Size(), info.ModTime().UnixNano())
+
+	tests := []struct {
+		name    string
+		cursor  string
+		wantErr bool
+	}{
+		{name: "at the end", cursor: encodeCursor(3, fingerprint)},
+		{name: "past the rows", cursor: encodeCursor(4, fingerprint), wantErr: true},
+		{name: "near MaxInt", cursor: encodeCursor(math.MaxInt-1, fingerprint), wantErr: true},
+		{name: "negative", cursor: encodeCursor(-1, fingerprint), wantErr: true},
+		{name: "not base64", cursor: "!!!", wantErr: true},
+		{name: "not json", cursor: base64.RawURLEncoding.EncodeToString([]byte("offset")), wantErr: true},
+	}
+	for _, tt := range tests {
+		for _, tool := range []string{"query_records", "search_records"} {
+			t.Run(tt.name+"/"+tool, func(t *testing.T) {
+				args := map[string]any{"query": "A", "limit": 2, "cursor": tt.cursor}
+				if tool == "query_records" {
+					args = map[string]any{
+						"conditions": []map[string]any{{"column": "ward", "operator": "eq", "value": "A"}}, "limit": 2, "cursor": tt.cursor,
+					}
+				}
+				text, isError := callTool(t, cfg, tool, args)
+				if isError != tt.wantErr {
+					t.Errorf("%s = %q, want error %t", tool, text, tt.wantErr)
+				}
+				if tt.wantErr && !strings.Contains(text, "invalid cursor") {
+					t.Errorf("%s = %q, want an invalid cursor error", tool, text)
+				}
+			})
+		}
+	}
+}
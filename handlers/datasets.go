@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDataset is the name CSV_FILE_PATH is registered under.
+const DefaultDataset = "default"
+
+// Datasets maps dataset names to the CSV files they are read from.
+type Datasets map[string]string
+
+// Names returns the dataset names in sorted order.
+func (d Datasets) Names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the file path of the named dataset. An empty name selects
+// the only dataset when there is just one, or DefaultDataset otherwise.
+func (d Datasets) Resolve(name string) (string, error) {
+	if len(d) == 0 {
+		return "", errors.New("no datasets are available")
+	}
+	if name == "" {
+		if len(d) == 1 {
+			for _, path := range d {
+				return path, nil
+			}
+		}
+		name = DefaultDataset
+	}
+
+	path, ok := d[name]
+	if !ok {
+		return "", fmt.Errorf("unknown dataset %q; valid datasets are: %s", name, strings.Join(d.Names(), ", "))
+	}
+	return path, nil
+}
+
+// DatasetRegistry is the set of datasets the tools can read: the datasets
+// configured explicitly plus, when a directory is given, one per CSV file in
+// it, named by the file's base name. It is safe for concurrent use, and the
+// directory can be rescanned while requests are served.
+type DatasetRegistry struct {
+	static Datasets
+	dir    string
+
+	mu      sync.RWMutex
+	current Datasets
+}
+
+// NewDatasetRegistry returns a registry of the static datasets and, if dir is
+// not empty, the CSV files found in dir.
+func NewDatasetRegistry(static Datasets, dir string) (*DatasetRegistry, error) {
+	r := &DatasetRegistry{static: static, dir: dir, current: static}
+	if dir != "" {
+		if err := r.Rescan(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Snapshot returns the current datasets. The caller must not modify it.
+func (r *DatasetRegistry) Snapshot() Datasets {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Resolve returns the file path of the named dataset, as Datasets.Resolve.
+func (r *DatasetRegistry) Resolve(name string) (string, error) {
+	return r.Snapshot().Resolve(name)
+}
+
+// Rescan replaces the datasets found in the directory with its current
+// contents. Files ending in .csv or .csv.gz are registered under their name
+// without that extension. A name already taken by a static dataset or by an
+// earlier file, in sorted order, is skipped with a warning. An empty
+// directory simply contributes no datasets.
+func (r *DatasetRegistry) Rescan() error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return fmt.Errorf("could not read dataset directory: %w", err)
+	}
+
+	datasets := make(Datasets, len(r.static)+len(entries))
+	for name, path := range r.static {
+		datasets[name] = path
+	}
+	for _, entry := range entries {
+		name, ok := datasetName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(r.dir, entry.Name())
+		if existing, taken := datasets[name]; taken {
+			slog.Warn("skipping dataset file whose name is already taken", "file", path, "dataset", name, "existing", existing)
+			continue
+		}
+		datasets[name] = path
+	}
+
+	r.mu.Lock()
+	r.current = datasets
+	r.mu.Unlock()
+	return nil
+}
+
+// Reload rescans the directory, if the registry has one, and returns the
+// names of the datasets that appeared and disappeared, in sorted order.
+func (r *DatasetRegistry) Reload() (added, removed []string, err error) {
+	before := r.Snapshot()
+	if r.dir != "" {
+		if err := r.Rescan(); err != nil {
+			return nil, nil, err
+		}
+	}
+	after := r.Snapshot()
+
+	added, removed = []string{}, []string{}
+	for _, name := range after.Names() {
+		if _, ok := before[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	for _, name := range before.Names() {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed, nil
+}
+
+// Watch rescans the directory whenever a file is added to, removed from or
+// renamed in it, until ctx is done. It returns immediately when the registry
+// has no directory.
+func (r *DatasetRegistry) Watch(ctx context.Context) error {
+	if r.dir == "" {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not watch dataset directory: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(r.dir); err != nil {
+		return fmt.Errorf("could not watch dataset directory: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			if err := r.Rescan(); err != nil {
+				slog.Warn("failed to rescan dataset directory", "dir", r.dir, "error", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("dataset directory watch error", "dir", r.dir, "error", err)
+		}
+	}
+}
+
+// datasetName returns the dataset name for a file in the dataset directory,
+// reporting false for files that are not CSV files.
+func datasetName(file string) (string, bool) {
+	for _, ext := range []string{".csv", ".csv.gz"} {
+		if name, ok := strings.CutSuffix(file, ext); ok && name != "" && !strings.HasPrefix(file, ".") {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDatasetRegistryDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"visits.csv":  "id,ward\n1,A\n2,B\n",
+		"labs.csv":    "id,test\n10,ldl\n",
+		"notes.txt":   "not a dataset\n",
+		".hidden.csv": "id\n1\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "archive.csv"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	registry, err := NewDatasetRegistry(Datasets{}, dir)
+	if err != nil {
+		t.Fatalf("NewDatasetRegistry: %v", err)
+	}
+	if names, want := registry.Snapshot().Names(), []string{"labs", "visits"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("datasets = %q, want %q", names, want)
+	}
+	for _, name := range []string{"labs", "visits"} {
+		path, err := registry.Resolve(name)
+		if err != nil || path != filepath.Join(dir, name+".csv") {
+			t.Errorf("Resolve(%q) = %q, %v", name, path, err)
+		}
+	}
+	for _, name := range []string{"missing", "notes", ""} {
+		_, err := registry.Resolve(name)
+		if err == nil || !strings.Contains(err.Error(), "valid datasets are: labs, visits") {
+			t.Errorf("Resolve(%q) = %v, want an unknown dataset error listing both", name, err)
+		}
+	}
+
+	cfg := Config{Datasets: registry, DefaultCount: 10}
+	text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{"dataset": "labs", "count": 5})
+	if isError {
+		t.Fatalf("get_last_n_records on labs failed: %s", text)
+	}
+	if ids := recordIDs(decodeRecords(t, text).Records); !reflect.DeepEqual(ids, []string{"10"}) {
+		t.Errorf("labs ids = %q, want [10]", ids)
+	}
+	if text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{"dataset": "missing"}); !isError || !strings.Contains(text, `unknown dataset "missing"`) {
+		t.Errorf("get_last_n_records on an unknown dataset = %q", text)
+	}
+}
+
+func TestDatasetRegistryRescan(t *testing.T) {
+	dir := t.TempDir()
+	static := writeFixture(t, "static.csv", "id\n1\n")
+	registry, err := NewDatasetRegistry(Datasets{"visits": static}, dir)
+	if err != nil {
+		t.Fatalf("NewDatasetRegistry: %v", err)
+	}
+	if names := registry.Snapshot().Names(); !reflect.DeepEqual(names, []string{"visits"}) {
+		t.Errorf("datasets of an empty directory = %q, want only the static one", names)
+	}
+
+	for _, name := range []string{"visits.csv", "labs.csv.gz"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+	added, removed, err := registry.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !reflect.DeepEqual(added, []string{"labs"}) || len(removed) != 0 {
+		t.Errorf("Reload = %q added, %q removed; want [labs] and none", added, removed)
+	}
+	if path, _ := registry.Resolve("visits"); path != static {
+		t.Errorf("visits = %q, want the static %q to win the collision", path, static)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "labs.csv.gz")); err != nil {
+		t.Fatal(err)
+	}
+	added, removed, err = registry.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(added) != 0 || !reflect.DeepEqual(removed, []string{"labs"}) {
+		t.Errorf("Reload = %q added, %q removed; want none and [labs]", added, removed)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/tools"
+)
+
+// HealthHandler is the liveness check. It always reports ok, along with the
+// build commit and API version, since a process that can answer is alive;
+// whether it can serve data is ReadinessHandler's concern.
+func HealthHandler(commit, apiVersion string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":      "ok",
+			"commit":      commit,
+			"api_version": apiVersion,
+			"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// ReadinessHandler reports whether the CSV file of every dataset can be read.
+// It returns 503 with the reason when a file is missing, is a directory, or
+// cannot be opened, so traffic is not routed to a pod that cannot serve it.
+func ReadinessHandler(registry *DatasetRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasets := registry.Snapshot()
+		if len(datasets) == 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "reason": "no datasets are available"})
+			return
+		}
+		for _, name := range datasets.Names() {
+			if err := checkReadable(datasets[name]); err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "dataset": name, "reason": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
+}
+
+// checkReadable returns an error describing why path cannot be read, if it
+// cannot.
+func checkReadable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("csv file is not accessible: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("csv path %s is a directory", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("csv file cannot be opened: %w", err)
+	}
+	return file.Close()
+}
+
+// datasetHealth is the per-dataset entry returned by HealthDetailsHandler.
+type datasetHealth struct {
+	Name     string    `json:"name"`
+	Modified time.Time `json:"modified"`
+	Rows     int       `json:"rows"`
+	Stale    bool      `json:"stale"`
+}
+
+// healthDetailsTTL is how long HealthDetailsHandler reuses a report. The
+// route is unauthenticated and counting rows reads every file in full, so
+// without it any client could make the server scan the datasets at will.
+const healthDetailsTTL = 10 * time.Second
+
+// HealthDetailsHandler reports the last-modified time and row count of every
+// dataset. When maxAge is positive, a dataset not modified within it is
+// marked stale and the overall status becomes "stale", still with 200, so a
+// data pipeline that stopped updating the file shows up without failing
+// probes. An unreadable dataset yields 503, as for ReadinessHandler. A report
+// is reused for healthDetailsTTL.
+func HealthDetailsHandler(registry *DatasetRegistry, maxAge time.Duration) gin.HandlerFunc {
+	return healthDetailsHandler(registry, maxAge, healthDetailsTTL)
+}
+
+func healthDetailsHandler(registry *DatasetRegistry, maxAge, ttl time.Duration) gin.HandlerFunc {
+	// mu is held while a report is made, so concurrent requests wait for it
+	// rather than all scanning the files.
+	var mu sync.Mutex
+	var report healthReport
+	var reportedAt time.Time
+
+	return func(c *gin.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if reportedAt.IsZero() || time.Since(reportedAt) >= ttl {
+			ctx := c.Request.Context()
+			fresh := healthDetails(ctx, registry, maxAge)
+			// A report cut short by a client that went away is not kept.
+			if ctx.Err() != nil {
+				c.JSON(fresh.code, fresh.body)
+				return
+			}
+			report, reportedAt = fresh, time.Now()
+		}
+		c.JSON(report.code, report.body)
+	}
+}
+
+// healthReport is a response of HealthDetailsHandler.
+type healthReport struct {
+	code int
+	body gin.H
+}
+
+// healthDetails stats and counts the rows of every dataset in registry.
+func healthDetails(ctx context.Context, registry *DatasetRegistry, maxAge time.Duration) healthReport {
+	datasets := registry.Snapshot()
+	status := "ok"
+	details := make([]datasetHealth, 0, len(datasets))
+	for _, name := range datasets.Names() {
+		path := datasets[name]
+		info, err := os.Stat(path)
+		if err != nil {
+			return healthReport{http.StatusServiceUnavailable, gin.H{"status": "unavailable", "dataset": name, "reason": err.Error()}}
+		}
+		rows, err := tools.CountDataRows(ctx, path, true)
+		if err != nil {
+			return healthReport{http.StatusServiceUnavailable, gin.H{"status": "unavailable", "dataset": name, "reason": err.Error()}}
+		}
+
+		health := datasetHealth{Name: name, Modified: info.ModTime().UTC(), Rows: rows}
+		if maxAge > 0 && time.Since(info.ModTime()) > maxAge {
+			health.Stale = true
+			status = "stale"
+		}
+		details = append(details, health)
+	}
+	return healthReport{http.StatusOK, gin.H{"status": status, "datasets": details}}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// writeFixture writes content to a file named name in a temporary directory
+// and returns its path.
+func writeFixture(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	return path
+}
+
+// newRegistry returns a registry of datasets without a directory.
+func newRegistry(t *testing.T, datasets Datasets) *DatasetRegistry {
+	t.Helper()
+	registry, err := NewDatasetRegistry(datasets, "")
+	if err != nil {
+		t.Fatalf("NewDatasetRegistry: %v", err)
+	}
+	return registry
+}
+
+func TestReadinessAndLiveness(t *testing.T) {
+	tests := []struct {
+		name      string
+		datasets  Datasets
+		wantReady int
+	}{
+		{name: "readable file", datasets: Datasets{DefaultDataset: writeFixture(t, "records.csv", "id\n1\n")}, wantReady: http.StatusOK},
+		{name: "missing file", datasets: Datasets{DefaultDataset: filepath.Join(t.TempDir(), "missing.csv")}, wantReady: http.StatusServiceUnavailable},
+		{name: "directory", datasets: Datasets{DefaultDataset: t.TempDir()}, wantReady: http.StatusServiceUnavailable},
+		{name: "no datasets", datasets: Datasets{}, wantReady: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/health", HealthHandler("abc123", "v1"))
+			router.GET("/ready", ReadinessHandler(newRegistry(t, tt.datasets)))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			if w.Code != tt.wantReady {
+				t.Errorf("/ready status = %d, want %d: %s", w.Code, tt.wantReady, w.Body)
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("/health status = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+// getHealthDetails serves one /health/details request with handler and
+// decodes the reply.
+func getHealthDetails(t *testing.T, handler gin.HandlerFunc) (int, healthDetailsReply) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/health/details", handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/details", nil))
+	var reply healthDetailsReply
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return w.Code, reply
+}
+
+type healthDetailsReply struct {
+	Status   string          `json:"status"`
+	Datasets []datasetHealth `json:"datasets"`
+}
+
+func TestHealthDetailsStale(t *testing.T) {
+	fresh := writeFixture(t, "fresh.csv", "id\n1\n2\n")
+	old := writeFixture(t, "old.csv", "id\n1\n")
+	modified := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, modified, modified); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		datasets   Datasets
+		maxAge     time.Duration
+		wantStatus string
+		wantStale  map[string]bool
+	}{
+		{name: "fresh", datasets: Datasets{"fresh": fresh}, maxAge: time.Hour, wantStatus: "ok", wantStale: map[string]bool{"fresh": false}},
+		{name: "one stale", datasets: Datasets{"fresh": fresh, "old": old}, maxAge: time.Hour, wantStatus: "stale", wantStale: map[string]bool{"fresh": false, "old": true}},
+		{name: "no max age", datasets: Datasets{"old": old}, wantStatus: "ok", wantStale: map[string]bool{"old": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reply := getHealthDetails(t, HealthDetailsHandler(newRegistry(t, tt.datasets), tt.maxAge))
+			if code != http.StatusOK {
+				t.Fatalf("status code = %d, want 200", code)
+			}
+			if reply.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", reply.Status, tt.wantStatus)
+			}
+			if len(reply.Datasets) != len(tt.wantStale) {
+				t.Fatalf("got %d datasets, want %d", len(reply.Datasets), len(tt.wantStale))
+			}
+			for _, d := range reply.Datasets {
+				if d.Stale != tt.wantStale[d.Name] {
+					t.Errorf("%s: stale = %v, want %v", d.Name, d.Stale, tt.wantStale[d.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestHealthDetailsCached(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		wantRows int
+	}{
+		{name: "within the TTL", ttl: time.Hour, wantRows: 1},
+		{name: "expired", ttl: 0, wantRows: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", "id\n1\n")
+			handler := healthDetailsHandler(newRegistry(t, Datasets{DefaultDataset: path}), 0, tt.ttl)
+
+			if _, reply := getHealthDetails(t, handler); reply.Datasets[0].Rows != 1 {
+				t.Fatalf("first rows = %d, want 1", reply.Datasets[0].Rows)
+			}
+			if err := os.WriteFile(path, []byte("id\n1\n2\n3\n"), 0o600); err != nil {
+				t.Fatalf("rewriting fixture: %v", err)
+			}
+			if _, reply := getHealthDetails(t, handler); reply.Datasets[0].Rows != tt.wantRows {
+				t.Errorf("second rows = %d, want %d", reply.Datasets[0].Rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+// IdempotencyCache remembers the responses of recent append_record calls by
+// idempotency key, so that a client retrying a call whose response it never
+// saw gets the original response instead of appending the record twice. It
+// keeps at most size keys, evicting the least recently used, and forgets a
+// key ttl after it was first stored.
+type IdempotencyCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type idempotencyEntry struct {
+	key     string
+	resp    *mcp.ToolResponse
+	expires time.Time
+}
+
+// NewIdempotencyCache returns an empty cache holding up to size keys for ttl.
+func NewIdempotencyCache(size int, ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// do returns the response stored for key if there is one, and otherwise
+// calls fn and stores its response unless it reports an error, so a failed
+// call can be retried. Calls with the same cache are serialised, which makes
+// a retry that races the original wait for it rather than append again.
+func (c *IdempotencyCache) do(key string, now time.Time, fn func() (*mcp.ToolResponse, error)) (*mcp.ToolResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*idempotencyEntry)
+		if now.Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			return entry.resp, nil
+		}
+		c.remove(elem)
+	}
+
+	resp, err := fn()
+	if err != nil || isErrorResponse(resp) {
+		return resp, err
+	}
+
+	c.entries[key] = c.order.PushFront(&idempotencyEntry{key: key, resp: resp, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return resp, nil
+}
+
+func (c *IdempotencyCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*idempotencyEntry).key)
+}
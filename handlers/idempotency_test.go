@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+func TestAppendRecordIdempotencyKey(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,name\n1,a\n")
+	cfg := Config{
+		Datasets:    newRegistry(t, Datasets{DefaultDataset: path}),
+		Writable:    true,
+		Idempotency: NewIdempotencyCache(10, time.Hour),
+	}
+
+	calls := []struct {
+		fields []string
+		key    string
+	}{
+		{fields: []string{"2", "b"}, key: "key-1"},
+		{fields: []string{"2", "b"}, key: "key-1"},
+		{fields: []string{"3", "c"}, key: "key-2"},
+		{fields: []string{"4", "d"}},
+		{fields: []string{"4", "d"}},
+	}
+	for _, call := range calls {
+		args := map[string]any{"fields": call.fields}
+		if call.key != "" {
+			args["idempotency_key"] = call.key
+		}
+		if text, isError := callTool(t, cfg, "append_record", args); isError || text != "Record appended." {
+			t.Fatalf("append_record %q with key %q = %q", call.fields, call.key, text)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,name\n1,a\n2,b\n3,c\n4,d\n4,d\n"; string(content) != want {
+		t.Errorf("file holds\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Now()
+	response := func(text string) func() (*mcp.ToolResponse, error) {
+		return func() (*mcp.ToolResponse, error) {
+			return mcp.NewToolResponse(mcp.NewTextContent(text)), nil
+		}
+	}
+	text := func(resp *mcp.ToolResponse) string { return resp.Content[0].TextContent.Text }
+
+	tests := []struct {
+		name string
+		run  func(c *IdempotencyCache) string
+		want string
+	}{
+		{
+			name: "repeated key",
+			run: func(c *IdempotencyCache) string {
+				c.do("a", now, response("first"))
+				resp, _ := c.do("a", now.Add(time.Minute), response("second"))
+				return text(resp)
+			},
+			want: "first",
+		},
+		{
+			name: "expired key",
+			run: func(c *IdempotencyCache) string {
+				c.do("a", now, response("first"))
+				resp, _ := c.do("a", now.Add(2*time.Hour), response("second"))
+				return text(resp)
+			},
+			want: "second",
+		},
+		{
+			name: "evicted key",
+			run: func(c *IdempotencyCache) string {
+				c.do("a", now, response("first"))
+				c.do("b", now, response("b"))
+				c.do("c", now, response("c"))
+				resp, _ := c.do("a", now, response("second"))
+				return text(resp)
+			},
+			want: "second",
+		},
+		{
+			name: "recently used key is kept",
+			run: func(c *IdempotencyCache) string {
+				c.do("a", now, response("first"))
+				c.do("b", now, response("b"))
+				c.do("a", now, response("unused"))
+				c.do("c", now, response("c"))
+				resp, _ := c.do("a", now, response("second"))
+				return text(resp)
+			},
+			want: "first",
+		},
+		{
+			name: "failed call is not stored",
+			run: func(c *IdempotencyCache) string {
+				c.do("a", now, func() (*mcp.ToolResponse, error) { return errorResponse("disk full") })
+				resp, _ := c.do("a", now, response("retried"))
+				return text(resp)
+			},
+			want: "retried",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.run(NewIdempotencyCache(2, time.Hour)); got != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,60 +1,320 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"log/slog"
+	"reflect"
+	"runtime/debug"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/middleware"
+	"github.com/korjavin/claude_connector/tools"
 	mcp "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport"
 	"github.com/metoro-io/mcp-golang/transport/http"
-	"github.com/korjavin/claude_connector/tools"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tail strategies selectable through Config.TailStrategy.
+const (
+	TailStrategyScan = "scan"
+	TailStrategySeek = "seek"
 )
 
-type GetLastNRecordsArgs struct {
-	Count int `json:"count" jsonschema:"required,description=The number of recent records to retrieve."`
-}
-
-func MCPHandler(csvPath string) gin.HandlerFunc {
-	transport := http.NewGinTransport()
-	server := mcp.NewServer(transport)
-
-	err := server.RegisterTool(
-		"get_last_n_records",
-		"Retrieves the last N records from the local medical information CSV file.",
-		func(args GetLastNRecordsArgs) (*mcp.ToolResponse, error) {
-			if args.Count <= 0 {
-				return mcp.NewToolResponse(mcp.NewTextContent("Error: count must be a positive integer.")), nil
-			}
-
-			records, err := tools.GetLastNRecords(csvPath, args.Count)
-			if err != nil {
-				return mcp.NewToolResponse(mcp.NewTextContent(fmt.Sprintf("Error: failed to get records: %v", err))), nil
-			}
-
-			if len(records) == 0 {
-				return mcp.NewToolResponse(mcp.NewTextContent("No records found.")), nil
-			}
-
-			var b strings.Builder
-			for i, record := range records {
-				for j, value := range record {
-					b.WriteString(value)
-					if j < len(record)-1 {
-						b.WriteString(",")
-					}
-				}
-				if i < len(records)-1 {
-					b.WriteString("\n")
-				}
-			}
-
-			return mcp.NewToolResponse(mcp.NewTextContent(b.String())), nil
-		},
-	)
+// Config selects the datasets the MCP tools serve and how they read them.
+type Config struct {
+	// ServerName and ServerVersion identify the server to clients during the
+	// MCP handshake.
+	ServerName    string
+	ServerVersion string
+
+	// Datasets maps the names accepted by the dataset argument to CSV files.
+	Datasets *DatasetRegistry
+
+	// TailStrategy selects how get_last_n_records finds the end of the file:
+	// TailStrategyScan streams the whole file, TailStrategySeek reads
+	// backwards from the end.
+	TailStrategy string
+
+	// Strict makes get_last_n_records fail on the first malformed row instead
+	// of skipping it.
+	Strict bool
+
+	// Writable registers the append_record tool. When it is false the tools
+	// never modify the CSV files.
+	Writable bool
+
+	// WriteScope is the scope a token must grant to call append_record. An
+	// empty WriteScope only requires the scope needed by the endpoint itself.
+	WriteScope string
+
+	// MaxRecords caps the number of records any tool returns. Zero means no
+	// cap.
+	MaxRecords int
+
+	// DefaultCount is the number of records get_last_n_records and
+	// get_first_n_records return when the count is omitted.
+	DefaultCount int
+
+	// ToolTimeout bounds how long a tool call may run before it is abandoned
+	// with an error. Zero means no bound.
+	ToolTimeout time.Duration
+
+	// Idempotency, when set, remembers the responses of append_record calls
+	// made with an idempotency key.
+	Idempotency *IdempotencyCache
+
+	// Audit, when set, receives an entry for every tool invocation.
+	Audit *AuditLogger
+}
+
+// tailFunc returns the TailFunc selected by cfg. In lenient mode the number
+// of malformed rows skipped by each call is stored in skipped.
+func (cfg Config) tailFunc(skipped *int) tools.TailFunc {
+	if cfg.Strict {
+		if cfg.TailStrategy == TailStrategySeek {
+			return tools.GetLastNRecordsSeek
+		}
+		return tools.GetLastNRecords
+	}
+
+	lenient := tools.GetLastNRecordsLenient
+	if cfg.TailStrategy == TailStrategySeek {
+		lenient = tools.GetLastNRecordsSeekLenient
+	}
+	return func(ctx context.Context, filePath string, n int) ([][]string, error) {
+		records, count, err := lenient(ctx, filePath, n)
+		*skipped = count
+		return records, err
+	}
+}
+
+// MCPHandler serves the MCP protocol over stateless HTTP: every POST carries
+// one JSON-RPC message and its response is the reply. A tool call is
+// cancelled when its request is, such as when the client disconnects.
+func MCPHandler(cfg Config) gin.HandlerFunc {
+	tr := http.NewGinTransport()
+	if err := newServer(tr, cfg); err != nil {
+		panic(fmt.Sprintf("Failed to start MCP server: %v", err))
+	}
+	handler := tr.Handler()
+	return func(c *gin.Context) {
+		c.Set(clientDoneKey, c.Request.Context().Done())
+		handler(c)
+	}
+}
+
+// clientDoneKey is the gin context key of a channel that is closed once
+// nobody is waiting for the reply to a tool call any more. The HTTP
+// transports set it, since they do not derive the tool context from it.
+const clientDoneKey = "clientDone"
+
+// newServer creates an MCP server on transport with every tool registered and
+// starts serving.
+func newServer(tr transport.Transport, cfg Config) error {
+	server := mcp.NewServer(tr, mcp.WithName(cfg.ServerName), mcp.WithVersion(cfg.ServerVersion))
+	if err := RegisterTools(server, cfg); err != nil {
+		return err
+	}
+	return server.Serve()
+}
+
+// toolInvocations counts tool calls by tool name and result.
+var toolInvocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "mcp_tool_invocations_total",
+	Help: "Number of MCP tool invocations, by tool and result.",
+}, []string{"tool", "result"})
+
+// MetricsCollectors returns the Prometheus collectors the MCP tools record
+// into, for registration by the caller.
+func MetricsCollectors() []prometheus.Collector {
+	return []prometheus.Collector{toolInvocations}
+}
+
+// toolRegistrar registers tools on a server, keeping the first failure so that
+// a run of registrations only needs checking once. It also records each tool
+// for the catalog; with a nil server it only records them.
+type toolRegistrar struct {
+	server  *mcp.Server
+	audit   *AuditLogger
+	timeout time.Duration
+	tools   []toolInfo
+	err     error
+}
+
+// registerTool registers handler as the named tool, logging the subject of the
+// token that invoked it, counting the outcome and writing it to the audit log
+// when one is configured. A call still running after the registrar's timeout
+// is cancelled and reported as an error, as is one that panics. Registration
+// only fails when the handler signature is invalid.
+func registerTool[T any](r *toolRegistrar, name, description string, handler func(context.Context, T) (*mcp.ToolResponse, error)) {
+	if r.err != nil {
+		return
+	}
+	r.tools = append(r.tools, toolInfo{
+		Name:        name,
+		Description: description,
+		InputSchema: schemaReflector.ReflectFromType(reflect.TypeFor[T]()),
+	})
+	if r.server == nil {
+		return
+	}
+	audit, timeout := r.audit, r.timeout
+	err := r.server.RegisterTool(name, description, func(ctx context.Context, args T) (*mcp.ToolResponse, error) {
+		slog.Info("tool invoked",
+			slog.String("tool", name),
+			slog.String("subject", callerSubject(ctx)),
+			slog.String("request_id", callerRequestID(ctx)),
+		)
+
+		ctx, span := tracer.Start(withRequestSpan(ctx), "tool "+name, trace.WithAttributes(attribute.String("mcp.tool", name)))
+		defer span.End()
+
+		ctx, cancel := withClientCancel(ctx)
+		defer cancel()
 
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		var rows int
+		ctx = context.WithValue(ctx, auditRowsKey{}, &rows)
+		resp, err := callHandler(ctx, name, handler, args)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			resp, err = errorResponse("tool call timed out after %s.", timeout)
+		}
+		result := "success"
+		if err != nil || isErrorResponse(resp) {
+			result = "error"
+			span.SetStatus(codes.Error, "tool call failed")
+		}
+		span.SetAttributes(attribute.Int("mcp.rows", rows))
+		toolInvocations.WithLabelValues(name, result).Inc()
+		if audit != nil {
+			audit.log(auditEntry{
+				Time:      time.Now().UTC(),
+				Subject:   callerSubject(ctx),
+				RequestID: callerRequestID(ctx),
+				Tool:      name,
+				Arguments: auditArguments(args),
+				Rows:      rows,
+				Result:    result,
+			})
+		}
+		return resp, err
+	})
 	if err != nil {
-		panic(fmt.Sprintf("Failed to register tool: %v", err))
+		r.err = fmt.Errorf("failed to register tool %s: %w", name, err)
+	}
+}
+
+// callHandler calls the handler of the named tool, turning a panic into an
+// error response. Without this a panicking tool would take down the whole
+// stdio server, which has no recovery middleware in front of it.
+func callHandler[T any](ctx context.Context, name string, handler func(context.Context, T) (*mcp.ToolResponse, error), args T) (resp *mcp.ToolResponse, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		slog.Error("panic in tool",
+			slog.String("tool", name),
+			slog.String("request_id", callerRequestID(ctx)),
+			slog.String("panic", fmt.Sprint(recovered)),
+			slog.String("stack", string(debug.Stack())),
+		)
+		resp, err = errorResponse("internal error in %s.", name)
+	}()
+	return handler(ctx, args)
+}
+
+// tracer records a span for every tool call. Until a tracer provider is
+// installed with otel.SetTracerProvider its spans are no-ops.
+var tracer = otel.Tracer("github.com/korjavin/claude_connector/handlers")
+
+// withRequestSpan returns ctx with the span of the HTTP request behind a tool
+// call, if there is one, so the tool span joins the request's trace. The HTTP
+// transport does not derive the tool context from the request context.
+func withRequestSpan(ctx context.Context) context.Context {
+	c, ok := ginContext(ctx)
+	if !ok {
+		return ctx
 	}
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(c.Request.Context()))
+}
 
-	return transport.Handler()
+// withClientCancel returns a copy of ctx that is cancelled when the client
+// behind a tool call goes away, if the transport reports that.
+func withClientCancel(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	c, ok := ginContext(ctx)
+	if !ok {
+		return ctx, cancel
+	}
+	done, ok := c.Value(clientDoneKey).(<-chan struct{})
+	if !ok {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// ginContext returns the gin context the HTTP transport attaches to every
+// tool call, if there is one.
+func ginContext(ctx context.Context) (*gin.Context, bool) {
+	c, ok := ctx.Value("ginContext").(*gin.Context)
+	return c, ok
+}
+
+// callerSubject returns the sub claim of the token behind a tool call, or
+// "unknown" when the call is not authenticated.
+func callerSubject(ctx context.Context) string {
+	c, ok := ginContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return "unknown"
+	}
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "unknown"
+	}
+	return sub
+}
+
+// callerHasScope reports whether the token behind a tool call grants scope.
+func callerHasScope(ctx context.Context, scope string) bool {
+	c, ok := ginContext(ctx)
+	if !ok {
+		return false
+	}
+	claims, ok := middleware.ClaimsFromContext(c)
+	return ok && middleware.HasScope(claims, scope)
+}
+
+// callerRequestID returns the request ID of the HTTP request behind a tool
+// call, or an empty string when there is none.
+func callerRequestID(ctx context.Context) string {
+	c, ok := ginContext(ctx)
+	if !ok {
+		return ""
+	}
+	return middleware.RequestIDFromContext(c)
 }
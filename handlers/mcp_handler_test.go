@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	mcp "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport/http"
+)
+
+// explodeArgs are the arguments of the panicking tool in TestToolPanic.
+type explodeArgs struct {
+	Explode bool `json:"explode"`
+}
+
+func TestToolPanic(t *testing.T) {
+	var logs bytes.Buffer
+	saved := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(saved) })
+
+	tr := http.NewGinTransport()
+	r := &toolRegistrar{server: mcp.NewServer(tr)}
+	registerTool(r, "explode", "Panics when asked to.", func(ctx context.Context, args explodeArgs) (*mcp.ToolResponse, error) {
+		if args.Explode {
+			var records [][]string
+			_ = records[3]
+		}
+		return mcp.NewToolResponse(mcp.NewTextContent("fine")), nil
+	})
+	if r.err != nil {
+		t.Fatalf("registerTool: %v", r.err)
+	}
+	if err := r.server.Serve(); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	handler := tr.Handler()
+
+	text, isError := callToolOn(t, handler, "explode", explodeArgs{Explode: true})
+	if !isError || !strings.Contains(text, "internal error in explode") {
+		t.Errorf("response to a panicking call = %q, want an internal error", text)
+	}
+	if strings.Contains(text, "index out of range") {
+		t.Errorf("response %q leaks the panic value", text)
+	}
+	if !strings.Contains(logs.String(), "panic in tool") || !strings.Contains(logs.String(), "index out of range") {
+		t.Errorf("the panic was not logged with its value: %s", logs.String())
+	}
+
+	// The server keeps serving after a panic.
+	if text, isError := callToolOn(t, handler, "explode", explodeArgs{}); isError || text != "fine" {
+		t.Errorf("call after a panic = %q, want fine", text)
+	}
+}
+
+// slowArgs are the arguments of the slow tool in TestToolTimeout.
+type slowArgs struct {
+	Delay string `json:"delay"`
+}
+
+func TestToolTimeout(t *testing.T) {
+	tr := http.NewGinTransport()
+	r := &toolRegistrar{server: mcp.NewServer(tr), timeout: 50 * time.Millisecond}
+	registerTool(r, "slow", "Sleeps for delay unless cancelled first.", func(ctx context.Context, args slowArgs) (*mcp.ToolResponse, error) {
+		delay, err := time.ParseDuration(args.Delay)
+		if err != nil {
+			return errorResponse("%v", err)
+		}
+		select {
+		case <-time.After(delay):
+			return mcp.NewToolResponse(mcp.NewTextContent("done")), nil
+		case <-ctx.Done():
+			return errorResponse("failed to read records: %v", ctx.Err())
+		}
+	})
+	if r.err != nil {
+		t.Fatalf("registerTool: %v", r.err)
+	}
+	if err := r.server.Serve(); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	handler := tr.Handler()
+
+	tests := []struct {
+		delay   string
+		want    string
+		wantErr bool
+	}{
+		{delay: "1ms", want: "done"},
+		{delay: "10s", want: errorPrefix + "tool call timed out after 50ms.", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.delay, func(t *testing.T) {
+			start := time.Now()
+			text, isError := callToolOn(t, handler, "slow", slowArgs{Delay: tt.delay})
+			if text != tt.want || isError != tt.wantErr {
+				t.Errorf("response = %q, want %q", text, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("call took %s despite the timeout", elapsed)
+			}
+		})
+	}
+}
+
+// readOnlyTools are the names of the tools registered without Writable.
+var readOnlyTools = []string{
+	"get_last_n_records", "get_first_n_records", "sample_records", "get_records_page",
+	"get_tail_range", "get_records_since", "get_records_where", "query_records",
+	"get_record_by_id", "get_records_between", "search_records", "export_jsonl",
+	"describe_schema", "get_header", "validate_csv", "count_records",
+	"column_completeness", "column_stats", "distinct_values", "group_count",
+	"column_histogram", "diff_datasets",
+}
+
+func TestToolsList(t *testing.T) {
+	tests := []struct {
+		name     string
+		writable bool
+		want     []string
+	}{
+		{name: "read only", want: readOnlyTools},
+		{name: "writable", writable: true, want: append(append([]string{}, readOnlyTools...), "append_record")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := toolConfig(t, "id,name\n1,a\n")
+			cfg.Writable = tt.writable
+
+			var result struct {
+				Tools []struct {
+					Name        string         `json:"name"`
+					Description string         `json:"description"`
+					InputSchema map[string]any `json:"inputSchema"`
+				} `json:"tools"`
+			}
+			callMethodOn(t, MCPHandler(cfg), "tools/list", map[string]any{}, &result)
+
+			var names []string
+			for _, tool := range result.Tools {
+				names = append(names, tool.Name)
+				if tool.Description == "" || tool.InputSchema == nil {
+					t.Errorf("tool %s has no description or input schema", tool.Name)
+				}
+			}
+			sort.Strings(names)
+			want := append([]string{}, tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("tools = %q, want %q", names, want)
+			}
+		})
+	}
+}
+
+func TestInitializeServerInfo(t *testing.T) {
+	cfg := toolConfig(t, "id,name\n1,a\n")
+	cfg.ServerName = "csv-connector"
+	cfg.ServerVersion = "abc1234"
+
+	var result struct {
+		ServerInfo struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	callMethodOn(t, MCPHandler(cfg), "initialize", map[string]any{
+		"protocolVersion": "2024-11-05",
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+	}, &result)
+	if result.ServerInfo.Name != "csv-connector" || result.ServerInfo.Version != "abc1234" {
+		t.Errorf("serverInfo = %+v, want csv-connector abc1234", result.ServerInfo)
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/korjavin/claude_connector/tools"
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+// Output formats accepted by the format argument of record-returning tools.
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// recordSet is the JSON shape returned by tools that produce records. Columns
+// preserves the header order, which is lost in the per-record maps.
+type recordSet struct {
+	Columns    []string            `json:"columns"`
+	Records    []map[string]string `json:"records"`
+	Matched    *int                `json:"matched,omitempty"`
+	Page       *pageInfo           `json:"page,omitempty"`
+	Cursor     *cursorInfo         `json:"cursor,omitempty"`
+	Metadata   *recordTotals       `json:"metadata,omitempty"`
+	Notes      []string            `json:"notes,omitempty"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// recordTotals reports how many of the records in a dataset were returned.
+type recordTotals struct {
+	Total    int `json:"total"`
+	Returned int `json:"returned"`
+}
+
+// pageInfo describes where a page of records sits in the whole dataset.
+type pageInfo struct {
+	Offset   int  `json:"offset"`
+	Returned int  `json:"returned"`
+	Total    int  `json:"total"`
+	HasMore  bool `json:"has_more"`
+}
+
+// String renders the page metadata as a note for non-JSON output.
+func (p pageInfo) String() string {
+	more := "no more records"
+	if p.HasMore {
+		more = "more records available"
+	}
+	return fmt.Sprintf("offset %d, %d of %d records returned, %s", p.Offset, p.Returned, p.Total, more)
+}
+
+// cursorInfo tells a polling client where to resume reading. LastSeenLine is
+// the number of the last data row returned, to be passed back as
+// last_seen_line.
+type cursorInfo struct {
+	LastSeenLine int  `json:"last_seen_line"`
+	Reset        bool `json:"reset"`
+	HasMore      bool `json:"has_more"`
+}
+
+// String renders the cursor as a note for non-JSON output.
+func (c cursorInfo) String() string {
+	note := fmt.Sprintf("last_seen_line %d", c.LastSeenLine)
+	if c.Reset {
+		note += ", file shrank so reading restarted from the first record"
+	}
+	if c.HasMore {
+		note += ", more records available"
+	}
+	return note
+}
+
+// recordExtras is optional information returned alongside records.
+type recordExtras struct {
+	// Matched is the number of matching records in the whole dataset, which
+	// may exceed the number returned.
+	Matched *int
+	Page    *pageInfo
+	Cursor  *cursorInfo
+	Totals  *recordTotals
+	Notes   []string
+	// NextCursor continues a chunked result after these records.
+	NextCursor string
+}
+
+// recordCount is the JSON shape returned by count_records. Matched is only
+// present when a filter was given.
+type recordCount struct {
+	Total   int  `json:"total"`
+	Matched *int `json:"matched,omitempty"`
+}
+
+// headerColumns is the JSON shape returned by get_header.
+type headerColumns struct {
+	Columns []string `json:"columns"`
+}
+
+// distinctValues is the JSON shape returned by distinct_values. Distinct is the
+// number of distinct values in the column, which exceeds len(Values) when the
+// list was truncated to the limit.
+type distinctValues struct {
+	Column    string             `json:"column"`
+	Values    []tools.ValueCount `json:"values"`
+	Distinct  int                `json:"distinct"`
+	Truncated bool               `json:"truncated"`
+}
+
+// groupCounts is the JSON shape returned by group_count. Groups is the number
+// of distinct values in the grouping column, which exceeds len(Values) when
+// the list was truncated to the limit.
+type groupCounts struct {
+	GroupBy   string        `json:"group_by"`
+	Metric    string        `json:"metric,omitempty"`
+	Values    []tools.Group `json:"values"`
+	Groups    int           `json:"groups"`
+	Truncated bool          `json:"truncated"`
+}
+
+// validateFormat checks the format argument of a record-returning tool.
+func validateFormat(format string) error {
+	switch format {
+	case "", FormatJSON, FormatCSV, FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("format must be %q, %q, %q or %q, got %q", FormatJSON, FormatCSV, FormatMarkdown, FormatHTML, format)
+}
+
+// recordsResponse renders records in the requested format. JSON output is an
+// array of objects keyed by column name; CSV output repeats the header row and
+// uses the configured delimiter; Markdown and HTML output are tables. For the
+// formats other than JSON any extras are appended as notes after the records,
+// HTML-escaped in HTML output.
+func recordsResponse(format string, header []string, records [][]string, extras recordExtras) (*mcp.ToolResponse, error) {
+	if format != FormatCSV && format != FormatMarkdown && format != FormatHTML {
+		return jsonResponse(recordSet{
+			Columns:    header,
+			Records:    tools.RecordsToMaps(header, records),
+			Matched:    extras.Matched,
+			Page:       extras.Page,
+			Cursor:     extras.Cursor,
+			Metadata:   extras.Totals,
+			Notes:      extras.Notes,
+			NextCursor: extras.NextCursor,
+		})
+	}
+
+	notes := extras.Notes
+	if extras.Page != nil {
+		notes = append([]string{extras.Page.String()}, notes...)
+	}
+	if extras.Cursor != nil {
+		notes = append([]string{extras.Cursor.String()}, notes...)
+	}
+	if extras.NextCursor != "" {
+		notes = append(notes, fmt.Sprintf("more records available; pass cursor %q to continue", extras.NextCursor))
+	}
+	if extras.Totals != nil {
+		notes = append([]string{fmt.Sprintf("%d of %d records returned", extras.Totals.Returned, extras.Totals.Total)}, notes...)
+	}
+	if extras.Matched != nil {
+		notes = append([]string{fmt.Sprintf("%d of %d matching records returned", len(records), *extras.Matched)}, notes...)
+	}
+
+	var b strings.Builder
+	switch format {
+	case FormatMarkdown:
+		b.WriteString(tools.MarkdownTable(header, records))
+		b.WriteString("\n")
+	case FormatHTML:
+		b.WriteString(tools.HTMLTable(header, records))
+		b.WriteString("\n")
+	default:
+		w := csv.NewWriter(&b)
+		w.Comma = tools.Delimiter()
+		if err := w.Write(header); err != nil {
+			return errorResponse("failed to encode response: %v", err)
+		}
+		if err := w.WriteAll(records); err != nil {
+			return errorResponse("failed to encode response: %v", err)
+		}
+	}
+	for _, note := range notes {
+		if format == FormatHTML {
+			note = html.EscapeString(note)
+		}
+		fmt.Fprintf(&b, "\n(%s)", note)
+	}
+
+	return mcp.NewToolResponse(mcp.NewTextContent(strings.TrimSuffix(b.String(), "\n"))), nil
+}
+
+// noRecordsResponse reports that a tool found no records, saying so
+// explicitly when the file is empty or has only a header, which usually means
+// a misconfiguration or data that has not arrived yet rather than a filter
+// that matched nothing. Otherwise message is returned.
+func noRecordsResponse(ctx context.Context, csvPath, message string) (*mcp.ToolResponse, error) {
+	if err := tools.CheckData(ctx, csvPath); errors.Is(err, tools.ErrEmptyFile) || errors.Is(err, tools.ErrNoDataRows) {
+		message = fmt.Sprintf("No records found: %v.", err)
+	}
+	return mcp.NewToolResponse(mcp.NewTextContent(message)), nil
+}
+
+// errorPrefix starts the text of every tool response that reports a failure.
+const errorPrefix = "Error: "
+
+// errorResponse returns a tool response reporting a failure. Tools report
+// failures as text rather than as Go errors so the model can read them.
+func errorResponse(format string, args ...any) (*mcp.ToolResponse, error) {
+	return mcp.NewToolResponse(mcp.NewTextContent(errorPrefix + fmt.Sprintf(format, args...))), nil
+}
+
+// isErrorResponse reports whether resp was built by errorResponse.
+func isErrorResponse(resp *mcp.ToolResponse) bool {
+	if resp == nil || len(resp.Content) == 0 || resp.Content[0].TextContent == nil {
+		return false
+	}
+	return strings.HasPrefix(resp.Content[0].TextContent.Text, errorPrefix)
+}
+
+// jsonResponse marshals v into a text tool response.
+func jsonResponse(v any) (*mcp.ToolResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse("failed to encode response: %v", err)
+	}
+	return mcp.NewToolResponse(mcp.NewTextContent(string(body))), nil
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/middleware"
+	"github.com/metoro-io/mcp-golang/transport"
+)
+
+// sseKeepAlive is how often an idle event stream receives a comment line, so
+// that proxies do not close it.
+const sseKeepAlive = 30 * time.Second
+
+// SSEServer serves the MCP protocol over Server-Sent Events. A client opens a
+// long-lived event stream, is told where to POST its messages, and receives
+// every response as an event on the stream. Each stream is its own session
+// with its own MCP server.
+type SSEServer struct {
+	cfg         Config
+	messagePath string
+
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+	closed   bool
+}
+
+// NewSSEServer creates an SSEServer whose clients POST their messages to
+// messagePath, which must be routed to MessageHandler.
+func NewSSEServer(cfg Config, messagePath string) *SSEServer {
+	return &SSEServer{
+		cfg:         cfg,
+		messagePath: messagePath,
+		sessions:    make(map[string]*sseSession),
+	}
+}
+
+// StreamHandler opens the event stream of a new session. The first event,
+// "endpoint", carries the URL to POST messages to; later "message" events
+// carry the JSON-RPC responses.
+func (s *SSEServer) StreamHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := newSSESession(callerSubjectOf(c))
+		if err := newServer(session, s.cfg); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
+		if !s.add(session) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+			return
+		}
+		defer s.remove(session)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+
+		writeEvent(c, "endpoint", []byte(s.messagePath+"?session_id="+session.id))
+
+		ticker := time.NewTicker(sseKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case data := <-session.events:
+				writeEvent(c, "message", data)
+			case <-ticker.C:
+				fmt.Fprint(c.Writer, ": ping\n\n")
+				c.Writer.Flush()
+			case <-session.done:
+				return
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// MessageHandler accepts a JSON-RPC message for the session named by the
+// session_id query parameter. The message is answered on the session's event
+// stream, so the POST itself only gets 202 Accepted.
+func (s *SSEServer) MessageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := s.get(c.Query("session_id"))
+		if session == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown session"})
+			return
+		}
+		if session.subject != callerSubjectOf(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Session belongs to another user"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			return
+		}
+		message, err := decodeMessage(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Tool calls run after this request has completed, so they get a copy
+		// of the gin context that stays valid, and are cancelled when the
+		// event stream their reply is sent on closes rather than with it.
+		copied := c.Copy()
+		copied.Set(clientDoneKey, (<-chan struct{})(session.done))
+		ctx := context.WithValue(context.Background(), "ginContext", copied)
+		session.handle(ctx, message)
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// Close ends every open event stream and refuses new ones. It is meant to be
+// registered with http.Server.RegisterOnShutdown, since open streams would
+// otherwise hold up a graceful shutdown.
+func (s *SSEServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, session := range s.sessions {
+		session.Close()
+	}
+}
+
+func (s *SSEServer) add(session *sseSession) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.sessions[session.id] = session
+	return true
+}
+
+func (s *SSEServer) get(id string) *sseSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+func (s *SSEServer) remove(session *sseSession) {
+	s.mu.Lock()
+	delete(s.sessions, session.id)
+	s.mu.Unlock()
+	session.Close()
+}
+
+// writeEvent writes one server-sent event and flushes it to the client.
+func writeEvent(c *gin.Context, event string, data []byte) {
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, data)
+	c.Writer.Flush()
+}
+
+// callerSubjectOf returns the sub claim of the token behind an HTTP request,
+// or an empty string when there is none.
+func callerSubjectOf(c *gin.Context) string {
+	claims, ok := middleware.ClaimsFromContext(c)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// decodeMessage parses a JSON-RPC request, notification, response or error.
+func decodeMessage(body []byte) (*transport.BaseJsonRpcMessage, error) {
+	var request transport.BaseJSONRPCRequest
+	if err := json.Unmarshal(body, &request); err == nil {
+		return transport.NewBaseMessageRequest(&request), nil
+	}
+	var notification transport.BaseJSONRPCNotification
+	if err := json.Unmarshal(body, &notification); err == nil {
+		return transport.NewBaseMessageNotification(&notification), nil
+	}
+	var response transport.BaseJSONRPCResponse
+	if err := json.Unmarshal(body, &response); err == nil {
+		return transport.NewBaseMessageResponse(&response), nil
+	}
+	var rpcError transport.BaseJSONRPCError
+	if err := json.Unmarshal(body, &rpcError); err == nil {
+		return transport.NewBaseMessageError(&rpcError), nil
+	}
+	return nil, errors.New("body is not a JSON-RPC message")
+}
+
+// errSessionClosed is returned by Send once the event stream has ended.
+var errSessionClosed = errors.New("sse session closed")
+
+// sseSession is the transport.Transport of one event stream.
+type sseSession struct {
+	id      string
+	subject string
+	events  chan []byte
+	done    chan struct{}
+	once    sync.Once
+
+	mu             sync.RWMutex
+	messageHandler func(ctx context.Context, message *transport.BaseJsonRpcMessage)
+	errorHandler   func(error)
+	closeHandler   func()
+}
+
+func newSSESession(subject string) *sseSession {
+	return &sseSession{
+		id:      newSessionID(),
+		subject: subject,
+		events:  make(chan []byte, 16),
+		done:    make(chan struct{}),
+	}
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate session ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Start implements transport.Transport. The stream is already open by the
+// time the server starts, so there is nothing to do.
+func (t *sseSession) Start(ctx context.Context) error {
+	return nil
+}
+
+// Send implements transport.Transport by queueing message for the stream.
+func (t *sseSession) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	select {
+	case t.events <- data:
+		return nil
+	case <-t.done:
+		return errSessionClosed
+	}
+}
+
+// Close implements transport.Transport. It ends the event stream.
+func (t *sseSession) Close() error {
+	t.once.Do(func() {
+		close(t.done)
+		t.mu.RLock()
+		handler := t.closeHandler
+		t.mu.RUnlock()
+		if handler != nil {
+			handler()
+		}
+	})
+	return nil
+}
+
+// SetCloseHandler implements transport.Transport.
+func (t *sseSession) SetCloseHandler(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeHandler = handler
+}
+
+// SetErrorHandler implements transport.Transport.
+func (t *sseSession) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorHandler = handler
+}
+
+// SetMessageHandler implements transport.Transport.
+func (t *sseSession) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messageHandler = handler
+}
+
+// handle passes a message received over POST to the MCP server.
+func (t *sseSession) handle(ctx context.Context, message *transport.BaseJsonRpcMessage) {
+	t.mu.RLock()
+	handler := t.messageHandler
+	t.mu.RUnlock()
+	if handler != nil {
+		handler(ctx, message)
+	}
+}
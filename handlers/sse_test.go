@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseEvent is one event read from an event stream.
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents sends the events read from body to the returned channel until
+// the stream ends, when the channel is closed.
+func readEvents(body *bufio.Reader) <-chan sseEvent {
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		var event sseEvent
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.name != "":
+				events <- event
+				event = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+// nextEvent returns the next event from events, failing the test if none
+// arrives in time.
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("event stream ended")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event arrived")
+	}
+	return sseEvent{}
+}
+
+// newSSETestServer serves an SSEServer for cfg under /mcp.
+func newSSETestServer(t *testing.T, cfg Config) (*httptest.Server, *SSEServer) {
+	t.Helper()
+	sse := NewSSEServer(cfg, "/mcp/messages")
+	router := gin.New()
+	router.GET("/mcp/sse", sse.StreamHandler())
+	router.POST("/mcp/messages", sse.MessageHandler())
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		sse.Close()
+		server.Close()
+	})
+	return server, sse
+}
+
+func TestSSEToolsList(t *testing.T) {
+	server, _ := newSSETestServer(t, toolConfig(t, "id,name\n1,a\n"))
+
+	resp, err := http.Get(server.URL + "/mcp/sse")
+	if err != nil {
+		t.Fatalf("opening stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("stream content type = %q", ct)
+	}
+	events := readEvents(bufio.NewReader(resp.Body))
+
+	endpoint := nextEvent(t, events)
+	if endpoint.name != "endpoint" || !strings.HasPrefix(endpoint.data, "/mcp/messages?session_id=") {
+		t.Fatalf("first event = %+v, want the message endpoint", endpoint)
+	}
+
+	post, err := http.Post(server.URL+endpoint.data, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"tools/list","params":{}}`))
+	if err != nil {
+		t.Fatalf("posting message: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("message POST answered %d, want 202", post.StatusCode)
+	}
+
+	reply := nextEvent(t, events)
+	if reply.name != "message" {
+		t.Fatalf("reply event = %+v, want a message", reply)
+	}
+	var message struct {
+		ID     int `json:"id"`
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(reply.data), &message); err != nil {
+		t.Fatalf("decoding reply %s: %v", reply.data, err)
+	}
+	if message.ID != 7 || len(message.Result.Tools) == 0 {
+		t.Errorf("reply = %s, want the tool list for request 7", reply.data)
+	}
+}
+
+func TestSSEMessageErrors(t *testing.T) {
+	server, sse := newSSETestServer(t, toolConfig(t, "id,name\n1,a\n"))
+
+	resp, err := http.Post(server.URL+"/mcp/messages?session_id=unknown", "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatalf("posting message: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("message for an unknown session answered %d, want 404", resp.StatusCode)
+	}
+
+	stream, err := http.Get(server.URL + "/mcp/sse")
+	if err != nil {
+		t.Fatalf("opening stream: %v", err)
+	}
+	defer stream.Body.Close()
+	events := readEvents(bufio.NewReader(stream.Body))
+	endpoint := nextEvent(t, events)
+
+	resp, err = http.Post(server.URL+endpoint.data, "application/json", strings.NewReader("not json"))
+	if err != nil {
+		t.Fatalf("posting message: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed message answered %d, want 400", resp.StatusCode)
+	}
+
+	// Closing the server ends open streams.
+	sse.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("stream sent another event after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream stayed open after Close")
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/metoro-io/mcp-golang/transport"
+	"github.com/metoro-io/mcp-golang/transport/stdio"
+)
+
+// ServeStdio serves the MCP protocol over newline-delimited JSON-RPC read
+// from in and written to out, for hosts that run the connector as a
+// subprocess. It returns once in reaches EOF and every request read so far
+// has been answered, or when ctx is cancelled.
+func ServeStdio(ctx context.Context, cfg Config, in io.Reader, out io.Writer) error {
+	input := &eofReader{Reader: in, eof: make(chan struct{})}
+	tr := &stdioTransport{StdioServerTransport: stdio.NewStdioServerTransportWithIO(input, out)}
+	if err := newServer(tr, cfg); err != nil {
+		return err
+	}
+	defer tr.Close()
+
+	select {
+	case <-input.eof:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	answered := make(chan struct{})
+	go func() {
+		tr.pending.Wait()
+		close(answered)
+	}()
+	select {
+	case <-answered:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stdioTransport tracks the requests the stdio transport has received but not
+// yet answered, so that ServeStdio does not exit with responses outstanding.
+type stdioTransport struct {
+	*stdio.StdioServerTransport
+	pending sync.WaitGroup
+}
+
+func (t *stdioTransport) SetMessageHandler(handler func(ctx context.Context, message *transport.BaseJsonRpcMessage)) {
+	t.StdioServerTransport.SetMessageHandler(func(ctx context.Context, message *transport.BaseJsonRpcMessage) {
+		if message.Type == transport.BaseMessageTypeJSONRPCRequestType {
+			t.pending.Add(1)
+		}
+		handler(ctx, message)
+	})
+}
+
+func (t *stdioTransport) Send(ctx context.Context, message *transport.BaseJsonRpcMessage) error {
+	err := t.StdioServerTransport.Send(ctx, message)
+	switch message.Type {
+	case transport.BaseMessageTypeJSONRPCResponseType, transport.BaseMessageTypeJSONRPCErrorType:
+		t.pending.Done()
+	}
+	return err
+}
+
+// eofReader closes eof once the underlying reader fails, which the stdio
+// transport otherwise does not report.
+type eofReader struct {
+	io.Reader
+	eof  chan struct{}
+	once sync.Once
+}
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		r.once.Do(func() { close(r.eof) })
+	}
+	return n, err
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestServeStdio(t *testing.T) {
+	cfg := toolConfig(t, "id,name\n1,a\n2,b\n")
+	cfg.ServerName = "csv-test"
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- ServeStdio(context.Background(), cfg, inR, outW)
+		outW.Close()
+	}()
+	replies := bufio.NewReader(outR)
+
+	call := func(request string) map[string]any {
+		t.Helper()
+		if _, err := io.WriteString(inW, request+"\n"); err != nil {
+			t.Fatalf("writing request: %v", err)
+		}
+		line, err := replies.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("reading reply to %s: %v", request, err)
+		}
+		var reply map[string]any
+		if err := json.Unmarshal(line, &reply); err != nil {
+			t.Fatalf("decoding reply %s: %v", line, err)
+		}
+		if reply["jsonrpc"] != "2.0" || reply["error"] != nil {
+			t.Fatalf("reply to %s = %s", request, line)
+		}
+		return reply
+	}
+
+	reply := call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1"}}}`)
+	result, _ := reply["result"].(map[string]any)
+	info, _ := result["serverInfo"].(map[string]any)
+	if reply["id"] != float64(1) || info["name"] != "csv-test" {
+		t.Errorf("initialize reply = %v, want id 1 from csv-test", reply)
+	}
+
+	reply = call(`{"jsonrpc":"2.0","id":2,"method":"tools/list","params":{}}`)
+	result, _ = reply["result"].(map[string]any)
+	list, _ := result["tools"].([]any)
+	if reply["id"] != float64(2) || len(list) == 0 {
+		t.Errorf("tools/list reply = %v, want the tools for id 2", reply)
+	}
+
+	inW.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ServeStdio = %v, want nil at EOF", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeStdio did not return at EOF")
+	}
+}
+
+func TestServeStdioCancel(t *testing.T) {
+	inR, inW := io.Pipe()
+	defer inW.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	served := make(chan error, 1)
+	go func() { served <- ServeStdio(ctx, toolConfig(t, "id\n1\n"), inR, io.Discard) }()
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ServeStdio = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeStdio did not return after cancellation")
+	}
+}
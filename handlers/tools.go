@@ -0,0 +1,1205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/korjavin/claude_connector/tools"
+	mcp "github.com/metoro-io/mcp-golang"
+)
+
+type GetLastNRecordsArgs struct {
+	Count           int      `json:"count,omitempty" jsonschema:"description=The number of recent records to retrieve. Omit to use the server default, normally 10."`
+	SortBy          string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc            bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns         []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format          string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	IncludeMetadata bool     `json:"include_metadata,omitempty" jsonschema:"description=Also report the total number of records in the file and how many were returned."`
+	Dataset         string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetFirstNRecordsArgs struct {
+	Count   int      `json:"count,omitempty" jsonschema:"description=The number of oldest records to retrieve. Omit to use the server default, normally 10."`
+	SortBy  string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc    bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type SampleRecordsArgs struct {
+	N       int      `json:"n" jsonschema:"required,description=The number of records to sample."`
+	Seed    *int64   `json:"seed,omitempty" jsonschema:"description=A seed for the random choice. The same seed returns the same sample of an unchanged file. Defaults to a new random sample on every call."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetTailRangeArgs struct {
+	FromEnd int      `json:"from_end,omitempty" jsonschema:"description=The number of most recent records to skip. 0 returns the same records as get_last_n_records and 10 the ten before those."`
+	Count   int      `json:"count" jsonschema:"required,description=The number of records to retrieve."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetRecordsPageArgs struct {
+	Offset  int      `json:"offset,omitempty" jsonschema:"description=The number of records to skip from the start of the file."`
+	Limit   int      `json:"limit" jsonschema:"required,description=The maximum number of records to return."`
+	SortBy  string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc    bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetRecordsSinceArgs struct {
+	LastSeenLine int      `json:"last_seen_line,omitempty" jsonschema:"description=The cursor returned by the previous call: the number of the last data row already seen. Omit or use 0 to start from the first record."`
+	Limit        int      `json:"limit,omitempty" jsonschema:"description=The maximum number of records to return. Defaults to 100."`
+	Columns      []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format       string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset      string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetRecordsWhereArgs struct {
+	Column     string   `json:"column" jsonschema:"required,description=The header name of the column to match against."`
+	Value      string   `json:"value" jsonschema:"required,description=The value the column must equal, or with glob the pattern it must match."`
+	Limit      int      `json:"limit,omitempty" jsonschema:"description=The maximum number of records to return. Defaults to 100."`
+	IgnoreCase bool     `json:"ignore_case,omitempty" jsonschema:"description=Match the value case-insensitively."`
+	Glob       bool     `json:"glob,omitempty" jsonschema:"description=Treat value as a wildcard pattern matched against the whole cell: * matches any run of characters and ? exactly one, so Amoxi* matches every value starting with Amoxi. Unlike the regex option of search_records, no other character is special."`
+	SortBy     string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc       bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns    []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format     string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset    string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type QueryRecordsArgs struct {
+	Conditions []QueryCondition `json:"conditions" jsonschema:"required,description=The conditions records are matched against."`
+	Match      string           `json:"match,omitempty" jsonschema:"enum=all,enum=any,description=Whether a record must satisfy all conditions or any of them. Defaults to all."`
+	Limit      int              `json:"limit,omitempty" jsonschema:"description=The maximum number of records to return. Defaults to 100."`
+	SortBy     string           `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc       bool             `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns    []string         `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format     string           `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Cursor     string           `json:"cursor,omitempty" jsonschema:"description=The next_cursor of a previous call with the same arguments, to fetch the records after the ones it returned."`
+	Dataset    string           `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type QueryCondition struct {
+	Column   string `json:"column" jsonschema:"required,description=The header name of the column to test."`
+	Operator string `json:"operator" jsonschema:"required,enum=eq,enum=ne,enum=contains,enum=gt,enum=lt,enum=gte,enum=lte,description=How the cell is compared with value. contains is case-insensitive; gt, lt, gte and lte compare numerically and never match cells that are not numbers."`
+	Value    string `json:"value" jsonschema:"required,description=The value to compare the cell with."`
+}
+
+type GetRecordByIDArgs struct {
+	IDColumn string   `json:"id_column" jsonschema:"required,description=The header name of the column holding the record ID."`
+	ID       string   `json:"id" jsonschema:"required,description=The ID of the record to return."`
+	All      bool     `json:"all,omitempty" jsonschema:"description=Return every record with this ID rather than only the first."`
+	Columns  []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format   string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset  string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetRecordsBetweenArgs struct {
+	Column  string   `json:"column" jsonschema:"required,description=The header name of the date column to filter on."`
+	From    string   `json:"from" jsonschema:"required,description=The earliest date to include as RFC3339 or YYYY-MM-DD."`
+	To      string   `json:"to" jsonschema:"required,description=The latest date to include as RFC3339 or YYYY-MM-DD."`
+	Limit   int      `json:"limit,omitempty" jsonschema:"description=The maximum number of records to return. Defaults to 100."`
+	SortBy  string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc    bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type ExportJSONLArgs struct {
+	Column     string   `json:"column,omitempty" jsonschema:"description=The header name of a column that must equal value."`
+	Value      string   `json:"value,omitempty" jsonschema:"description=The value column must equal."`
+	DateColumn string   `json:"date_column,omitempty" jsonschema:"description=The header name of a date column that must fall between from and to inclusive."`
+	From       string   `json:"from,omitempty" jsonschema:"description=The earliest date to include as RFC3339 or YYYY-MM-DD. Required with date_column."`
+	To         string   `json:"to,omitempty" jsonschema:"description=The latest date to include as RFC3339 or YYYY-MM-DD. Required with date_column."`
+	Limit      int      `json:"limit,omitempty" jsonschema:"description=The maximum number of records to export. Defaults to the server maximum."`
+	Columns    []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to export in order. Defaults to all columns."`
+	Dataset    string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type SearchRecordsArgs struct {
+	Query   string   `json:"query" jsonschema:"required,description=The text to look for in any column."`
+	Regex   bool     `json:"regex,omitempty" jsonschema:"description=Treat query as a case-insensitive regular expression instead of a plain substring."`
+	Limit   int      `json:"limit,omitempty" jsonschema:"description=The maximum number of records to return. Defaults to 100."`
+	SortBy  string   `json:"sort_by,omitempty" jsonschema:"description=The header name of a column to sort the records by before they are selected."`
+	Desc    bool     `json:"desc,omitempty" jsonschema:"description=Sort in descending order."`
+	Columns []string `json:"columns,omitempty" jsonschema:"description=The header names of the columns to return in order. Defaults to all columns."`
+	Format  string   `json:"format,omitempty" jsonschema:"enum=json,enum=csv,enum=markdown,enum=html,description=The output format. Defaults to json."`
+	Cursor  string   `json:"cursor,omitempty" jsonschema:"description=The next_cursor of a previous call with the same arguments, to fetch the records after the ones it returned."`
+	Dataset string   `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type DescribeSchemaArgs struct {
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GetHeaderArgs struct {
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type ValidateCSVArgs struct {
+	MaxProblems int    `json:"max_problems,omitempty" jsonschema:"description=The maximum number of problems to list. Defaults to 20."`
+	Dataset     string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type ColumnCompletenessArgs struct {
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type ColumnStatsArgs struct {
+	Column  string `json:"column" jsonschema:"required,description=The header name of the numeric column to summarise."`
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type GroupCountArgs struct {
+	GroupBy string `json:"group_by" jsonschema:"required,description=The header name of the column to group records by."`
+	Metric  string `json:"metric,omitempty" jsonschema:"description=The header name of a numeric column to sum within each group."`
+	Limit   int    `json:"limit,omitempty" jsonschema:"description=The maximum number of groups to return. Defaults to 100."`
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type DistinctValuesArgs struct {
+	Column     string `json:"column" jsonschema:"required,description=The header name of the column to list values of."`
+	WithCounts bool   `json:"with_counts,omitempty" jsonschema:"description=Include the number of records holding each value and sort by frequency."`
+	Limit      int    `json:"limit,omitempty" jsonschema:"description=The maximum number of values to return. Defaults to 100."`
+	Dataset    string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type ColumnHistogramArgs struct {
+	Column  string `json:"column" jsonschema:"required,description=The header name of the column to summarise."`
+	Bins    int    `json:"bins,omitempty" jsonschema:"description=The number of equal-width bins for a numeric column. Defaults to 10."`
+	Limit   int    `json:"limit,omitempty" jsonschema:"description=The maximum number of values to return for a categorical column. Defaults to 100."`
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+type DiffDatasetsArgs struct {
+	From  string `json:"from" jsonschema:"required,description=The name of the dataset holding the older version, such as yesterday's snapshot."`
+	To    string `json:"to" jsonschema:"required,description=The name of the dataset holding the newer version."`
+	Key   string `json:"key" jsonschema:"required,description=The header name of the column that identifies a record in both datasets. Its values must be unique."`
+	Limit int    `json:"limit,omitempty" jsonschema:"description=The maximum number of added, removed and changed records to list each. Defaults to 100."`
+}
+
+type AppendRecordArgs struct {
+	Fields         []string          `json:"fields,omitempty" jsonschema:"description=The values of the new record in header order. Give either fields or record."`
+	Record         map[string]string `json:"record,omitempty" jsonschema:"description=The values of the new record keyed by header name. Missing columns are left empty."`
+	Dataset        string            `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to append to. May be omitted when only one dataset is configured."`
+	IdempotencyKey string            `json:"idempotency_key,omitempty" jsonschema:"description=A unique key for this append, such as a UUID. Retrying with the same key returns the original response instead of appending the record again."`
+}
+
+type CountRecordsArgs struct {
+	Column  string `json:"column,omitempty" jsonschema:"description=The header name of the column to filter on. Leave empty to count every record."`
+	Value   string `json:"value,omitempty" jsonschema:"description=The value the column must equal to be counted."`
+	Dataset string `json:"dataset,omitempty" jsonschema:"description=The name of the dataset to read. May be omitted when only one dataset is configured."`
+}
+
+// RegisterTools registers every tool selected by cfg on server, whatever
+// transport it uses.
+func RegisterTools(server *mcp.Server, cfg Config) error {
+	r := &toolRegistrar{server: server, audit: cfg.Audit, timeout: cfg.ToolTimeout}
+	registerTools(r, cfg)
+	return r.err
+}
+
+// registerTools passes every tool selected by cfg to r.
+func registerTools(r *toolRegistrar, cfg Config) {
+	registerTool(r,
+		"get_last_n_records",
+		"Retrieves the last N records from the local medical information CSV file.",
+		func(ctx context.Context, args GetLastNRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Count < 0 {
+				return errorResponse("count must be a positive integer.")
+			}
+			if args.Count == 0 {
+				args.Count = cfg.DefaultCount
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			notes := cfg.capRecords(&args.Count)
+
+			var skipped, total int
+			var records [][]string
+			var header []string
+			if args.SortBy != "" {
+				records, header, err = sortedRecords(ctx, csvPath, args.SortBy, args.Desc)
+				total = len(records)
+				// Sorted, the last N records are the first N in sort order.
+				if len(records) > args.Count {
+					records = records[:args.Count]
+				}
+			} else {
+				records, header, err = tools.TailWithHeader(ctx, cfg.tailFunc(&skipped), csvPath, args.Count)
+			}
+			if err != nil {
+				return errorResponse("failed to get records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			extras := recordExtras{Notes: notes}
+			if args.IncludeMetadata {
+				if args.SortBy == "" {
+					total, err = tools.CountDataRows(ctx, csvPath, !cfg.Strict)
+					if err != nil {
+						return errorResponse("failed to count records: %v", err)
+					}
+				}
+				extras.Totals = &recordTotals{Total: total, Returned: len(records)}
+			}
+			if skipped > 0 {
+				extras.Notes = append(extras.Notes, fmt.Sprintf("%d malformed rows skipped", skipped))
+			}
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, extras)
+		},
+	)
+
+	registerTool(r,
+		"get_first_n_records",
+		"Retrieves the first N records from the local medical information CSV file.",
+		func(ctx context.Context, args GetFirstNRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Count < 0 {
+				return errorResponse("count must be a positive integer.")
+			}
+			if args.Count == 0 {
+				args.Count = cfg.DefaultCount
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			notes := cfg.capRecords(&args.Count)
+
+			var records [][]string
+			var header []string
+			if args.SortBy != "" {
+				records, header, err = sortedRecords(ctx, csvPath, args.SortBy, args.Desc)
+				if len(records) > args.Count {
+					records = records[:args.Count]
+				}
+			} else {
+				records, header, err = tools.GetFirstNRecords(ctx, csvPath, args.Count)
+			}
+			if err != nil {
+				return errorResponse("failed to get records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"sample_records",
+		"Retrieves a uniform random sample of N records from the local medical information CSV file, in file order, for spot-checking the data. Pass a seed to get a reproducible sample.",
+		func(ctx context.Context, args SampleRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.N <= 0 {
+				return errorResponse("n must be a positive integer.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			notes := cfg.capRecords(&args.N)
+
+			seed := time.Now().UnixNano()
+			if args.Seed != nil {
+				seed = *args.Seed
+			}
+			records, header, err := tools.SampleRecords(ctx, csvPath, args.N, rand.New(rand.NewSource(seed)))
+			if err != nil {
+				return errorResponse("failed to sample records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"get_records_page",
+		"Retrieves a page of records from the local medical information CSV file, skipping offset records and returning up to limit. The response says whether more pages remain.",
+		func(ctx context.Context, args GetRecordsPageArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Offset < 0 {
+				return errorResponse("offset must not be negative.")
+			}
+			if args.Limit <= 0 {
+				return errorResponse("limit must be a positive integer.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			notes := cfg.capRecords(&args.Limit)
+
+			var records [][]string
+			var header []string
+			var total int
+			if args.SortBy != "" {
+				records, header, err = sortedRecords(ctx, csvPath, args.SortBy, args.Desc)
+				total = len(records)
+				// Clamp before adding so a huge offset or limit cannot overflow.
+				start := min(args.Offset, total)
+				end := start + min(args.Limit, total-start)
+				records = records[start:end]
+			} else {
+				records, header, total, err = tools.GetRecordsPage(ctx, csvPath, args.Offset, args.Limit)
+			}
+			if err != nil {
+				return errorResponse("failed to get records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if total == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Page: &pageInfo{
+				Offset:   args.Offset,
+				Returned: len(records),
+				Total:    total,
+				HasMore:  min(args.Offset, total)+len(records) < total,
+			}, Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"get_tail_range",
+		"Retrieves count records from the local medical information CSV file ending from_end records before the most recent one, for paging backwards through recent history. The records are in file order.",
+		func(ctx context.Context, args GetTailRangeArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.FromEnd < 0 {
+				return errorResponse("from_end must not be negative.")
+			}
+			if args.Count <= 0 {
+				return errorResponse("count must be a positive integer.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			notes := cfg.capRecords(&args.Count)
+
+			records, header, total, err := tools.GetTailRange(ctx, csvPath, args.FromEnd, args.Count)
+			if err != nil {
+				return errorResponse("failed to get records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				if total > 0 {
+					return noRecordsResponse(ctx, csvPath, fmt.Sprintf("No records found: the file has only %d records.", total))
+				}
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"get_records_since",
+		"Retrieves the records added to the local medical information CSV file after the data row last_seen_line, for polling. The response carries the cursor to pass as last_seen_line next time, and says when the file shrank so reading restarted from the first record.",
+		func(ctx context.Context, args GetRecordsSinceArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.LastSeenLine < 0 {
+				return errorResponse("last_seen_line must not be negative.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			if args.Limit <= 0 {
+				args.Limit = tools.DefaultFilterLimit
+			}
+			notes := cfg.capRecords(&args.Limit)
+
+			offset := args.LastSeenLine
+			records, header, total, err := tools.GetRecordsPage(ctx, csvPath, offset, args.Limit)
+			// A file with fewer rows than the client has seen was truncated or
+			// replaced, so everything in it is new.
+			reset := err == nil && total < offset
+			if reset {
+				offset = 0
+				records, header, total, err = tools.GetRecordsPage(ctx, csvPath, offset, args.Limit)
+			}
+			if err != nil {
+				return errorResponse("failed to get records: %v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if header == nil {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Cursor: &cursorInfo{
+				LastSeenLine: offset + len(records),
+				Reset:        reset,
+				HasMore:      offset+len(records) < total,
+			}, Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"get_records_where",
+		"Retrieves records from the local medical information CSV file whose column equals the given value, or matches it as a wildcard pattern such as Amoxi* when glob is set.",
+		func(ctx context.Context, args GetRecordsWhereArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Column == "" {
+				return errorResponse("column is required.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			if args.Limit <= 0 {
+				args.Limit = tools.DefaultFilterLimit
+			}
+			notes := cfg.capRecords(&args.Limit)
+
+			if err := checkSortable(csvPath, args.SortBy); err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, err := tools.FilterRecords(ctx, csvPath, args.Column, args.Value, scanLimit(args.Limit, args.SortBy), args.IgnoreCase, args.Glob)
+			if err != nil {
+				return errorResponse("failed to filter records: %v", err)
+			}
+			records, err = sortAndLimit(records, header, args.SortBy, args.Desc, args.Limit)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"query_records",
+		"Retrieves records from the local medical information CSV file that satisfy all, or any, of a list of conditions on their columns.",
+		func(ctx context.Context, args QueryRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(args.Conditions) == 0 {
+				return errorResponse("at least one condition is required.")
+			}
+			if args.Match != "" && args.Match != "all" && args.Match != "any" {
+				return errorResponse("match must be \"all\" or \"any\", got %q", args.Match)
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			if args.Limit <= 0 {
+				args.Limit = tools.DefaultFilterLimit
+			}
+			notes := cfg.capRecords(&args.Limit)
+			offset, fingerprint, err := resumeCursor(ctx, csvPath, args.Cursor)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			conditions := make([]tools.Condition, len(args.Conditions))
+			for i, c := range args.Conditions {
+				conditions[i] = tools.Condition{Column: c.Column, Operator: c.Operator, Value: c.Value}
+			}
+			// One match more than the chunk tells whether another chunk follows.
+			want := offset + args.Limit + 1
+			if err := checkSortable(csvPath, args.SortBy); err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, err := tools.QueryRecords(ctx, csvPath, conditions, args.Match == "any", scanLimit(want, args.SortBy))
+			if err != nil {
+				return errorResponse("failed to query records: %v", err)
+			}
+			records, err = sortAndLimit(records, header, args.SortBy, args.Desc, want)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+			records = records[min(offset, len(records)):]
+			var next string
+			if len(records) > args.Limit {
+				records = records[:args.Limit]
+				next = encodeCursor(offset+args.Limit, fingerprint)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes, NextCursor: next})
+		},
+	)
+
+	registerTool(r,
+		"get_record_by_id",
+		"Retrieves the record from the local medical information CSV file whose ID column equals the given ID.",
+		func(ctx context.Context, args GetRecordByIDArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.IDColumn == "" {
+				return errorResponse("id_column is required.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+
+			var records [][]string
+			var header []string
+			var notes []string
+			if args.All {
+				limit := math.MaxInt
+				if cfg.MaxRecords > 0 {
+					// One match more than the cap tells whether any were left out.
+					limit = cfg.MaxRecords + 1
+				}
+				records, header, err = tools.FilterRecords(ctx, csvPath, args.IDColumn, args.ID, limit, false, false)
+				if cfg.MaxRecords > 0 && len(records) > cfg.MaxRecords {
+					records = records[:cfg.MaxRecords]
+					notes = []string{fmt.Sprintf("more than %[1]d records have this ID but at most %[1]d are returned; results truncated", cfg.MaxRecords)}
+				}
+			} else {
+				var record []string
+				record, header, err = tools.GetRecordByID(ctx, csvPath, args.IDColumn, args.ID)
+				if record != nil {
+					records = [][]string{record}
+				}
+			}
+			if err != nil {
+				return errorResponse("failed to get record: %v", err)
+			}
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, fmt.Sprintf("No record with %s %q found.", args.IDColumn, args.ID))
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"get_records_between",
+		"Retrieves records from the local medical information CSV file whose date column falls between from and to inclusive.",
+		func(ctx context.Context, args GetRecordsBetweenArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Column == "" {
+				return errorResponse("column is required.")
+			}
+			from, err := tools.ParseDate(args.From)
+			if err != nil {
+				return errorResponse("invalid from: %v", err)
+			}
+			to, err := tools.ParseDate(args.To)
+			if err != nil {
+				return errorResponse("invalid to: %v", err)
+			}
+			if to.Before(from) {
+				return errorResponse("to must not be before from.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			if args.Limit <= 0 {
+				args.Limit = tools.DefaultFilterLimit
+			}
+			notes := cfg.capRecords(&args.Limit)
+
+			if err := checkSortable(csvPath, args.SortBy); err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, err := tools.FilterByDateRange(ctx, csvPath, args.Column, from, to, scanLimit(args.Limit, args.SortBy))
+			if err != nil {
+				return errorResponse("failed to filter records: %v", err)
+			}
+			records, err = sortAndLimit(records, header, args.SortBy, args.Desc, args.Limit)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Notes: notes})
+		},
+	)
+
+	registerTool(r,
+		"search_records",
+		"Searches every column of the local medical information CSV file for the given text, case-insensitively, and returns the matching records along with how many matched in total.",
+		func(ctx context.Context, args SearchRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Query == "" {
+				return errorResponse("query is required.")
+			}
+			if err := validateFormat(args.Format); err != nil {
+				return errorResponse("%v", err)
+			}
+			if args.Limit <= 0 {
+				args.Limit = tools.DefaultFilterLimit
+			}
+			notes := cfg.capRecords(&args.Limit)
+			offset, fingerprint, err := resumeCursor(ctx, csvPath, args.Cursor)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			want := offset + args.Limit
+			if err := checkSortable(csvPath, args.SortBy); err != nil {
+				return errorResponse("%v", err)
+			}
+			records, header, matched, err := tools.SearchRecords(ctx, csvPath, args.Query, scanLimit(want, args.SortBy), args.Regex)
+			if err != nil {
+				return errorResponse("failed to search records: %v", err)
+			}
+			records, err = sortAndLimit(records, header, args.SortBy, args.Desc, want)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+			records = records[min(offset, len(records)):]
+			var next string
+			if offset+len(records) < matched {
+				next = encodeCursor(offset+len(records), fingerprint)
+			}
+			records, header, err = tools.ProjectColumns(records, header, args.Columns)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if len(records) == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, len(records))
+			return recordsResponse(args.Format, header, records, recordExtras{Matched: &matched, Notes: notes, NextCursor: next})
+		},
+	)
+
+	registerTool(r,
+		"export_jsonl",
+		"Exports records from the local medical information CSV file as JSON Lines with one object per line for bulk processing. Optionally only records whose column equals value and whose date column falls between from and to.",
+		func(ctx context.Context, args ExportJSONLArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			filter := tools.ExportFilter{Column: args.Column, Value: args.Value, DateColumn: args.DateColumn}
+			if args.DateColumn != "" {
+				if filter.From, err = tools.ParseDate(args.From); err != nil {
+					return errorResponse("invalid from: %v", err)
+				}
+				if filter.To, err = tools.ParseDate(args.To); err != nil {
+					return errorResponse("invalid to: %v", err)
+				}
+				if filter.To.Before(filter.From) {
+					return errorResponse("to must not be before from.")
+				}
+			}
+			if args.Limit <= 0 {
+				args.Limit = cfg.MaxRecords
+			}
+			notes := cfg.capRecords(&args.Limit)
+
+			var b strings.Builder
+			written, err := tools.ExportJSONL(ctx, &b, csvPath, filter, args.Columns, args.Limit)
+			if err != nil {
+				return errorResponse("failed to export records: %v", err)
+			}
+			if written == 0 {
+				return noRecordsResponse(ctx, csvPath, "No records found.")
+			}
+
+			reportRows(ctx, written)
+
+			// Notes go in their own content so the export stays valid JSON Lines.
+			content := []*mcp.Content{mcp.NewTextContent(b.String())}
+			for _, note := range notes {
+				content = append(content, mcp.NewTextContent("("+note+")"))
+			}
+			return mcp.NewToolResponse(content...), nil
+		},
+	)
+
+	registerTool(r,
+		"describe_schema",
+		"Describes the local medical information CSV file: its columns, the inferred type of each column, and the total number of records.",
+		func(ctx context.Context, args DescribeSchemaArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			schema, err := tools.DescribeCSV(ctx, csvPath)
+			if err != nil {
+				return errorResponse("failed to describe schema: %v", err)
+			}
+
+			return jsonResponse(schema)
+		},
+	)
+
+	registerTool(r,
+		"get_header",
+		"Returns the column names of the local medical information CSV file without reading any records. Cheaper than describe_schema when column types are not needed.",
+		func(ctx context.Context, args GetHeaderArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			header, err := tools.GetHeader(ctx, csvPath)
+			if errors.Is(err, tools.ErrEmptyFile) {
+				return mcp.NewToolResponse(mcp.NewTextContent("No header found: the file is empty.")), nil
+			}
+			if err != nil {
+				return errorResponse("failed to read header: %v", err)
+			}
+
+			return jsonResponse(headerColumns{Columns: header})
+		},
+	)
+
+	registerTool(r,
+		"validate_csv",
+		"Checks the local medical information CSV file for defects: a missing header and rows with the wrong number of fields or that cannot be parsed. Also counts the empty cells in each column. Never modifies the file.",
+		func(ctx context.Context, args ValidateCSVArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			validation, err := tools.ValidateCSV(ctx, csvPath, args.MaxProblems)
+			if err != nil {
+				return errorResponse("failed to validate csv file: %v", err)
+			}
+
+			return jsonResponse(validation)
+		},
+	)
+
+	registerTool(r,
+		"count_records",
+		"Counts the records in the local medical information CSV file, optionally only those whose column equals the given value.",
+		func(ctx context.Context, args CountRecordsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			total, matched, err := tools.CountRecords(ctx, csvPath, args.Column, args.Value)
+			if err != nil {
+				return errorResponse("failed to count records: %v", err)
+			}
+
+			result := recordCount{Total: total}
+			if args.Column != "" {
+				result.Matched = &matched
+			}
+			return jsonResponse(result)
+		},
+	)
+
+	registerTool(r,
+		"column_completeness",
+		"Reports how complete each column of the local medical information CSV file is: the number and percentage of records with an empty cell, along with the total number of records.",
+		func(ctx context.Context, args ColumnCompletenessArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			completeness, err := tools.ColumnCompleteness(ctx, csvPath)
+			if err != nil {
+				return errorResponse("failed to measure completeness: %v", err)
+			}
+
+			return jsonResponse(completeness)
+		},
+	)
+
+	registerTool(r,
+		"column_stats",
+		"Summarises a numeric column of the local medical information CSV file: count, min, max, sum, mean and median. Non-numeric cells are skipped and counted.",
+		func(ctx context.Context, args ColumnStatsArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Column == "" {
+				return errorResponse("column is required.")
+			}
+
+			stats, err := tools.ColumnStats(ctx, csvPath, args.Column)
+			if err != nil {
+				return errorResponse("failed to compute statistics: %v", err)
+			}
+
+			return jsonResponse(stats)
+		},
+	)
+
+	registerTool(r,
+		"distinct_values",
+		"Lists the distinct values of a column in the local medical information CSV file, optionally with how many records hold each. Useful for finding valid values for get_records_where.",
+		func(ctx context.Context, args DistinctValuesArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Column == "" {
+				return errorResponse("column is required.")
+			}
+			limit := args.Limit
+			if limit <= 0 {
+				limit = tools.DefaultDistinctLimit
+			}
+
+			values, err := tools.DistinctValues(ctx, csvPath, args.Column, args.WithCounts)
+			if err != nil {
+				return errorResponse("failed to list values: %v", err)
+			}
+
+			result := distinctValues{Column: args.Column, Distinct: len(values)}
+			if len(values) > limit {
+				values = values[:limit]
+				result.Truncated = true
+			}
+			result.Values = values
+			return jsonResponse(result)
+		},
+	)
+
+	registerTool(r,
+		"group_count",
+		"Counts the records of the local medical information CSV file per distinct value of a column, most frequent first, such as visits per department. Optionally sums a numeric metric column within each group.",
+		func(ctx context.Context, args GroupCountArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.GroupBy == "" {
+				return errorResponse("group_by is required.")
+			}
+			limit := args.Limit
+			if limit <= 0 {
+				limit = tools.DefaultGroupLimit
+			}
+
+			groups, err := tools.GroupCount(ctx, csvPath, args.GroupBy, args.Metric)
+			if err != nil {
+				return errorResponse("failed to group records: %v", err)
+			}
+
+			result := groupCounts{GroupBy: args.GroupBy, Metric: args.Metric, Groups: len(groups)}
+			if len(groups) > limit {
+				groups = groups[:limit]
+				result.Truncated = true
+			}
+			result.Values = groups
+			return jsonResponse(result)
+		},
+	)
+
+	registerTool(r,
+		"column_histogram",
+		"Summarises the distribution of a column in the local medical information CSV file: record counts per equal-width bin for a numeric column, or per value, most frequent first, for any other column.",
+		func(ctx context.Context, args ColumnHistogramArgs) (*mcp.ToolResponse, error) {
+			csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			if args.Column == "" {
+				return errorResponse("column is required.")
+			}
+			if args.Bins < 0 || args.Bins > tools.MaxHistogramBins {
+				return errorResponse("bins must be between 1 and %d.", tools.MaxHistogramBins)
+			}
+
+			histogram, err := tools.ColumnHistogram(ctx, csvPath, args.Column, args.Bins, args.Limit)
+			if err != nil {
+				return errorResponse("failed to compute histogram: %v", err)
+			}
+			return jsonResponse(histogram)
+		},
+	)
+
+	registerTool(r,
+		"diff_datasets",
+		"Compares two datasets of local medical information, such as yesterday's and today's snapshots, matching records on a key column. Returns the records added, removed and changed, or the difference in columns if the datasets do not share the same columns.",
+		func(ctx context.Context, args DiffDatasetsArgs) (*mcp.ToolResponse, error) {
+			if args.From == "" || args.To == "" {
+				return errorResponse("from and to are required.")
+			}
+			if args.Key == "" {
+				return errorResponse("key is required.")
+			}
+			fromPath, err := cfg.Datasets.Resolve(args.From)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+			toPath, err := cfg.Datasets.Resolve(args.To)
+			if err != nil {
+				return errorResponse("%v", err)
+			}
+
+			limit := args.Limit
+			if limit <= 0 {
+				limit = tools.DefaultDiffLimit
+			}
+			if cfg.MaxRecords > 0 && limit > cfg.MaxRecords {
+				limit = cfg.MaxRecords
+			}
+
+			diff, err := tools.DiffDatasets(ctx, fromPath, toPath, args.Key, limit)
+			if err != nil {
+				return errorResponse("failed to diff datasets: %v", err)
+			}
+			return jsonResponse(diff)
+		},
+	)
+
+	if cfg.Writable {
+		registerTool(r,
+			"append_record",
+			"Appends a new record to the end of the local medical information CSV file.",
+			func(ctx context.Context, args AppendRecordArgs) (*mcp.ToolResponse, error) {
+				if cfg.WriteScope != "" && !callerHasScope(ctx, cfg.WriteScope) {
+					return errorResponse("appending records requires the %q scope.", cfg.WriteScope)
+				}
+
+				csvPath, err := cfg.Datasets.Resolve(args.Dataset)
+				if err != nil {
+					return errorResponse("%v", err)
+				}
+
+				fields := args.Fields
+				switch {
+				case len(args.Fields) > 0 && len(args.Record) > 0:
+					return errorResponse("give either fields or record, not both.")
+				case len(args.Record) > 0:
+					fields, err = tools.RecordFromMap(ctx, csvPath, args.Record)
+					if err != nil {
+						return errorResponse("%v", err)
+					}
+				case len(args.Fields) == 0:
+					return errorResponse("fields or record is required.")
+				}
+
+				appendRecord := func() (*mcp.ToolResponse, error) {
+					if err := tools.AppendRecord(csvPath, fields); err != nil {
+						return errorResponse("failed to append record: %v", err)
+					}
+					return mcp.NewToolResponse(mcp.NewTextContent("Record appended.")), nil
+				}
+				if args.IdempotencyKey == "" || cfg.Idempotency == nil {
+					return appendRecord()
+				}
+				// Keys are scoped to the caller and the file, so clients
+				// cannot replay one another's responses.
+				key := callerSubject(ctx) + "\x00" + csvPath + "\x00" + args.IdempotencyKey
+				return cfg.Idempotency.do(key, time.Now(), appendRecord)
+			},
+		)
+	}
+}
+
+// sortedRecords reads every data row of the CSV file at csvPath and sorts them
+// by column. Sorting needs the whole file, so every row is held in memory.
+func sortedRecords(ctx context.Context, csvPath, column string, desc bool) ([][]string, []string, error) {
+	if err := checkSortable(csvPath, column); err != nil {
+		return nil, nil, err
+	}
+	records, header, err := tools.GetFirstNRecords(ctx, csvPath, math.MaxInt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tools.SortRecords(records, header, column, desc); err != nil {
+		return nil, nil, err
+	}
+	return records, header, nil
+}
+
+// resumeCursor returns the number of matching records to skip for cursor, an
+// empty cursor meaning none, along with the fingerprint of the file at
+// csvPath for the cursor of the next chunk.
+func resumeCursor(ctx context.Context, csvPath, cursor string) (int, string, error) {
+	fingerprint, err := tools.Fingerprint(csvPath)
+	if err != nil {
+		return 0, "", err
+	}
+	if cursor == "" {
+		return 0, fingerprint, nil
+	}
+	rows, err := tools.CountDataRows(ctx, csvPath, true)
+	if err != nil {
+		return 0, "", err
+	}
+	offset, err := decodeCursor(cursor, fingerprint, rows)
+	return offset, fingerprint, err
+}
+
+// checkSortable refuses to sort the records of a file over the MAX_FILE_BYTES
+// limit, as sorting holds all of them in memory. Without sortBy it does
+// nothing.
+func checkSortable(csvPath, sortBy string) error {
+	if sortBy == "" {
+		return nil
+	}
+	err := tools.CheckFileSize(csvPath)
+	if errors.Is(err, tools.ErrFileTooLarge) {
+		return fmt.Errorf("%v; sorting reads every record into memory, so leave out sort_by and narrow the records with a filtering tool instead", err)
+	}
+	return err
+}
+
+// scanLimit returns the limit to pass to a filtering reader. Without sorting
+// the reader can stop at limit; with sorting every match is needed, so the
+// limit is applied afterwards by sortAndLimit instead.
+func scanLimit(limit int, sortBy string) int {
+	if sortBy == "" {
+		return limit
+	}
+	return math.MaxInt
+}
+
+// sortAndLimit sorts the matches of a filtering reader by sortBy and applies
+// limit, defaulting to tools.DefaultFilterLimit. The matching rows are all
+// buffered before sorting. Without sortBy the records are returned unchanged.
+func sortAndLimit(records [][]string, header []string, sortBy string, desc bool, limit int) ([][]string, error) {
+	if sortBy == "" {
+		return records, nil
+	}
+	if err := tools.SortRecords(records, header, sortBy, desc); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = tools.DefaultFilterLimit
+	}
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// capRecords lowers *n to cfg.MaxRecords when it asks for more, returning a
+// note for the response saying so.
+func (cfg Config) capRecords(n *int) []string {
+	if cfg.MaxRecords <= 0 || *n <= cfg.MaxRecords {
+		return nil
+	}
+	note := fmt.Sprintf("%d records requested but at most %d are returned; results truncated", *n, cfg.MaxRecords)
+	*n = cfg.MaxRecords
+	return []string{note}
+}
@@ -0,0 +1,640 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/tools"
+)
+
+// callTool calls the named tool through the MCP HTTP handler for cfg and
+// returns the text of its response and whether it reports an error.
+func callTool(t *testing.T, cfg Config, name string, args any) (string, bool) {
+	t.Helper()
+	return callToolOn(t, MCPHandler(cfg), name, args)
+}
+
+// callToolOn is callTool for the MCP HTTP handler mcpHandler.
+func callToolOn(t *testing.T, mcpHandler gin.HandlerFunc, name string, args any) (string, bool) {
+	t.Helper()
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	callMethodOn(t, mcpHandler, "tools/call", map[string]any{"name": name, "arguments": args}, &result)
+	if len(result.Content) == 0 {
+		t.Fatalf("%s: reply has no content", name)
+	}
+	text := result.Content[0].Text
+	return text, result.IsError || strings.HasPrefix(text, errorPrefix)
+}
+
+// callMethodOn sends a JSON-RPC request for method to the MCP HTTP handler
+// mcpHandler and decodes the result of its reply into result.
+func callMethodOn(t *testing.T, mcpHandler gin.HandlerFunc, method string, params, result any) {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		t.Fatalf("encoding request: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/mcp", mcpHandler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", method, w.Code, w.Body)
+	}
+
+	var reply struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("%s: decoding reply %s: %v", method, w.Body, err)
+	}
+	if reply.Error != nil {
+		t.Fatalf("%s: %s", method, reply.Error.Message)
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		t.Fatalf("%s: decoding result %s: %v", method, reply.Result, err)
+	}
+}
+
+// toolConfig returns a Config serving content as the default dataset.
+func toolConfig(t *testing.T, content string) Config {
+	t.Helper()
+	path := writeFixture(t, "records.csv", content)
+	return Config{Datasets: newRegistry(t, Datasets{DefaultDataset: path}), DefaultCount: 10}
+}
+
+// setReaderOptions replaces the reader options of the tools package for the
+// duration of the test, filling in the defaults of the fields left unset.
+func setReaderOptions(t *testing.T, opts tools.ReaderOptions) {
+	t.Helper()
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	if opts.OpenRetries == 0 {
+		opts.OpenRetries = tools.DefaultOpenRetries
+	}
+	tools.SetReaderOptions(opts)
+	t.Cleanup(func() { tools.SetReaderOptions(tools.ReaderOptions{Comma: ',', OpenRetries: tools.DefaultOpenRetries}) })
+}
+
+// decodeRecords decodes the JSON text of a records response.
+func decodeRecords(t *testing.T, text string) recordSet {
+	t.Helper()
+	var resp recordSet
+	if err := json.Unmarshal([]byte(text), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", text, err)
+	}
+	return resp
+}
+
+// recordIDs returns the id column of records.
+func recordIDs(records []map[string]string) []string {
+	ids := []string{}
+	for _, record := range records {
+		ids = append(ids, record["id"])
+	}
+	return ids
+}
+
+func TestGetRecordsPageSorted(t *testing.T) {
+	cfg := toolConfig(t, "id,dose\n1,30\n2,10\n3,20\n")
+
+	tests := []struct {
+		name        string
+		offset      int
+		limit       int
+		wantIDs     []string
+		wantHasMore bool
+	}{
+		{name: "first page", offset: 0, limit: 2, wantIDs: []string{"2", "3"}, wantHasMore: true},
+		{name: "last page", offset: 2, limit: 2, wantIDs: []string{"1"}, wantHasMore: false},
+		{name: "past the end", offset: 5, limit: 2, wantIDs: []string{}, wantHasMore: false},
+		{name: "huge limit", offset: 1, limit: math.MaxInt, wantIDs: []string{"3", "1"}, wantHasMore: false},
+		{name: "huge offset and limit", offset: math.MaxInt, limit: math.MaxInt, wantIDs: []string{}, wantHasMore: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, "get_records_page", map[string]any{
+				"offset": tt.offset, "limit": tt.limit, "sort_by": "dose",
+			})
+			if isError {
+				t.Fatalf("get_records_page failed: %s", text)
+			}
+			resp := decodeRecords(t, text)
+			if ids := recordIDs(resp.Records); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", ids, tt.wantIDs)
+			}
+			if resp.Page == nil || resp.Page.HasMore != tt.wantHasMore || resp.Page.Total != 3 {
+				t.Errorf("page = %+v, want total 3 and has_more %t", resp.Page, tt.wantHasMore)
+			}
+		})
+	}
+}
+
+func TestGetLastNRecordsSorted(t *testing.T) {
+	cfg := toolConfig(t, "id,dose\n1,30\n2,10\n3,20\n4,5\n")
+
+	tests := []struct {
+		name    string
+		desc    bool
+		wantIDs []string
+	}{
+		{name: "ascending", wantIDs: []string{"4", "2"}},
+		{name: "descending", desc: true, wantIDs: []string{"1", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{
+				"count": 2, "sort_by": "dose", "desc": tt.desc,
+			})
+			if isError {
+				t.Fatalf("get_last_n_records failed: %s", text)
+			}
+			resp := decodeRecords(t, text)
+			if ids := recordIDs(resp.Records); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestMaxRecords(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,A\n3,A\n4,B\n5,A\n")
+	cfg.MaxRecords = 2
+	const truncated = "5 records requested but at most 2 are returned; results truncated"
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]any
+		wantIDs  []string
+		wantNote string
+	}{
+		{name: "last over the cap", tool: "get_last_n_records", args: map[string]any{"count": 5}, wantIDs: []string{"4", "5"}, wantNote: truncated},
+		{name: "last at the cap", tool: "get_last_n_records", args: map[string]any{"count": 2}, wantIDs: []string{"4", "5"}},
+		{name: "first over the cap", tool: "get_first_n_records", args: map[string]any{"count": 5}, wantIDs: []string{"1", "2"}, wantNote: truncated},
+		{name: "page over the cap", tool: "get_records_page", args: map[string]any{"offset": 1, "limit": 5}, wantIDs: []string{"2", "3"}, wantNote: truncated},
+		{name: "where over the cap", tool: "get_records_where", args: map[string]any{"column": "ward", "value": "A", "limit": 5}, wantIDs: []string{"1", "2"}, wantNote: truncated},
+		{name: "where under the cap", tool: "get_records_where", args: map[string]any{"column": "ward", "value": "B", "limit": 1}, wantIDs: []string{"4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, tt.tool, tt.args)
+			if isError {
+				t.Fatalf("%s failed: %s", tt.tool, text)
+			}
+			resp := decodeRecords(t, text)
+			if ids := recordIDs(resp.Records); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", ids, tt.wantIDs)
+			}
+			var notes []string
+			if tt.wantNote != "" {
+				notes = []string{tt.wantNote}
+			}
+			if !reflect.DeepEqual(resp.Notes, notes) {
+				t.Errorf("notes = %q, want %q", resp.Notes, notes)
+			}
+		})
+	}
+}
+
+func TestSampleRecordsSeed(t *testing.T) {
+	cfg := toolConfig(t, "id\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+
+	sample := func(seed int) []string {
+		t.Helper()
+		text, isError := callTool(t, cfg, "sample_records", map[string]any{"n": 3, "seed": seed})
+		if isError {
+			t.Fatalf("sample_records failed: %s", text)
+		}
+		return recordIDs(decodeRecords(t, text).Records)
+	}
+
+	first := sample(7)
+	if len(first) != 3 {
+		t.Fatalf("sampled %q, want 3 records", first)
+	}
+	if again := sample(7); !reflect.DeepEqual(again, first) {
+		t.Errorf("seed 7 sampled %q and then %q", first, again)
+	}
+}
+
+func TestMaxFileBytes(t *testing.T) {
+	content := "id,dose\n1,30\n2,10\n3,20\n"
+	cfg := toolConfig(t, content)
+	setReaderOptions(t, tools.ReaderOptions{MaxFileBytes: int64(len(content) - 1)})
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		// wantErr is a substring of the error, or "" when the call succeeds.
+		wantErr string
+	}{
+		{tool: "get_records_page", args: map[string]any{"limit": 2, "sort_by": "dose"}, wantErr: "leave out sort_by"},
+		{tool: "get_last_n_records", args: map[string]any{"count": 2, "sort_by": "dose"}, wantErr: "leave out sort_by"},
+		{tool: "get_records_where", args: map[string]any{"column": "id", "value": "1", "sort_by": "dose"}, wantErr: "leave out sort_by"},
+		{tool: "column_stats", args: map[string]any{"column": "dose"}, wantErr: "larger than the configured maximum"},
+		{tool: "get_records_page", args: map[string]any{"limit": 2}},
+		{tool: "get_last_n_records", args: map[string]any{"count": 2}},
+		{tool: "get_records_where", args: map[string]any{"column": "id", "value": "1"}},
+		{tool: "distinct_values", args: map[string]any{"column": "dose"}},
+		{tool: "count_records", args: map[string]any{}},
+	}
+	for _, tt := range tests {
+		name := tt.tool
+		if _, ok := tt.args["sort_by"]; ok {
+			name += " sorted"
+		}
+		t.Run(name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, tt.tool, tt.args)
+			if tt.wantErr == "" {
+				if isError {
+					t.Errorf("%s failed on a file over the limit it does not need: %s", tt.tool, text)
+				}
+				return
+			}
+			if !isError || !strings.Contains(text, tt.wantErr) {
+				t.Errorf("%s = %q, want an error mentioning %q", tt.tool, text, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTMLFormat(t *testing.T) {
+	cfg := toolConfig(t, "id,note\n1,<script>alert(1)</script>\n2,a & b\n")
+	cfg.MaxRecords = 1
+
+	text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{"count": 2, "format": "html"})
+	if isError {
+		t.Fatalf("get_last_n_records failed: %s", text)
+	}
+	for _, want := range []string{"<th>id</th><th>note</th>", "<td>2</td><td>a &amp; b</td>", "at most 1 are returned"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("response %q does not contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, "<script>") {
+		t.Errorf("response %q contains an unescaped script tag", text)
+	}
+
+	text, isError = callTool(t, cfg, "get_first_n_records", map[string]any{"count": 1, "format": "html"})
+	if isError {
+		t.Fatalf("get_first_n_records failed: %s", text)
+	}
+	if !strings.Contains(text, "<td>&lt;script&gt;alert(1)&lt;/script&gt;</td>") {
+		t.Errorf("response %q does not escape the script cell", text)
+	}
+}
+
+func TestGetRecordByID(t *testing.T) {
+	cfg := toolConfig(t, "id,mrn,drug\n1,M1,a\n2,M2,b\n3,M1,c\n4,M1,d\n")
+	const truncated = "more than 2 records have this ID but at most 2 are returned; results truncated"
+
+	tests := []struct {
+		name       string
+		args       map[string]any
+		maxRecords int
+		wantIDs    []string
+		wantNotes  []string
+		wantText   string
+		wantErr    bool
+	}{
+		{name: "found", args: map[string]any{"id_column": "mrn", "id": "M2"}, wantIDs: []string{"2"}},
+		{name: "duplicate returns the first", args: map[string]any{"id_column": "mrn", "id": "M1"}, wantIDs: []string{"1"}},
+		{name: "duplicate with all", args: map[string]any{"id_column": "mrn", "id": "M1", "all": true}, wantIDs: []string{"1", "3", "4"}},
+		{name: "all within the cap", args: map[string]any{"id_column": "mrn", "id": "M1", "all": true}, maxRecords: 3, wantIDs: []string{"1", "3", "4"}},
+		{name: "all over the cap", args: map[string]any{"id_column": "mrn", "id": "M1", "all": true}, maxRecords: 2, wantIDs: []string{"1", "3"}, wantNotes: []string{truncated}},
+		{name: "not found", args: map[string]any{"id_column": "mrn", "id": "M9"}, wantText: `No record with mrn "M9" found.`},
+		{name: "unknown column", args: map[string]any{"id_column": "nope", "id": "M1"}, wantErr: true},
+		{name: "missing id column", args: map[string]any{"id": "M1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.MaxRecords = tt.maxRecords
+			text, isError := callTool(t, cfg, "get_record_by_id", tt.args)
+			if isError != tt.wantErr {
+				t.Fatalf("get_record_by_id = %q, want error %t", text, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantText != "" {
+				if !strings.Contains(text, tt.wantText) {
+					t.Errorf("get_record_by_id = %q, want %q", text, tt.wantText)
+				}
+				return
+			}
+			resp := decodeRecords(t, text)
+			if ids := recordIDs(resp.Records); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", ids, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(resp.Notes, tt.wantNotes) {
+				t.Errorf("notes = %q, want %q", resp.Notes, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestGroupCountLimit(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,B\n3,A\n4,C\n5,B\n6,A\n")
+
+	tests := []struct {
+		name          string
+		limit         int
+		wantValues    []string
+		wantTruncated bool
+	}{
+		{name: "default", wantValues: []string{"A", "B", "C"}},
+		{name: "under the groups", limit: 2, wantValues: []string{"A", "B"}, wantTruncated: true},
+		{name: "at the groups", limit: 3, wantValues: []string{"A", "B", "C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, "group_count", map[string]any{"group_by": "ward", "limit": tt.limit})
+			if isError {
+				t.Fatalf("group_count failed: %s", text)
+			}
+			var resp groupCounts
+			if err := json.Unmarshal([]byte(text), &resp); err != nil {
+				t.Fatalf("decoding %s: %v", text, err)
+			}
+			var values []string
+			for _, group := range resp.Values {
+				values = append(values, group.Value)
+			}
+			if !reflect.DeepEqual(values, tt.wantValues) {
+				t.Errorf("values = %q, want %q", values, tt.wantValues)
+			}
+			if resp.Groups != 3 || resp.Truncated != tt.wantTruncated {
+				t.Errorf("groups = %d, truncated = %v; want 3, %v", resp.Groups, resp.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestStrictLastNRecords(t *testing.T) {
+	tests := []struct {
+		name         string
+		strict       bool
+		tailStrategy string
+	}{
+		{name: "lenient scan"},
+		{name: "lenient seek", tailStrategy: TailStrategySeek},
+		{name: "strict scan", strict: true},
+		{name: "strict seek", strict: true, tailStrategy: TailStrategySeek},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := toolConfig(t, "id,name\n1,a\n2,b,extra\n3,c\n4\n5,e\n")
+			cfg.Strict = tt.strict
+			cfg.TailStrategy = tt.tailStrategy
+
+			text, isError := callTool(t, cfg, "get_last_n_records", map[string]any{"count": 10})
+			if tt.strict {
+				if !isError {
+					t.Errorf("strict get_last_n_records succeeded: %s", text)
+				}
+				return
+			}
+			if isError {
+				t.Fatalf("get_last_n_records failed: %s", text)
+			}
+			resp := decodeRecords(t, text)
+			if ids, want := recordIDs(resp.Records), []string{"1", "3", "5"}; !reflect.DeepEqual(ids, want) {
+				t.Errorf("ids = %q, want %q", ids, want)
+			}
+			if want := []string{"2 malformed rows skipped"}; !reflect.DeepEqual(resp.Notes, want) {
+				t.Errorf("notes = %q, want %q", resp.Notes, want)
+			}
+		})
+	}
+}
+
+func TestLastNRecordsMetadata(t *testing.T) {
+	cfg := toolConfig(t, "id,ward\n1,A\n2,B\n3,A\n4,C\n5,B\n")
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want *recordTotals
+	}{
+		{name: "without metadata", args: map[string]any{"count": 2}},
+		{name: "tail", args: map[string]any{"count": 2, "include_metadata": true}, want: &recordTotals{Total: 5, Returned: 2}},
+		{name: "whole file", args: map[string]any{"count": 10, "include_metadata": true}, want: &recordTotals{Total: 5, Returned: 5}},
+		{name: "sorted", args: map[string]any{"count": 3, "sort_by": "ward", "include_metadata": true}, want: &recordTotals{Total: 5, Returned: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, "get_last_n_records", tt.args)
+			if isError {
+				t.Fatalf("get_last_n_records failed: %s", text)
+			}
+			if resp := decodeRecords(t, text); !reflect.DeepEqual(resp.Metadata, tt.want) {
+				t.Errorf("metadata = %+v, want %+v", resp.Metadata, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoRecordsMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "zero bytes", content: "", want: "No records found: file is empty."},
+		{name: "header only", content: "id,name\n", want: "No records found: no data rows (header only)."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := toolConfig(t, tt.content)
+			for _, tool := range []string{"get_last_n_records", "get_first_n_records"} {
+				text, isError := callTool(t, cfg, tool, map[string]any{"count": 5})
+				if isError || text != tt.want {
+					t.Errorf("%s = %q, want %q", tool, text, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactedTools(t *testing.T) {
+	setReaderOptions(t, tools.ReaderOptions{
+		Aliases: map[string]string{"ssn": "social"},
+		Redact:  map[string]string{"social": tools.RedactLast4},
+	})
+	cfg := toolConfig(t, "id,name,ssn\n1,Ann,123-45-6789\n2,Bob,987-65-4321\n")
+
+	tests := []struct {
+		name string
+		tool string
+		args map[string]any
+	}{
+		{name: "last", tool: "get_last_n_records", args: map[string]any{"count": 5}},
+		{name: "filter", tool: "get_records_where", args: map[string]any{"column": "name", "value": "Ann"}},
+		{name: "search", tool: "search_records", args: map[string]any{"query": "a"}},
+		{name: "export by alias", tool: "export_jsonl", args: map[string]any{"columns": []string{"id", "social"}}},
+		{name: "export by header name", tool: "export_jsonl", args: map[string]any{"columns": []string{"id", "ssn"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, tt.tool, tt.args)
+			if isError {
+				t.Fatalf("%s failed: %s", tt.tool, text)
+			}
+			if strings.Contains(text, "45-6789") || strings.Contains(text, "65-4321") {
+				t.Errorf("%s leaked an ssn: %s", tt.tool, text)
+			}
+			if !strings.Contains(text, tools.RedactedPlaceholder+"6789") {
+				t.Errorf("%s = %s, want the masked ssn", tt.tool, text)
+			}
+		})
+	}
+
+	for _, column := range []string{"ssn", "social"} {
+		text, _ := callTool(t, cfg, "get_records_where", map[string]any{"column": column, "value": "123-45-6789"})
+		if strings.Contains(text, "Ann") {
+			t.Errorf("get_records_where on %s matched the original value: %s", column, text)
+		}
+	}
+}
+
+func TestGetHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "header", content: "id,name\n1,a\n", want: `{"columns":["id","name"]}`},
+		{name: "header only", content: "id,name\n", want: `{"columns":["id","name"]}`},
+		{name: "zero bytes", content: "", want: "No header found: the file is empty."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, toolConfig(t, tt.content), "get_header", map[string]any{})
+			if isError {
+				t.Fatalf("get_header failed: %s", text)
+			}
+			if text != tt.want {
+				t.Errorf("get_header = %s, want %s", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultCount(t *testing.T) {
+	cfg := toolConfig(t, "id\n1\n2\n3\n4\n5\n")
+	cfg.DefaultCount = 3
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    map[string]any
+		wantIDs []string
+		wantErr bool
+	}{
+		{name: "last without count", tool: "get_last_n_records", args: map[string]any{}, wantIDs: []string{"3", "4", "5"}},
+		{name: "last with zero count", tool: "get_last_n_records", args: map[string]any{"count": 0}, wantIDs: []string{"3", "4", "5"}},
+		{name: "last with count", tool: "get_last_n_records", args: map[string]any{"count": 1}, wantIDs: []string{"5"}},
+		{name: "last with negative count", tool: "get_last_n_records", args: map[string]any{"count": -1}, wantErr: true},
+		{name: "first without count", tool: "get_first_n_records", args: map[string]any{}, wantIDs: []string{"1", "2", "3"}},
+		{name: "first with negative count", tool: "get_first_n_records", args: map[string]any{"count": -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isError := callTool(t, cfg, tt.tool, tt.args)
+			if tt.wantErr {
+				if !isError {
+					t.Errorf("%s succeeded: %s", tt.tool, text)
+				}
+				return
+			}
+			if isError {
+				t.Fatalf("%s failed: %s", tt.tool, text)
+			}
+			if ids := recordIDs(decodeRecords(t, text).Records); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetRecordsSince(t *testing.T) {
+	cfg := toolConfig(t, "id\n1\n2\n3\n")
+	path, err := cfg.Datasets.Resolve("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		content  string
+		args     map[string]any
+		wantIDs  []string
+		wantNext cursorInfo
+	}{
+		{name: "from the start", args: map[string]any{}, wantIDs: []string{"1", "2", "3"}, wantNext: cursorInfo{LastSeenLine: 3}},
+		{name: "nothing new", args: map[string]any{"last_seen_line": 3}, wantIDs: []string{}, wantNext: cursorInfo{LastSeenLine: 3}},
+		{name: "appended", content: "id\n1\n2\n3\n4\n5\n6\n", args: map[string]any{"last_seen_line": 3, "limit": 2}, wantIDs: []string{"4", "5"}, wantNext: cursorInfo{LastSeenLine: 5, HasMore: true}},
+		{name: "rest of the append", args: map[string]any{"last_seen_line": 5}, wantIDs: []string{"6"}, wantNext: cursorInfo{LastSeenLine: 6}},
+		{name: "truncated", content: "id\n7\n8\n", args: map[string]any{"last_seen_line": 6}, wantIDs: []string{"7", "8"}, wantNext: cursorInfo{LastSeenLine: 2, Reset: true}},
+		{name: "appended after truncation", content: "id\n7\n8\n9\n", args: map[string]any{"last_seen_line": 2}, wantIDs: []string{"9"}, wantNext: cursorInfo{LastSeenLine: 3}},
+	}
+	for _, step := range steps {
+		if step.content != "" {
+			if err := os.WriteFile(path, []byte(step.content), 0o600); err != nil {
+				t.Fatalf("%s: rewriting fixture: %v", step.name, err)
+			}
+		}
+		text, isError := callTool(t, cfg, "get_records_since", step.args)
+		if isError {
+			t.Fatalf("%s: get_records_since failed: %s", step.name, text)
+		}
+		resp := decodeRecords(t, text)
+		if ids := recordIDs(resp.Records); !reflect.DeepEqual(ids, step.wantIDs) {
+			t.Errorf("%s: ids = %q, want %q", step.name, ids, step.wantIDs)
+		}
+		if resp.Cursor == nil || *resp.Cursor != step.wantNext {
+			t.Errorf("%s: cursor = %+v, want %+v", step.name, resp.Cursor, step.wantNext)
+		}
+	}
+}
+
+func TestGetTailRange(t *testing.T) {
+	cfg := toolConfig(t, "id\n1\n2\n3\n4\n5\n")
+
+	text, isError := callTool(t, cfg, "get_tail_range", map[string]any{"from_end": 1, "count": 2})
+	if isError {
+		t.Fatalf("get_tail_range failed: %s", text)
+	}
+	if ids := recordIDs(decodeRecords(t, text).Records); !reflect.DeepEqual(ids, []string{"3", "4"}) {
+		t.Errorf("ids = %q, want [3 4]", ids)
+	}
+
+	text, isError = callTool(t, cfg, "get_tail_range", map[string]any{"from_end": 5, "count": 2})
+	if want := "No records found: the file has only 5 records."; isError || text != want {
+		t.Errorf("get_tail_range before the first record = %q, want %q", text, want)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestToolSpans(t *testing.T) {
+	// The package tracer delegates to the first provider installed, so the
+	// recorder stays in place for the rest of the test binary.
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	cfg := toolConfig(t, "id,name\n1,a\n2,b\n3,c\n")
+
+	tests := []struct {
+		name       string
+		args       map[string]any
+		wantRows   int64
+		wantStatus codes.Code
+	}{
+		{name: "success", args: map[string]any{"count": 2}, wantRows: 2, wantStatus: codes.Unset},
+		{name: "failure", args: map[string]any{"count": -1}, wantRows: 0, wantStatus: codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(recorder.Ended())
+			callTool(t, cfg, "get_last_n_records", tt.args)
+
+			var span sdktrace.ReadOnlySpan
+			for _, ended := range recorder.Ended()[before:] {
+				if ended.Name() == "tool get_last_n_records" {
+					span = ended
+				}
+			}
+			if span == nil {
+				t.Fatal("no span named \"tool get_last_n_records\" was recorded")
+			}
+
+			attrs := make(map[attribute.Key]attribute.Value)
+			for _, kv := range span.Attributes() {
+				attrs[kv.Key] = kv.Value
+			}
+			if got := attrs["mcp.tool"].AsString(); got != "get_last_n_records" {
+				t.Errorf("mcp.tool = %q, want get_last_n_records", got)
+			}
+			if got, ok := attrs["mcp.rows"]; !ok || got.AsInt64() != tt.wantRows {
+				t.Errorf("mcp.rows = %v, want %d", got.AsInt64(), tt.wantRows)
+			}
+			if got := span.Status().Code; got != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
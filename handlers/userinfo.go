@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/middleware"
+)
+
+// userInfo is the JSON shape returned by UserInfoHandler.
+type userInfo struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Scope   string `json:"scope"`
+}
+
+// UserInfoHandler reports who the caller's token was issued to, taken from
+// the claims stored by the authentication middleware, so a client can check
+// which account it is connected as. It must be chained after that middleware.
+func UserInfoHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := middleware.ClaimsFromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		info := userInfo{Scope: strings.Join(middleware.Scopes(claims), " ")}
+		info.Subject, _ = claims["sub"].(string)
+		info.Email, _ = claims["email"].(string)
+		c.JSON(http.StatusOK, info)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/korjavin/claude_connector/middleware"
+)
+
+func TestUserInfoHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		wantCode int
+		want     userInfo
+	}{
+		{
+			name:     "scope string",
+			claims:   jwt.MapClaims{"sub": "user-1", "email": "ann@example.com", "scope": "csv:read csv:write"},
+			wantCode: http.StatusOK,
+			want:     userInfo{Subject: "user-1", Email: "ann@example.com", Scope: "csv:read csv:write"},
+		},
+		{
+			name:     "scp array without email",
+			claims:   jwt.MapClaims{"sub": "user-2", "scp": []any{"csv:read"}},
+			wantCode: http.StatusOK,
+			want:     userInfo{Subject: "user-2", Scope: "csv:read"},
+		},
+		{
+			name:     "no claims",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/userinfo", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set(middleware.ClaimsKey, tt.claims)
+				}
+			}, UserInfoHandler())
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/userinfo", nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got userInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if got != tt.want {
+				t.Errorf("userinfo = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,51 +1,456 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/korjavin/claude_connector/config"
 	"github.com/korjavin/claude_connector/handlers"
 	"github.com/korjavin/claude_connector/middleware"
+	"github.com/korjavin/claude_connector/tools"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 // CommitSHA will be set at build time via ldflags
 var CommitSHA = "unknown"
 
+// APIVersion is the version of the MCP routes, served under /<APIVersion>/mcp
+// and reported by /health.
+const APIVersion = "v1"
+
 func main() {
-	port := os.Getenv("MCP_SERVER_PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if err := middleware.ValidateAlgorithms(cfg.JWTAlgorithms, cfg.AuthMode == config.AuthModeHMAC); err != nil {
+		log.Fatalf("FATAL: invalid JWT_ALGS: %v", err)
+	}
+
+	logger := newLogger(cfg.LogFormat)
+	// Route the standard library logger through slog as well so every log
+	// line uses the same format.
+	slog.SetDefault(logger)
+
+	tools.SetReaderOptions(tools.ReaderOptions{
+		Comma:        cfg.Delimiter,
+		Comment:      cfg.Comment,
+		OpenRetries:  cfg.OpenRetries,
+		MaxFileBytes: cfg.MaxFileBytes,
+		DateLayout:   cfg.DateLayout,
+		Encoding:     cfg.Encoding,
+		LazyQuotes:   cfg.LazyQuotes,
+		TrimSpace:    cfg.TrimSpace,
+		Redact:       cfg.Redact,
+		Aliases:      cfg.Aliases,
+		Queryable:    cfg.QueryableColumns,
+		FixedWidth:   cfg.FixedWidth,
+	})
+	if cfg.Cache {
+		tools.EnableCache()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx, cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Warn("failed to flush trace spans", "error", err)
+		}
+	}()
+
+	datasets, err := loadDatasets(ctx, cfg)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
 	}
+	go func() {
+		if err := datasets.Watch(ctx); err != nil {
+			slog.Warn("dataset directory changes will not be picked up", "error", err)
+		}
+	}()
 
-	csvPath := os.Getenv("CSV_FILE_PATH")
-	if csvPath == "" {
-		log.Fatal("FATAL: CSV_FILE_PATH environment variable not set.")
+	toolsCfg := handlers.Config{
+		ServerName:    cfg.ServerName,
+		ServerVersion: CommitSHA,
+		Datasets:      datasets,
+		TailStrategy:  cfg.TailStrategy,
+		Strict:        cfg.Strict,
+		Writable:      cfg.Writable,
+		WriteScope:    cfg.WriteScope,
+		MaxRecords:    cfg.MaxRecords,
+		DefaultCount:  cfg.DefaultRecordCount,
+		ToolTimeout:   cfg.ToolTimeout,
+	}
+	if cfg.Writable {
+		toolsCfg.Idempotency = handlers.NewIdempotencyCache(cfg.IdempotencyCacheSize, cfg.IdempotencyTTL)
+	}
+	if cfg.AuditEnabled {
+		toolsCfg.Audit = handlers.NewAuditLogger(openAuditLog(cfg))
+	}
+
+	if cfg.PprofEnabled {
+		go servePprof(ctx, cfg.PprofAddr)
+	}
+
+	if cfg.Transport == config.TransportStdio {
+		// The host process that launched us is the only client, so there are
+		// no tokens and hence no scopes to check.
+		toolsCfg.WriteScope = ""
+		if err := handlers.ServeStdio(ctx, toolsCfg, os.Stdin, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
 	}
 
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
-
-	// Health check endpoint (no authentication required)
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":    "ok",
-			"commit":    CommitSHA,
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
+	srv, err := newServer(cfg, toolsCfg, logger)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatalf("Server error: failed to start server: %v", err)
+	}
+	if err := serve(ctx, srv, listener, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.ShutdownTimeout); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+	log.Printf("Server stopped")
+}
+
+// newServer returns the HTTP server for cfg, serving the MCP tools of
+// toolsCfg along with the health, metrics and administration routes.
+func newServer(cfg *config.Config, toolsCfg handlers.Config, logger *slog.Logger) (*http.Server, error) {
+	router, err := newRouter(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	registry.MustRegister(middleware.MetricsCollectors()...)
+	registry.MustRegister(handlers.MetricsCollectors()...)
+
+	// Metrics endpoint (no authentication required)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	// Liveness check endpoint (no authentication required)
+	router.GET("/health", handlers.HealthHandler(CommitSHA, APIVersion))
+
+	// Readiness probe (no authentication required); unlike /health it fails
+	// when the CSV file cannot be read.
+	router.GET("/ready", handlers.ReadinessHandler(toolsCfg.Datasets))
+
+	// Data freshness report (no authentication required)
+	router.GET("/health/details", handlers.HealthDetailsHandler(toolsCfg.Datasets, cfg.MaxDataAge))
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: router,
+	}
+
+	var authenticate gin.HandlerFunc
+	if cfg.AuthMode == config.AuthModeIntrospect {
+		authenticate = middleware.IntrospectionMiddleware(middleware.IntrospectionConfig{
+			URL:          cfg.IntrospectionURL,
+			ClientID:     cfg.IntrospectionClientID,
+			ClientSecret: cfg.IntrospectionClientSecret,
+			CacheTTL:     cfg.IntrospectionCacheTTL,
+			Audience:     cfg.Audience,
+			Issuer:       cfg.Issuer,
 		})
-	})
+	} else {
+		authenticate = middleware.AuthMiddleware(middleware.AuthConfig{
+			JWKSURL:      cfg.JWKSURL,
+			JWKSCacheTTL: cfg.JWKSCacheTTL,
+			JWKSRetries:  cfg.JWKSRetries,
+			JWKSTimeout:  cfg.JWKSTimeout,
+			Audience:     cfg.Audience,
+			Issuer:       cfg.Issuer,
+			Leeway:       cfg.Leeway,
+			Algorithms:   cfg.JWTAlgorithms,
+			SharedSecret: cfg.JWTSharedSecret,
+		})
+	}
 
-	mcpGroup := router.Group("/mcp")
-	{
-		mcpGroup.Use(middleware.AuthMiddleware())
-		mcpGroup.POST("", handlers.MCPHandler(csvPath))
+	// Identity of the caller's token (authentication required)
+	router.GET("/userinfo", authenticate, handlers.UserInfoHandler())
+
+	// Administration (authentication and the admin scope required)
+	if cfg.AdminScope != "" {
+		router.POST("/admin/reload", authenticate, middleware.RequireScope(cfg.AdminScope), handlers.ReloadHandler(toolsCfg.Datasets))
 	}
 
-	log.Printf("Starting MCP server on port %s (commit: %s)", port, CommitSHA)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Tool catalog (authentication required)
+	router.GET("/tools", middleware.Gzip(), authenticate, handlers.ToolsHandler(toolsCfg))
+
+	// The middleware is created once and shared by both prefixes, so a client
+	// cannot double its rate limit by alternating between them.
+	mcpMiddleware := []gin.HandlerFunc{middleware.MaxBodyBytes(cfg.MaxBodyBytes), authenticate}
+	if cfg.RequiredScope != "" {
+		mcpMiddleware = append(mcpMiddleware, middleware.RequireScope(cfg.RequiredScope))
+	}
+	if cfg.RateLimitRPS > 0 {
+		mcpMiddleware = append(mcpMiddleware, middleware.RateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst))
+	}
+	var mcpHandler gin.HandlerFunc
+	if cfg.Transport != config.TransportSSE {
+		mcpHandler = handlers.MCPHandler(toolsCfg)
+	}
+
+	// The unversioned /mcp prefix is kept for clients configured before
+	// versioning was introduced.
+	for _, prefix := range []string{"/" + APIVersion + "/mcp", "/mcp"} {
+		mcpGroup := router.Group(prefix)
+		mcpGroup.Use(mcpMiddleware...)
+
+		switch cfg.Transport {
+		case config.TransportSSE:
+			sse := handlers.NewSSEServer(toolsCfg, prefix+"/messages")
+			mcpGroup.GET("/sse", sse.StreamHandler())
+			mcpGroup.POST("/messages", middleware.Gzip(), sse.MessageHandler())
+			// Open event streams would otherwise hold up a graceful shutdown.
+			srv.RegisterOnShutdown(sse.Close)
+		default:
+			mcpGroup.POST("", middleware.Gzip(), mcpHandler)
+		}
+	}
+	if cfg.TLSCertFile != "" {
+		srv.TLSConfig = &tls.Config{MinVersion: cfg.TLSMinVersion}
+	}
+	return srv, nil
+}
+
+// newRouter returns a router with the middleware shared by every route.
+func newRouter(cfg *config.Config, logger *slog.Logger) (*gin.Engine, error) {
+	router := gin.New()
+	// Only trusted proxies may set the client IP that rate limiting and
+	// logging see; by default X-Forwarded-For is ignored.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing())
+	router.Use(middleware.Logger(logger))
+	router.Use(middleware.Recovery(logger))
+	router.Use(middleware.Metrics())
+	router.Use(middleware.CORS(cfg.CORSAllowedOrigins))
+	return router, nil
+}
+
+// serve runs srv on listener until ctx is cancelled, then stops accepting
+// connections and waits up to drainTimeout for in-flight requests to
+// complete. When certFile and keyFile are set the server terminates TLS
+// itself.
+func serve(ctx context.Context, srv *http.Server, listener net.Listener, certFile, keyFile string, drainTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			log.Printf("Starting MCP server with TLS on %s (commit: %s)", listener.Addr(), CommitSHA)
+			errCh <- srv.ServeTLS(listener, certFile, keyFile)
+			return
+		}
+		log.Printf("Starting MCP server on %s (commit: %s)", listener.Addr(), CommitSHA)
+		errCh <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", drainTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down cleanly: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// servePprof serves the net/http/pprof profiling handlers on addr until ctx
+// is cancelled. They get their own listener, by default on the loopback
+// interface only, so they are never reachable through the public routes or
+// their authentication.
+func servePprof(ctx context.Context, addr string) {
+	srv := &http.Server{Addr: addr, Handler: pprofMux()}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("Serving pprof", slog.String("addr", addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Warn("pprof server failed", "error", err)
+	}
+}
+
+// staticDatasets returns the datasets named by CSV_FILES along with
+// CSV_FILE_PATH as the default dataset.
+func staticDatasets(cfg *config.Config) (handlers.Datasets, error) {
+	datasets := handlers.Datasets{}
+	for name, path := range cfg.Datasets {
+		datasets[name] = path
+	}
+	if cfg.CSVFilePath != "" {
+		if _, ok := datasets[handlers.DefaultDataset]; ok {
+			return nil, fmt.Errorf("CSV_FILES already defines a %q dataset; unset CSV_FILE_PATH", handlers.DefaultDataset)
+		}
+		datasets[handlers.DefaultDataset] = cfg.CSVFilePath
+	}
+	return datasets, nil
+}
+
+// pprofMux returns a mux serving the net/http/pprof handlers. Importing the
+// package also registers them on http.DefaultServeMux, which no server here
+// uses.
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// loadDatasets builds the dataset registry for cfg and, unless
+// SKIP_STARTUP_CHECK is set, checks that every dataset can be read, so that
+// main exits non-zero on an unreadable one.
+func loadDatasets(ctx context.Context, cfg *config.Config) (*handlers.DatasetRegistry, error) {
+	static, err := staticDatasets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := handlers.NewDatasetRegistry(static, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.SkipStartupCheck {
+		if err := checkDatasets(ctx, datasets.Snapshot()); err != nil {
+			return nil, err
+		}
+	}
+	return datasets, nil
+}
+
+// checkDatasets scans every dataset once, logging its header, its number of
+// rows and any problems found, so that a misconfigured file is noticed at
+// startup rather than on the first tool call. Only a dataset that cannot be
+// read at all is an error.
+func checkDatasets(ctx context.Context, datasets handlers.Datasets) error {
+	for _, name := range datasets.Names() {
+		path := datasets[name]
+		validation, err := tools.ValidateCSV(ctx, path, tools.DefaultValidationProblems)
+		if err != nil {
+			return fmt.Errorf("dataset %q (%s) is unreadable: %w", name, path, err)
+		}
+		slog.Info("dataset checked",
+			slog.String("dataset", name),
+			slog.String("path", path),
+			slog.Any("header", validation.Header),
+			slog.Int("rows", validation.Rows),
+			slog.Int("malformed_rows", validation.MalformedRows),
+		)
+		for _, problem := range validation.Problems {
+			slog.Warn("dataset problem",
+				slog.String("dataset", name),
+				slog.Int("line", problem.Line),
+				slog.String("problem", problem.Problem),
+			)
+		}
+		if validation.Truncated {
+			slog.Warn("dataset has more problems than were logged", slog.String("dataset", name))
+		}
+	}
+	return nil
+}
+
+// openAuditLog opens the audit log sink: AUDIT_LOG_FILE for appending when it
+// is set, otherwise standard output, or standard error under the stdio
+// transport, where standard output carries the protocol.
+func openAuditLog(cfg *config.Config) io.Writer {
+	if cfg.AuditLogFile == "" {
+		if cfg.Transport == config.TransportStdio {
+			return os.Stderr
+		}
+		return os.Stdout
+	}
+	file, err := os.OpenFile(cfg.AuditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		log.Fatalf("FATAL: failed to open audit log: %v", err)
+	}
+	return file
+}
+
+// setupTracing installs an OTLP/HTTP trace exporter when endpoint, the
+// OTEL_EXPORTER_OTLP_ENDPOINT value, is set; otherwise spans stay no-ops. The
+// exporter reads that and the other standard OTEL_* variables itself. The
+// returned function flushes the spans not yet exported.
+func setupTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "claude_connector"),
+			attribute.String("service.version", CommitSHA),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// newLogger builds the structured logger for a validated LOG_FORMAT value.
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil))
 	}
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
 }
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/korjavin/claude_connector/config"
+	"github.com/korjavin/claude_connector/handlers"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestStaticDatasets(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    handlers.Datasets
+		wantErr bool
+	}{
+		{
+			name: "file path only",
+			cfg:  config.Config{CSVFilePath: "records.csv"},
+			want: handlers.Datasets{handlers.DefaultDataset: "records.csv"},
+		},
+		{
+			name: "file path and named datasets",
+			cfg:  config.Config{CSVFilePath: "records.csv", Datasets: map[string]string{"labs": "labs.csv"}},
+			want: handlers.Datasets{handlers.DefaultDataset: "records.csv", "labs": "labs.csv"},
+		},
+		{
+			name: "named default without file path",
+			cfg:  config.Config{Datasets: map[string]string{handlers.DefaultDataset: "labs.csv"}},
+			want: handlers.Datasets{handlers.DefaultDataset: "labs.csv"},
+		},
+		{
+			name:    "file path and named default",
+			cfg:     config.Config{CSVFilePath: "records.csv", Datasets: map[string]string{handlers.DefaultDataset: "labs.csv"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := staticDatasets(&tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("staticDatasets = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("staticDatasets: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staticDatasets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		want       string
+	}{
+		{name: "none trusted", proxies: nil, remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "trusted proxy", proxies: []string{"10.0.0.1"}, remoteAddr: "10.0.0.1:1234", want: "203.0.113.7"},
+		{name: "trusted range", proxies: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", want: "203.0.113.7"},
+		{name: "untrusted peer", proxies: []string{"10.0.0.1"}, remoteAddr: "192.0.2.9:1234", want: "192.0.2.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := newRouter(&config.Config{TrustedProxies: tt.proxies}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("newRouter: %v", err)
+			}
+			router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	if _, err := newRouter(&config.Config{TrustedProxies: []string{"not-an-address"}}, slog.Default()); err == nil {
+		t.Error("newRouter accepted an invalid trusted proxy")
+	}
+}
+
+func TestPprofRoutes(t *testing.T) {
+	router, err := newRouter(&config.Config{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newRouter: %v", err)
+	}
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/symbol"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("public router answered %s with %d, want 404", path, w.Code)
+			}
+
+			w = httptest.NewRecorder()
+			pprofMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("pprof mux answered %s with %d, want 200", path, w.Code)
+			}
+		})
+	}
+}
+
+// startServe runs serve for handler on a loopback listener and returns the
+// server's base URL, a function that triggers the shutdown and a channel
+// receiving the result of serve.
+func startServe(t *testing.T, handler http.Handler, drainTimeout time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, &http.Server{Handler: handler}, listener, "", "", drainTimeout) }()
+	return "http://" + listener.Addr().String(), cancel, served
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	url, shutdown, served := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		io.WriteString(w, "done")
+	}), 5*time.Second)
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+
+	<-entered
+	shutdown()
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned %v while a request was in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-response; body != "done" {
+		t.Errorf("in-flight request got %q, want it to complete", body)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve = %v, want a clean exit", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the shutdown")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after the shutdown")
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	url, shutdown, served := startServe(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}), 50*time.Millisecond)
+
+	go http.Get(url)
+	<-entered
+	shutdown()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("serve = nil, want an error when requests outlast the drain timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not give up after the drain timeout")
+	}
+}
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// testServer returns the handler of the server newServer builds for cfg,
+// serving content as the default dataset and authenticating with a shared
+// secret, along with a bearer token it accepts.
+func testServer(t *testing.T, cfg config.Config, content string) (http.Handler, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "records.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	datasets, err := handlers.NewDatasetRegistry(handlers.Datasets{handlers.DefaultDataset: path}, "")
+	if err != nil {
+		t.Fatalf("NewDatasetRegistry: %v", err)
+	}
+
+	cfg.AuthMode = config.AuthModeHMAC
+	cfg.JWTSharedSecret = testSecret
+	cfg.MaxBodyBytes = 1 << 20
+	toolsCfg := handlers.Config{ServerName: "test", Datasets: datasets, DefaultCount: 10}
+	srv, err := newServer(&cfg, toolsCfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "tester",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return srv.Handler, token
+}
+
+// postMCP posts a JSON-RPC request for method to path on handler.
+func postMCP(t *testing.T, handler http.Handler, path, token, method string, params any) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		t.Fatalf("encoding request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// toolInvocations scrapes /metrics from handler and returns the value of
+// mcp_tool_invocations_total for tool and result, zero when it is absent.
+func toolInvocations(t *testing.T, handler http.Handler, tool, result string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/metrics answered %d", w.Code)
+	}
+	prefix := fmt.Sprintf("mcp_tool_invocations_total{result=%q,tool=%q} ", result, tool)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, prefix); ok {
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("parsing %q: %v", line, err)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestMetricsCountToolCalls(t *testing.T) {
+	handler, token := testServer(t, config.Config{}, "id,name\n1,a\n2,b\n")
+
+	before := toolInvocations(t, handler, "get_last_n_records", "success")
+	w := postMCP(t, handler, "/v1/mcp", token, "tools/call", map[string]any{
+		"name": "get_last_n_records", "arguments": map[string]any{"count": 1},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("tools/call answered %d: %s", w.Code, w.Body)
+	}
+	if after := toolInvocations(t, handler, "get_last_n_records", "success"); after != before+1 {
+		t.Errorf("mcp_tool_invocations_total went from %v to %v, want one more", before, after)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `http_requests_total{`) {
+		t.Errorf("/metrics has no request counter:\n%s", w.Body)
+	}
+}
+
+func TestMCPRoutes(t *testing.T) {
+	handler, token := testServer(t, config.Config{}, "id,name\n1,a\n2,b\n")
+
+	var replies []string
+	for _, path := range []string{"/" + APIVersion + "/mcp", "/mcp"} {
+		w := postMCP(t, handler, path, token, "tools/call", map[string]any{
+			"name": "get_last_n_records", "arguments": map[string]any{"count": 1},
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s answered %d: %s", path, w.Code, w.Body)
+		}
+		if !strings.Contains(w.Body.String(), `\"id\":\"2\"`) {
+			t.Errorf("%s reply %s does not hold the last record", path, w.Body)
+		}
+		replies = append(replies, w.Body.String())
+
+		w = postMCP(t, handler, path, "not-a-token", "tools/list", nil)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a valid token answered %d, want 401", path, w.Code)
+		}
+	}
+	if replies[0] != replies[1] {
+		t.Errorf("routes replied differently:\n%s\n%s", replies[0], replies[1])
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if !strings.Contains(w.Body.String(), `"api_version":"`+APIVersion+`"`) {
+		t.Errorf("/health = %s, want api_version %s", w.Body, APIVersion)
+	}
+}
+
+func TestLoadDatasets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+		return path
+	}
+	good := write("good.csv", "id,name\n1,a\n")
+	ragged := write("ragged.csv", "id,name\n1,a,extra\n2\n")
+	badHeader := write("bad_header.csv", "id,\"name\n1,a\n")
+	missing := filepath.Join(dir, "missing.csv")
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{name: "readable", cfg: config.Config{CSVFilePath: good}},
+		{name: "ragged rows are only logged", cfg: config.Config{CSVFilePath: ragged}},
+		{name: "unparseable header", cfg: config.Config{CSVFilePath: badHeader}, wantErr: true},
+		{name: "missing file", cfg: config.Config{CSVFilePath: missing}, wantErr: true},
+		{name: "missing named dataset", cfg: config.Config{CSVFilePath: good, Datasets: map[string]string{"labs": missing}}, wantErr: true},
+		{name: "check skipped", cfg: config.Config{CSVFilePath: missing, SkipStartupCheck: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadDatasets(context.Background(), &tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadDatasets error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,66 +2,293 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// ClaimsKey is the gin context key under which AuthMiddleware stores the
+// validated jwt.MapClaims of the request's token.
+const ClaimsKey = "claims"
+
+// AuthConfig configures AuthMiddleware.
+type AuthConfig struct {
+	// JWKSURL is where the key set used to verify token signatures is
+	// published.
+	JWKSURL string
+	// JWKSCacheTTL is how long a fetched key set is reused.
+	JWKSCacheTTL time.Duration
+	// JWKSRetries is how many times a failed key set fetch is retried.
+	JWKSRetries int
+	// JWKSTimeout bounds how long a request waits for the key set to be
+	// fetched, retries included. Zero leaves it bounded only by the request.
+	JWKSTimeout time.Duration
+	// Audience, when set, must appear in the token's aud claim.
+	Audience string
+	// Issuer, when set, must equal the token's iss claim.
+	Issuer string
+	// Leeway is the clock skew tolerated when checking the exp, nbf and iat
+	// claims.
+	Leeway time.Duration
+	// Algorithms lists the signing algorithms accepted, such as RS256 or
+	// ES256. Empty means DefaultAlgorithms, or DefaultHMACAlgorithms with a
+	// SharedSecret.
+	Algorithms []string
+	// SharedSecret, when set, verifies tokens signed with HMAC instead of
+	// keys from JWKSURL, which is then not used. Only HMAC algorithms are
+	// accepted in this mode and only asymmetric ones otherwise, so a public
+	// key can never be used as an HMAC secret.
+	SharedSecret []byte
+}
+
+// DefaultAlgorithms are the signing algorithms accepted when
+// AuthConfig.Algorithms is not configured explicitly.
+var DefaultAlgorithms = []string{"RS256"}
+
+// DefaultHMACAlgorithms are the signing algorithms accepted with a shared
+// secret when AuthConfig.Algorithms is not configured explicitly.
+var DefaultHMACAlgorithms = []string{"HS256"}
+
+// AuthMiddleware validates the bearer token of each request against the key
+// set published at cfg.JWKSURL, or against cfg.SharedSecret when set, and
+// checks its audience and issuer.
+func AuthMiddleware(cfg AuthConfig) gin.HandlerFunc {
+	var keys *keySetCache
+	algorithms := cfg.Algorithms
+	if len(cfg.SharedSecret) > 0 {
+		if len(algorithms) == 0 {
+			algorithms = DefaultHMACAlgorithms
+		}
+	} else {
+		keys = newKeySetCache(cfg.JWKSURL, cfg.JWKSCacheTTL, cfg.JWKSRetries)
+		if len(algorithms) == 0 {
+			algorithms = DefaultAlgorithms
+		}
+	}
+
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		tokenString, ok := bearerToken(c)
+		if !ok {
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format. Use 'Bearer <token>'"})
-			return
+		ctx, span := tracer.Start(c.Request.Context(), "auth.validate_token")
+		defer endAuthSpan(c, span)
+
+		var keyFunc jwt.Keyfunc
+		if keys == nil {
+			keyFunc = sharedSecretKeyFunc(cfg.SharedSecret)
+		} else {
+			if cfg.JWKSTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, cfg.JWKSTimeout)
+				defer cancel()
+			}
+
+			keySet, err := keys.Get(ctx)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					abortWithError(c, http.StatusServiceUnavailable, gin.H{"error": "Timed out fetching JWKS"})
+					return
+				}
+				abortWithError(c, http.StatusInternalServerError, gin.H{"error": "Failed to fetch JWKS"})
+				return
+			}
+			keyFunc = jwksKeyFunc(ctx, keys, keySet)
 		}
 
-		tokenString := parts[1]
+		token, err := jwt.Parse(tokenString, keyFunc, jwt.WithValidMethods(algorithms), jwt.WithoutClaimsValidation())
 
-		keySet, err := jwk.Fetch(context.Background(), "http://hydra:4444/.well-known/jwks.json")
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch JWKS"})
+			abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			kid, ok := token.Header["kid"].(string)
-			if !ok {
-				return nil, fmt.Errorf("kid header not found")
-			}
-			keys, ok := keySet.LookupKeyID(kid)
-			if !ok {
+		if !token.Valid {
+			abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+		if err := validateTimeClaims(claims, time.Now(), cfg.Leeway); err != nil {
+			abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
+			return
+		}
+		if !verifyAudienceAndIssuer(c, claims, cfg.Audience, cfg.Issuer) {
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		span.End()
+
+		c.Next()
+	}
+}
+
+// endAuthSpan ends the span of a token check, marking it failed when the
+// token was rejected. The span of an accepted token has already ended before
+// the rest of the chain ran, so ending it again has no effect.
+func endAuthSpan(c *gin.Context, span trace.Span) {
+	if c.IsAborted() {
+		span.SetStatus(codes.Error, "token rejected")
+	}
+	span.End()
+}
+
+// jwksKeyFunc returns a jwt.Keyfunc that looks the token's key up by kid in
+// keySet, refreshing the set once if the key is missing.
+func jwksKeyFunc(ctx context.Context, keys *keySetCache, keySet jwk.Set) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("kid header not found")
+		}
+		key, ok := keySet.LookupKeyID(kid)
+		if !ok {
+			// The signing key may have been rotated since the set was cached.
+			refreshed, err := keys.Refresh(ctx)
+			if err != nil {
 				return nil, fmt.Errorf("key with kid %s not found", kid)
 			}
-			var pubkey interface{}
-			if err := keys.Raw(&pubkey); err != nil {
-				return nil, fmt.Errorf("failed to get raw public key")
+			if key, ok = refreshed.LookupKeyID(kid); !ok {
+				return nil, fmt.Errorf("key with kid %s not found", kid)
 			}
-			return pubkey, nil
-		})
+		}
+		if err := checkKeyAlgorithm(key, token.Method); err != nil {
+			return nil, err
+		}
+		var pubkey interface{}
+		if err := key.Raw(&pubkey); err != nil {
+			return nil, fmt.Errorf("failed to get raw public key")
+		}
+		return pubkey, nil
+	}
+}
 
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
-			return
+// sharedSecretKeyFunc returns a jwt.Keyfunc that verifies HMAC tokens with
+// secret.
+func sharedSecretKeyFunc(secret []byte) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
+		return secret, nil
+	}
+}
 
-		if !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
+// ValidateAlgorithms checks that every name is a signing algorithm
+// AuthMiddleware can verify: an HMAC algorithm when hmac is set, and an
+// asymmetric one otherwise. none is always refused, and HMAC is refused with
+// a key set because verifying it with a public key would let anyone who has
+// the key forge tokens.
+func ValidateAlgorithms(names []string, hmac bool) error {
+	for _, name := range names {
+		method := jwt.GetSigningMethod(name)
+		if method == nil {
+			return fmt.Errorf("unknown signing algorithm %q", name)
+		}
+		_, isHMAC := method.(*jwt.SigningMethodHMAC)
+		_, isAsymmetric := keyTypeFor(method)
+		if (hmac && !isHMAC) || (!hmac && !isAsymmetric) {
+			return fmt.Errorf("signing algorithm %q is not supported in this mode", name)
 		}
+	}
+	return nil
+}
 
-		c.Next()
+// keyTypeFor returns the JWK key type that verifies method.
+func keyTypeFor(method jwt.SigningMethod) (jwa.KeyType, bool) {
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		return jwa.RSA, true
+	case *jwt.SigningMethodECDSA:
+		return jwa.EC, true
+	case *jwt.SigningMethodEd25519:
+		return jwa.OKP, true
+	}
+	return "", false
+}
+
+// checkKeyAlgorithm guards against algorithm confusion by requiring that the
+// key's type suits the token's signing method and, when the key names an
+// algorithm, that it is the one the token claims.
+func checkKeyAlgorithm(key jwk.Key, method jwt.SigningMethod) error {
+	keyType, ok := keyTypeFor(method)
+	if !ok || key.KeyType() != keyType {
+		return fmt.Errorf("key %s of type %s cannot verify %s tokens", key.KeyID(), key.KeyType(), method.Alg())
+	}
+	if alg := key.Algorithm(); alg != "" && alg != method.Alg() {
+		return fmt.Errorf("key %s is for %s, not %s", key.KeyID(), alg, method.Alg())
+	}
+	return nil
+}
+
+// bearerToken returns the bearer token of the request, aborting it with 401
+// when the Authorization header is missing or malformed.
+func bearerToken(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+		return "", false
+	}
+
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format. Use 'Bearer <token>'"})
+		return "", false
+	}
+	return parts[1], true
+}
+
+// verifyAudienceAndIssuer checks the aud and iss claims against the expected
+// values, when they are set, aborting the request with 403 on a mismatch.
+func verifyAudienceAndIssuer(c *gin.Context, claims jwt.MapClaims, audience, issuer string) bool {
+	if audience != "" && !claims.VerifyAudience(audience, true) {
+		abortWithError(c, http.StatusForbidden, gin.H{"error": "Wrong audience", "details": fmt.Sprintf("token is not intended for audience %q", audience)})
+		return false
+	}
+	if issuer != "" && !claims.VerifyIssuer(issuer, true) {
+		abortWithError(c, http.StatusForbidden, gin.H{"error": "Wrong issuer", "details": fmt.Sprintf("token was not issued by %q", issuer)})
+		return false
+	}
+	return true
+}
+
+// validateTimeClaims checks the exp, nbf and iat claims against now, allowing
+// for leeway of clock skew in either direction. The claims are optional.
+func validateTimeClaims(claims jwt.MapClaims, now time.Time, leeway time.Duration) error {
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), false) {
+		return fmt.Errorf("token is expired")
+	}
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	if !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return fmt.Errorf("token used before issued")
+	}
+	return nil
+}
+
+// ClaimsFromContext returns the validated claims AuthMiddleware stored for the
+// request, if any.
+func ClaimsFromContext(c *gin.Context) (jwt.MapClaims, bool) {
+	value, ok := c.Get(ClaimsKey)
+	if !ok {
+		return nil, false
 	}
+	claims, ok := value.(jwt.MapClaims)
+	return claims, ok
 }
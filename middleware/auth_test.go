@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// signHMAC returns claims signed with testSecret.
+func signHMAC(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+// serve sends req through a router running handlers before an endpoint that
+// answers 200, and returns the response.
+func serve(req *http.Request, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Any("/", append(handlers, func(c *gin.Context) { c.String(http.StatusOK, "ok") })...)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddlewareLeeway(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		leeway time.Duration
+		want   int
+	}{
+		{name: "expired within leeway", claims: jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}, leeway: 30 * time.Second, want: http.StatusOK},
+		{name: "expired without leeway", claims: jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}, leeway: 0, want: http.StatusUnauthorized},
+		{name: "expired beyond leeway", claims: jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}, leeway: 30 * time.Second, want: http.StatusUnauthorized},
+		{name: "not yet valid within leeway", claims: jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(10 * time.Second).Unix()}, leeway: 30 * time.Second, want: http.StatusOK},
+		{name: "not yet valid without leeway", claims: jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(10 * time.Second).Unix()}, leeway: 0, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := AuthMiddleware(AuthConfig{SharedSecret: testSecret, Leeway: tt.leeway})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signHMAC(t, tt.claims))
+
+			if w := serve(req, auth); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareAudienceIssuer(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{name: "matching claims", claims: jwt.MapClaims{"exp": exp, "aud": "connector", "iss": "https://issuer"}, want: http.StatusOK},
+		{name: "audience in a list", claims: jwt.MapClaims{"exp": exp, "aud": []string{"other", "connector"}, "iss": "https://issuer"}, want: http.StatusOK},
+		{name: "wrong audience", claims: jwt.MapClaims{"exp": exp, "aud": "other", "iss": "https://issuer"}, want: http.StatusForbidden},
+		{name: "missing audience", claims: jwt.MapClaims{"exp": exp, "iss": "https://issuer"}, want: http.StatusForbidden},
+		{name: "wrong issuer", claims: jwt.MapClaims{"exp": exp, "aud": "connector", "iss": "https://other"}, want: http.StatusForbidden},
+		{name: "missing issuer", claims: jwt.MapClaims{"exp": exp, "aud": "connector"}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := AuthMiddleware(AuthConfig{SharedSecret: testSecret, Audience: "connector", Issuer: "https://issuer"})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signHMAC(t, tt.claims))
+
+			w := serve(req, auth)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != "ok" {
+				t.Errorf("body = %q, want the handler to run", w.Body)
+			}
+		})
+	}
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	router := gin.New()
+	router.GET("/", AuthMiddleware(AuthConfig{SharedSecret: testSecret}), func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			c.String(http.StatusInternalServerError, "no claims")
+			return
+		}
+		sub, _ := claims["sub"].(string)
+		c.String(http.StatusOK, sub)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+signHMAC(t, jwt.MapClaims{"sub": "clinician-7", "exp": time.Now().Add(time.Hour).Unix()}))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "clinician-7" {
+		t.Errorf("got %d %q, want 200 with the sub claim", w.Code, w.Body)
+	}
+}
+
+func TestClaimsFromContextWithoutAuth(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if claims, ok := ClaimsFromContext(c); ok {
+		t.Errorf("ClaimsFromContext = %v, want none", claims)
+	}
+}
+
+// signingKey is a private key and the public JWK that verifies it.
+type signingKey struct {
+	private crypto.Signer
+	public  jwk.Key
+}
+
+// newSigningKey wraps the public half of private as a JWK with the given key
+// ID.
+func newSigningKey(t *testing.T, kid string, private crypto.Signer) signingKey {
+	t.Helper()
+	key, err := jwk.New(private.Public())
+	if err != nil {
+		t.Fatalf("wrapping key: %v", err)
+	}
+	if err := key.Set(jwk.KeyIDKey, kid); err != nil {
+		t.Fatalf("setting key ID: %v", err)
+	}
+	return signingKey{private: private, public: key}
+}
+
+// sign returns claims signed by key with method, naming kid in the header.
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing %s token: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+func TestAuthMiddlewareAlgorithms(t *testing.T) {
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating EC key: %v", err)
+	}
+	rsaKey := newSigningKey(t, "rsa", rsaPrivate)
+	ecKey := newSigningKey(t, "ec", ecPrivate)
+	pinned := newSigningKey(t, "pinned", rsaPrivate)
+	if err := pinned.public.Set(jwk.AlgorithmKey, "RS512"); err != nil {
+		t.Fatalf("setting key algorithm: %v", err)
+	}
+	server := newJWKSServer(t, rsaKey.public, ecKey.public, pinned.public)
+
+	rsaPEM, err := x509.MarshalPKIXPublicKey(rsaPrivate.Public())
+	if err != nil {
+		t.Fatalf("encoding RSA public key: %v", err)
+	}
+	claims := jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name       string
+		algorithms []string
+		token      string
+		want       int
+	}{
+		{name: "RS256 with an RSA key", token: sign(t, jwt.SigningMethodRS256, "rsa", rsaPrivate, claims), want: http.StatusOK},
+		{name: "ES256 with an EC key", algorithms: []string{"ES256"}, token: sign(t, jwt.SigningMethodES256, "ec", ecPrivate, claims), want: http.StatusOK},
+		{name: "ES256 naming an RSA key", algorithms: []string{"RS256", "ES256"}, token: sign(t, jwt.SigningMethodES256, "rsa", ecPrivate, claims), want: http.StatusUnauthorized},
+		{name: "RS256 naming an EC key", algorithms: []string{"RS256", "ES256"}, token: sign(t, jwt.SigningMethodRS256, "ec", rsaPrivate, claims), want: http.StatusUnauthorized},
+		{name: "RS256 with a key pinned to RS512", token: sign(t, jwt.SigningMethodRS256, "pinned", rsaPrivate, claims), want: http.StatusUnauthorized},
+		{name: "ES256 not in the accepted algorithms", token: sign(t, jwt.SigningMethodES256, "ec", ecPrivate, claims), want: http.StatusUnauthorized},
+		{name: "HS256 keyed with the public key", token: sign(t, jwt.SigningMethodHS256, "rsa", rsaPEM, claims), want: http.StatusUnauthorized},
+		{name: "HS256 even when accepted", algorithms: []string{"RS256", "HS256"}, token: sign(t, jwt.SigningMethodHS256, "rsa", rsaPEM, claims), want: http.StatusUnauthorized},
+		{name: "alg none", token: sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims), want: http.StatusUnauthorized},
+		{name: "alg none even when accepted", algorithms: []string{"none"}, token: sign(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims), want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := AuthMiddleware(AuthConfig{JWKSURL: server.URL, JWKSCacheTTL: time.Minute, Algorithms: tt.algorithms})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			if w := serve(req, auth); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestValidateAlgorithms(t *testing.T) {
+	tests := []struct {
+		names   []string
+		hmac    bool
+		wantErr bool
+	}{
+		{names: []string{"RS256", "ES256", "PS256", "EdDSA"}},
+		{names: []string{"RS256", "HS256"}, wantErr: true},
+		{names: []string{"none"}, wantErr: true},
+		{names: []string{"none"}, hmac: true, wantErr: true},
+		{names: []string{"XS256"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := ValidateAlgorithms(tt.names, tt.hmac)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAlgorithms(%q, %t) = %v, want error %t", tt.names, tt.hmac, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAuthMiddlewareSharedSecret(t *testing.T) {
+	server := newJWKSServer(t, newRSAKey(t, "rsa"))
+	token := sign(t, jwt.SigningMethodHS256, "rsa", testSecret, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name string
+		cfg  AuthConfig
+		want int
+	}{
+		{name: "hmac mode", cfg: AuthConfig{SharedSecret: testSecret}, want: http.StatusOK},
+		{name: "hmac mode with the wrong secret", cfg: AuthConfig{SharedSecret: []byte("another secret of thirty-two bytes")}, want: http.StatusUnauthorized},
+		{name: "hmac mode refusing HS256", cfg: AuthConfig{SharedSecret: testSecret, Algorithms: []string{"HS512"}}, want: http.StatusUnauthorized},
+		{name: "jwks mode", cfg: AuthConfig{JWKSURL: server.URL, JWKSCacheTTL: time.Minute}, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			if w := serve(req, AuthMiddleware(tt.cfg)); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestValidateAlgorithmsHMAC(t *testing.T) {
+	if err := ValidateAlgorithms([]string{"HS256", "HS512"}, true); err != nil {
+		t.Errorf("ValidateAlgorithms(HS256, HS512) in hmac mode = %v", err)
+	}
+	if err := ValidateAlgorithms([]string{"RS256"}, true); err == nil {
+		t.Error("ValidateAlgorithms(RS256) in hmac mode succeeded")
+	}
+}
+
+func TestAuthMiddlewareJWKSTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+	failing := newJWKSServer(t)
+	failing.setFailing(true)
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{name: "slow endpoint", url: slow.URL, want: http.StatusServiceUnavailable},
+		{name: "failing endpoint", url: failing.URL, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := AuthMiddleware(AuthConfig{JWKSURL: tt.url, JWKSCacheTTL: time.Minute, JWKSTimeout: 100 * time.Millisecond})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signHMAC(t, jwt.MapClaims{}))
+
+			start := time.Now()
+			w := serve(req, auth)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("request took %v, want it cut off near the JWKS timeout", elapsed)
+			}
+		})
+	}
+}
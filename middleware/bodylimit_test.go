@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+	const limit = 8
+	router := gin.New()
+	router.POST("/", MaxBodyBytes(limit), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+
+	tests := []struct {
+		name string
+		body string
+		// unknownLength sends the body without a Content-Length, as a
+		// chunked request would.
+		unknownLength bool
+		want          int
+	}{
+		{name: "empty", body: "", want: http.StatusOK},
+		{name: "under the limit", body: "1234567", want: http.StatusOK},
+		{name: "at the limit", body: "12345678", want: http.StatusOK},
+		{name: "over the limit", body: "123456789", want: http.StatusRequestEntityTooLarge},
+		{name: "at the limit without length", body: "12345678", unknownLength: true, want: http.StatusOK},
+		{name: "over the limit without length", body: "123456789", unknownLength: true, want: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code == http.StatusOK && w.Body.String() != tt.body {
+				t.Errorf("handler read %q, want the whole body %q", w.Body, tt.body)
+			}
+			if w.Code == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), `"limit":8`) {
+				t.Errorf("413 body = %s, want it to state the limit", w.Body)
+			}
+		})
+	}
+}
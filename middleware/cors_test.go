@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	const origin = "https://app.example.com"
+	tests := []struct {
+		name        string
+		allowed     []string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods bool
+	}{
+		{name: "preflight", allowed: []string{origin}, method: http.MethodOptions, origin: origin, preflight: true, wantStatus: http.StatusNoContent, wantOrigin: origin, wantMethods: true},
+		{name: "cross-origin post", allowed: []string{origin}, method: http.MethodPost, origin: origin, wantStatus: http.StatusOK, wantOrigin: origin},
+		{name: "wildcard", allowed: []string{"*"}, method: http.MethodPost, origin: origin, wantStatus: http.StatusOK, wantOrigin: origin},
+		{name: "origin not allowed", allowed: []string{"https://other.example.com"}, method: http.MethodPost, origin: origin, wantStatus: http.StatusOK},
+		{name: "preflight from origin not allowed", allowed: []string{"https://other.example.com"}, method: http.MethodOptions, origin: origin, preflight: true, wantStatus: http.StatusOK},
+		{name: "no origins configured", method: http.MethodPost, origin: origin, wantStatus: http.StatusOK},
+		{name: "same origin", allowed: []string{origin}, method: http.MethodPost, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "Authorization")
+			}
+
+			w := serve(req, CORS(tt.allowed))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			allowHeaders := w.Header().Get("Access-Control-Allow-Headers")
+			if tt.wantMethods {
+				if w.Header().Get("Access-Control-Allow-Methods") == "" {
+					t.Error("preflight response has no Access-Control-Allow-Methods")
+				}
+				if !strings.Contains(allowHeaders, "Authorization") {
+					t.Errorf("Access-Control-Allow-Headers = %q, want it to include Authorization", allowHeaders)
+				}
+			} else if allowHeaders != "" {
+				t.Errorf("Access-Control-Allow-Headers = %q on a response that is not an allowed preflight", allowHeaders)
+			}
+			if len(tt.allowed) == 0 {
+				for name := range w.Header() {
+					if strings.HasPrefix(name, "Access-Control-") || name == "Vary" {
+						t.Errorf("header %s emitted with no origins configured", name)
+					}
+				}
+			}
+		})
+	}
+}
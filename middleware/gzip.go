@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipMinSize is the smallest response body Gzip compresses. Smaller bodies
+// barely shrink and are sent as they are.
+const gzipMinSize = 1024
+
+// Gzip compresses response bodies of at least gzipMinSize bytes for clients
+// whose Accept-Encoding allows gzip. Output is buffered until the threshold is
+// reached, so it must not be used on streaming endpoints.
+func Gzip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer w.finish()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header value allows gzip.
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// gzipWriter holds back the response body until it is known to be large
+// enough to compress.
+type gzipWriter struct {
+	gin.ResponseWriter
+	buf []byte
+	gz  *gzip.Writer
+	// plain is set once the body is being passed through uncompressed.
+	plain bool
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.plain:
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) < gzipMinSize {
+		return len(b), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// start writes the buffered body, compressing it unless the handler already
+// chose a content encoding.
+func (w *gzipWriter) start() error {
+	buf := w.buf
+	w.buf = nil
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" {
+		w.plain = true
+		_, err := w.ResponseWriter.Write(buf)
+		return err
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// finish flushes whatever the handler wrote: the compressed stream if it was
+// large enough, the buffered body as it is otherwise.
+func (w *gzipWriter) finish() {
+	if w.gz != nil {
+		w.gz.Close()
+		return
+	}
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// Written reports whether the handler has written a body or headers.
+func (w *gzipWriter) Written() bool {
+	return w.ResponseWriter.Written() || len(w.buf) > 0
+}
+
+// Flush sends the compressed output so far. Until compression has started
+// the body stays buffered, so it does nothing.
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+		w.ResponseWriter.Flush()
+	}
+}
+
+var _ http.Flusher = (*gzipWriter)(nil)
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat("heart_rate,72,bpm\n", 200)
+	small := "ok"
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		// encoded is a Content-Encoding the handler sets itself.
+		encoded    string
+		wantGzip   bool
+		wantHeader string
+	}{
+		{name: "large body", acceptEncoding: "gzip", body: large, wantGzip: true},
+		{name: "large body among codings", acceptEncoding: "br, gzip;q=0.8", body: large, wantGzip: true},
+		{name: "small body", acceptEncoding: "gzip", body: small},
+		{name: "gzip not accepted", acceptEncoding: "br", body: large},
+		{name: "gzip refused with q=0", acceptEncoding: "gzip;q=0", body: large},
+		{name: "no Accept-Encoding", body: large},
+		{name: "already encoded", acceptEncoding: "gzip", body: large, encoded: "identity", wantHeader: "identity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", Gzip(), func(c *gin.Context) {
+				if tt.encoded != "" {
+					c.Header("Content-Encoding", tt.encoded)
+				}
+				// Write in pieces so the body crosses the threshold mid-way.
+				for _, line := range strings.SplitAfter(tt.body, "\n") {
+					c.Writer.WriteString(line)
+				}
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", vary)
+			}
+			body := w.Body.String()
+			wantHeader := tt.wantHeader
+			if tt.wantGzip {
+				wantHeader = "gzip"
+				gz, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("response is not gzip: %v", err)
+				}
+				decoded, err := io.ReadAll(gz)
+				if err != nil {
+					t.Fatalf("decompressing: %v", err)
+				}
+				body = string(decoded)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != wantHeader {
+				t.Errorf("Content-Encoding = %q, want %q", got, wantHeader)
+			}
+			if body != tt.body {
+				t.Errorf("body is %d bytes, want the %d the handler wrote", len(body), len(tt.body))
+			}
+		})
+	}
+}
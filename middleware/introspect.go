@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// introspectionTimeout bounds a single call to the introspection endpoint.
+const introspectionTimeout = 5 * time.Second
+
+// IntrospectionConfig configures IntrospectionMiddleware.
+type IntrospectionConfig struct {
+	// URL is the RFC 7662 token introspection endpoint.
+	URL string
+	// ClientID and ClientSecret authenticate the connector to the endpoint
+	// with HTTP Basic authentication. They are optional.
+	ClientID     string
+	ClientSecret string
+	// CacheTTL is how long a token found active is trusted without asking
+	// the endpoint again. Zero disables caching.
+	CacheTTL time.Duration
+	// Audience, when set, must appear in the token's aud claim.
+	Audience string
+	// Issuer, when set, must equal the token's iss claim.
+	Issuer string
+}
+
+// IntrospectionMiddleware validates opaque bearer tokens by asking the
+// introspection endpoint at cfg.URL whether they are active. The introspection
+// response is stored as the request's claims, so RequireScope and the tools
+// see the same scope, sub and exp values as for a JWT.
+func IntrospectionMiddleware(cfg IntrospectionConfig) gin.HandlerFunc {
+	client := &http.Client{}
+	cache := &introspectionCache{entries: make(map[[sha256.Size]byte]introspectionEntry)}
+
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+
+		ctx, span := tracer.Start(c.Request.Context(), "auth.introspect_token")
+		defer endAuthSpan(c, span)
+
+		claims, ok := cache.get(tokenString, time.Now())
+		span.SetAttributes(attribute.Bool("introspection.cached", ok))
+		if !ok {
+			ctx, cancel := context.WithTimeout(ctx, introspectionTimeout)
+			var err error
+			claims, err = introspect(ctx, client, cfg, tokenString)
+			cancel()
+			if err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					abortWithError(c, http.StatusServiceUnavailable, gin.H{"error": "Timed out introspecting token"})
+					return
+				}
+				abortWithError(c, http.StatusInternalServerError, gin.H{"error": "Failed to introspect token"})
+				return
+			}
+			if active, _ := claims["active"].(bool); !active {
+				abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": "token is not active"})
+				return
+			}
+			cache.put(tokenString, claims, time.Now(), cfg.CacheTTL)
+		}
+
+		if !verifyAudienceAndIssuer(c, claims, cfg.Audience, cfg.Issuer) {
+			return
+		}
+
+		c.Set(ClaimsKey, claims)
+		span.End()
+
+		c.Next()
+	}
+}
+
+// introspect posts token to the introspection endpoint and returns the
+// decoded response.
+func introspect(ctx context.Context, client *http.Client, cfg IntrospectionConfig, token string) (jwt.MapClaims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned %s", resp.Status)
+	}
+	var claims jwt.MapClaims
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+	return claims, nil
+}
+
+// introspectionCache remembers active introspection results, keyed by a hash
+// of the token so the tokens themselves are not kept in memory.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]introspectionEntry
+}
+
+type introspectionEntry struct {
+	claims  jwt.MapClaims
+	expires time.Time
+}
+
+func (c *introspectionCache) get(token string, now time.Time) (jwt.MapClaims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[sha256.Sum256([]byte(token))]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+// put caches claims for ttl, or until the token expires if that is sooner.
+// Expired entries are swept on each insert.
+func (c *introspectionCache) put(token string, claims jwt.MapClaims, now time.Time, ttl time.Duration) {
+	expires := now.Add(ttl)
+	if exp, ok := claims["exp"].(float64); ok {
+		if tokenExpiry := time.Unix(int64(exp), 0); tokenExpiry.Before(expires) {
+			expires = tokenExpiry
+		}
+	}
+	if !now.Before(expires) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[sha256.Sum256([]byte(token))] = introspectionEntry{claims: claims, expires: expires}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// introspectionServer is a fake RFC 7662 endpoint that answers every token
+// with response and records how it was called.
+type introspectionServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	calls    int
+	token    string
+	user     string
+	password string
+}
+
+func newIntrospectionServer(t *testing.T, status int, response map[string]any) *introspectionServer {
+	t.Helper()
+	s := &introspectionServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls++
+		s.token = r.PostFormValue("token")
+		s.user, s.password, _ = r.BasicAuth()
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *introspectionServer) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// subject is an endpoint that answers with the sub claim of the request.
+func subject(c *gin.Context) {
+	claims, _ := ClaimsFromContext(c)
+	sub, _ := claims["sub"].(string)
+	c.String(http.StatusOK, sub)
+}
+
+func TestIntrospectionMiddleware(t *testing.T) {
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	tests := []struct {
+		name     string
+		status   int
+		response map[string]any
+		audience string
+		want     int
+		wantBody string
+	}{
+		{name: "active", status: http.StatusOK, response: map[string]any{"active": true, "sub": "clinician-7", "exp": exp}, want: http.StatusOK, wantBody: "clinician-7"},
+		{name: "inactive", status: http.StatusOK, response: map[string]any{"active": false}, want: http.StatusUnauthorized},
+		{name: "active flag missing", status: http.StatusOK, response: map[string]any{"sub": "clinician-7"}, want: http.StatusUnauthorized},
+		{name: "wrong audience", status: http.StatusOK, response: map[string]any{"active": true, "aud": "other"}, audience: "connector", want: http.StatusForbidden},
+		{name: "endpoint failing", status: http.StatusInternalServerError, response: map[string]any{}, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newIntrospectionServer(t, tt.status, tt.response)
+			router := gin.New()
+			router.GET("/", IntrospectionMiddleware(IntrospectionConfig{URL: server.URL, Audience: tt.audience}), subject)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer opaque-token")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+			if server.token != "opaque-token" {
+				t.Errorf("endpoint was asked about %q, want the bearer token", server.token)
+			}
+		})
+	}
+}
+
+func TestIntrospectionClientCredentials(t *testing.T) {
+	server := newIntrospectionServer(t, http.StatusOK, map[string]any{"active": true})
+	auth := IntrospectionMiddleware(IntrospectionConfig{URL: server.URL, ClientID: "csv connector", ClientSecret: "p@ss:word"})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer opaque-token")
+	if w := serve(req, auth); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if server.user != "csv+connector" || server.password != "p%40ss%3Aword" {
+		t.Errorf("basic auth = %q:%q, want the query-escaped client credentials", server.user, server.password)
+	}
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	server := newIntrospectionServer(t, http.StatusOK, map[string]any{"active": true, "exp": exp})
+	auth := IntrospectionMiddleware(IntrospectionConfig{URL: server.URL, CacheTTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer opaque-token")
+		if w := serve(req, auth); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d: %s", i, w.Code, w.Body)
+		}
+	}
+	if got := server.callCount(); got != 1 {
+		t.Errorf("endpoint called %d times, want 1 with caching", got)
+	}
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// minJWKSRefreshInterval limits how often a token signed by an unknown key can
+// force the key set to be fetched again ahead of its TTL.
+const minJWKSRefreshInterval = time.Minute
+
+const (
+	// jwksAttemptTimeout bounds a single fetch so that a hung endpoint still
+	// leaves time for the retries.
+	jwksAttemptTimeout = 2 * time.Second
+	// jwksRetryBackoff is the delay before the first retry; it doubles on
+	// each further retry.
+	jwksRetryBackoff = 200 * time.Millisecond
+)
+
+// keySetCache holds the key set served at url and refreshes it once ttl has
+// elapsed. If a refresh fails, the last key set that was fetched successfully
+// keeps being served.
+type keySetCache struct {
+	url     string
+	ttl     time.Duration
+	retries int
+
+	// sem is a one-slot semaphore used as a mutex that waiters can abandon
+	// when their context ends, rather than queueing behind a slow fetch.
+	sem       chan struct{}
+	keySet    jwk.Set
+	fetchedAt time.Time
+}
+
+func newKeySetCache(url string, ttl time.Duration, retries int) *keySetCache {
+	return &keySetCache{url: url, ttl: ttl, retries: retries, sem: make(chan struct{}, 1)}
+}
+
+// Get returns the cached key set, fetching it first if it has expired.
+func (c *keySetCache) Get(ctx context.Context) (jwk.Set, error) {
+	if err := c.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	if c.keySet != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.keySet, nil
+	}
+	return c.refreshLocked(ctx)
+}
+
+// Refresh fetches the key set again, for example after a token arrives signed
+// by a key that is not in the cached set. It is rate limited so that such
+// tokens cannot be used to hammer the JWKS endpoint.
+func (c *keySetCache) Refresh(ctx context.Context) (jwk.Set, error) {
+	if err := c.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	if c.keySet != nil && time.Since(c.fetchedAt) < minJWKSRefreshInterval {
+		return c.keySet, nil
+	}
+	return c.refreshLocked(ctx)
+}
+
+func (c *keySetCache) lock(ctx context.Context) error {
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *keySetCache) unlock() {
+	<-c.sem
+}
+
+func (c *keySetCache) refreshLocked(ctx context.Context) (jwk.Set, error) {
+	keySet, err := c.fetch(ctx)
+	if err != nil {
+		if c.keySet == nil {
+			return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", c.url, err)
+		}
+		slog.Warn("failed to refresh JWKS, using cached keys", slog.String("url", c.url), "error", err)
+		return c.keySet, nil
+	}
+
+	c.keySet = keySet
+	c.fetchedAt = time.Now()
+	return keySet, nil
+}
+
+// fetch fetches the key set, retrying failed attempts up to c.retries times
+// with exponential backoff and jitter so that a brief outage of the endpoint,
+// such as a Hydra restart, does not fail requests.
+func (c *keySetCache) fetch(ctx context.Context) (jwk.Set, error) {
+	ctx, span := tracer.Start(ctx, "jwks.fetch", trace.WithAttributes(attribute.String("url.full", c.url)))
+	defer span.End()
+
+	backoff := jwksRetryBackoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, jwksAttemptTimeout)
+		keySet, err := jwk.Fetch(attemptCtx, c.url)
+		cancel()
+		if err == nil || attempt >= c.retries {
+			span.SetAttributes(attribute.Int("jwks.attempts", attempt+1))
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return keySet, err
+		}
+
+		wait := backoff/2 + rand.N(backoff/2)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// newRSAKey returns a public JWK with the given key ID.
+func newRSAKey(t *testing.T, kid string) jwk.Key {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	key, err := jwk.New(private.Public())
+	if err != nil {
+		t.Fatalf("wrapping key: %v", err)
+	}
+	if err := key.Set(jwk.KeyIDKey, kid); err != nil {
+		t.Fatalf("setting key ID: %v", err)
+	}
+	return key
+}
+
+// jwksServer serves a key set that the test can replace or break.
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    jwk.Set
+	failing bool
+	// failNext is how many more fetches fail before the endpoint recovers.
+	failNext int
+	fetches  int
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk.Key) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.setKeys(keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		if s.failing || s.failNext > 0 {
+			s.failNext--
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(s.keys)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...jwk.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = jwk.NewSet()
+	for _, key := range keys {
+		s.keys.Add(key)
+	}
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *jwksServer) setFailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// hasKey reports whether set holds a key with the given ID.
+func hasKey(set jwk.Set, kid string) bool {
+	_, ok := set.LookupKeyID(kid)
+	return ok
+}
+
+func TestKeySetCacheRefreshesAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := newRSAKey(t, "old"), newRSAKey(t, "new")
+	server := newJWKSServer(t, oldKey)
+	cache := newKeySetCache(server.URL, 200*time.Millisecond, 0)
+
+	set, err := cache.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !hasKey(set, "old") {
+		t.Fatal("first key set does not hold the old key")
+	}
+
+	server.setKeys(newKey)
+	if set, _ := cache.Get(ctx); !hasKey(set, "old") || server.fetchCount() != 1 {
+		t.Errorf("Get within the TTL fetched again or changed keys (%d fetches)", server.fetchCount())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	set, err = cache.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get after TTL: %v", err)
+	}
+	if !hasKey(set, "new") || hasKey(set, "old") {
+		t.Error("Get after the TTL did not return the replaced key set")
+	}
+}
+
+func TestKeySetCacheServesLastGoodKeysOnFailure(t *testing.T) {
+	ctx := context.Background()
+	server := newJWKSServer(t, newRSAKey(t, "good"))
+	cache := newKeySetCache(server.URL, time.Millisecond, 0)
+
+	if _, err := cache.Get(ctx); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	server.setFailing(true)
+	time.Sleep(5 * time.Millisecond)
+
+	set, err := cache.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get during an outage: %v", err)
+	}
+	if !hasKey(set, "good") {
+		t.Error("Get during an outage did not return the last good key set")
+	}
+}
+
+func TestKeySetCacheFailsWithoutKeys(t *testing.T) {
+	server := newJWKSServer(t)
+	server.setFailing(true)
+	cache := newKeySetCache(server.URL, time.Minute, 1)
+
+	if _, err := cache.Get(context.Background()); err == nil {
+		t.Error("Get succeeded although no key set was ever fetched")
+	}
+	if got := server.fetchCount(); got != 2 {
+		t.Errorf("endpoint fetched %d times, want 2 with one retry", got)
+	}
+}
+
+func TestKeySetCacheRetries(t *testing.T) {
+	tests := []struct {
+		retries     int
+		wantErr     bool
+		wantFetches int
+	}{
+		{retries: 2, wantErr: false, wantFetches: 3},
+		{retries: 1, wantErr: true, wantFetches: 2},
+	}
+	for _, tt := range tests {
+		server := newJWKSServer(t, newRSAKey(t, "good"))
+		server.setFailNext(2)
+		cache := newKeySetCache(server.URL, time.Minute, tt.retries)
+
+		set, err := cache.Get(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("with %d retries: Get error = %v, want error %t", tt.retries, err, tt.wantErr)
+		}
+		if err == nil && !hasKey(set, "good") {
+			t.Errorf("with %d retries: key set does not hold the key", tt.retries)
+		}
+		if got := server.fetchCount(); got != tt.wantFetches {
+			t.Errorf("with %d retries: endpoint fetched %d times, want %d", tt.retries, got, tt.wantFetches)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader is the header a request ID is read from and echoed back in.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the gin context key under which RequestID stores the ID of
+// the current request.
+const RequestIDKey = "request_id"
+
+// validRequestID limits incoming request IDs to a safe, bounded form so they
+// cannot be used to inject content into logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID assigns every request an ID, honoring a well-formed incoming
+// X-Request-ID header and generating one otherwise. The ID is stored in the
+// gin context and echoed in the response header.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDFromContext returns the ID RequestID assigned to the request, or an
+// empty string if there is none.
+func RequestIDFromContext(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Logger logs one structured entry per request with its method, path, status,
+// latency and request ID. It must be chained after RequestID.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		logger.Info("request",
+			slog.String("request_id", RequestIDFromContext(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// abortWithError aborts the request with status and a JSON error body that
+// carries the request ID, so a failed call can be traced in the logs.
+func abortWithError(c *gin.Context, status int, body gin.H) {
+	if id := RequestIDFromContext(c); id != "" {
+		body["request_id"] = id
+	}
+	c.AbortWithStatusJSON(status, body)
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long a client's limiter is kept after its last request.
+const limiterIdleTTL = 10 * time.Minute
+
+// RateLimit applies a token-bucket limit of rps requests per second with the
+// given burst to each client. Clients are identified by the sub claim of their
+// token, falling back to their IP address for unauthenticated requests, so it
+// should be chained after AuthMiddleware. Requests over the limit get 429 with
+// a Retry-After header.
+func RateLimit(rps float64, burst int) gin.HandlerFunc {
+	limiters := newLimiterSet(rate.Limit(rps), burst)
+
+	return func(c *gin.Context) {
+		reservation := limiters.get(clientKey(c)).Reserve()
+		delay := reservation.Delay()
+		if !reservation.OK() || delay > 0 {
+			reservation.Cancel()
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			abortWithError(c, http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey identifies the client a request should be rate limited as.
+func clientKey(c *gin.Context) string {
+	if claims, ok := ClaimsFromContext(c); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return "sub:" + sub
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// limiterSet holds one limiter per client and forgets clients that have been
+// idle for limiterIdleTTL, so the set does not grow without bound.
+type limiterSet struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	limiters  map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLimiterSet(limit rate.Limit, burst int) *limiterSet {
+	return &limiterSet{
+		limit:     limit,
+		burst:     burst,
+		limiters:  make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *limiterSet) get(key string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > limiterIdleTTL {
+		for k, l := range s.limiters {
+			if now.Sub(l.lastSeen) > limiterIdleTTL {
+				delete(s.limiters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	l, ok := s.limiters[key]
+	if !ok {
+		l = &clientLimiter{limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[key] = l
+	}
+	l.lastSeen = now
+	return l.limiter
+}
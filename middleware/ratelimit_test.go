@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRateLimit(t *testing.T) {
+	const burst = 3
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if sub := c.GetHeader("X-Test-Sub"); sub != "" {
+			c.Set(ClaimsKey, jwt.MapClaims{"sub": sub})
+		}
+	})
+	router.Use(RateLimit(0.001, burst))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	send := func(sub, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		if sub != "" {
+			req.Header.Set("X-Test-Sub", sub)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < burst+2; i++ {
+		w := send("alice", "10.0.0.1")
+		if i < burst {
+			if w.Code != http.StatusOK {
+				t.Fatalf("request %d within the burst got %d", i+1, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d over the burst got %d, want 429", i+1, w.Code)
+		}
+		if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 {
+			t.Errorf("Retry-After = %q, want a positive number of seconds", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// Another subject from the same address has its own bucket.
+	if w := send("bob", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("first request of another subject got %d, want 200", w.Code)
+	}
+
+	// Unauthenticated requests are limited per IP address.
+	for i := 0; i < burst; i++ {
+		send("", "10.0.0.2")
+	}
+	if w := send("", "10.0.0.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("unauthenticated request over the burst got %d, want 429", w.Code)
+	}
+	if w := send("", "10.0.0.3"); w.Code != http.StatusOK {
+		t.Errorf("request from another address got %d, want 200", w.Code)
+	}
+}
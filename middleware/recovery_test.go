@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecovery(t *testing.T) {
+	const requestID = "req-1234"
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		want    int
+		// wantBody is the body of the response, empty to skip the check.
+		wantBody string
+	}{
+		{
+			name:    "panic before writing",
+			handler: func(c *gin.Context) { panic("secret details") },
+			want:    http.StatusInternalServerError,
+		},
+		{
+			name: "panic after writing",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, "partial")
+				panic("secret details")
+			},
+			want:     http.StatusOK,
+			wantBody: "partial",
+		},
+		{
+			name:     "no panic",
+			handler:  func(c *gin.Context) { c.String(http.StatusOK, "ok") },
+			want:     http.StatusOK,
+			wantBody: "ok",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			router := gin.New()
+			router.Use(RequestID(), Recovery(slog.New(slog.NewJSONHandler(&logs, nil))))
+			router.GET("/", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, requestID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if strings.Contains(w.Body.String(), "secret details") {
+				t.Errorf("response %s leaks the panic value", w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+			if w.Code == http.StatusInternalServerError {
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding %s: %v", w.Body, err)
+				}
+				if body["error"] != "internal error" || body["request_id"] != requestID {
+					t.Errorf("body = %v, want a generic error with the request ID", body)
+				}
+			}
+
+			panicked := strings.Contains(logs.String(), "secret details")
+			if wantLog := tt.name != "no panic"; panicked != wantLog {
+				t.Errorf("panic logged = %t, want %t: %s", panicked, wantLog, logs.String())
+			}
+			if panicked && !strings.Contains(logs.String(), `"request_id":"`+requestID+`"`) {
+				t.Errorf("log %s does not carry the request ID", logs.String())
+			}
+		})
+	}
+}
+
+func TestRecoveryRepanicsOnAbortHandler(t *testing.T) {
+	router := gin.New()
+	router.Use(Recovery(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	router.GET("/", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to propagate", recovered)
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("http.ErrAbortHandler was swallowed")
+}
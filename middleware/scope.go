@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// RequireScope rejects requests whose token does not grant scope. It must be
+// chained after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := ClaimsFromContext(c)
+		if !ok {
+			abortWithError(c, http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		if !HasScope(claims, scope) {
+			abortWithError(c, http.StatusForbidden, gin.H{"error": "Insufficient scope", "missing_scope": scope})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasScope reports whether claims grant scope.
+func HasScope(claims jwt.MapClaims, scope string) bool {
+	for _, granted := range Scopes(claims) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes returns the scopes granted by claims. Both the space-delimited scope
+// claim of RFC 8693 and the scp array issued by Hydra are understood.
+func Scopes(claims jwt.MapClaims) []string {
+	var scopes []string
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+	if scp, ok := claims["scp"].([]interface{}); ok {
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+	return scopes
+}
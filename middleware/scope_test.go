@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRequireScope(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   int
+	}{
+		{name: "scope string grants it", claims: jwt.MapClaims{"exp": exp, "scope": "openid csv:read"}, want: http.StatusOK},
+		{name: "scope string lacks it", claims: jwt.MapClaims{"exp": exp, "scope": "openid csv:write"}, want: http.StatusForbidden},
+		{name: "scope string with a longer name", claims: jwt.MapClaims{"exp": exp, "scope": "csv:read:all"}, want: http.StatusForbidden},
+		{name: "scp array grants it", claims: jwt.MapClaims{"exp": exp, "scp": []string{"openid", "csv:read"}}, want: http.StatusOK},
+		{name: "scp array lacks it", claims: jwt.MapClaims{"exp": exp, "scp": []string{"openid"}}, want: http.StatusForbidden},
+		{name: "no scope claims", claims: jwt.MapClaims{"exp": exp}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signHMAC(t, tt.claims))
+
+			w := serve(req, AuthMiddleware(AuthConfig{SharedSecret: testSecret}), RequireScope("csv:read"))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequireScopeWithoutAuth(t *testing.T) {
+	w := serve(httptest.NewRequest(http.MethodGet, "/", nil), RequireScope("csv:read"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -14,6 +14,6 @@ ory create oauth2-client \
   --response-type token \
   --token-endpoint-auth-method client_secret_post \
   --name "Claude Connector Client" \
-  --scope "profile"
+  --scope "profile records:read"
 
 echo "OAuth2 client created successfully."
@@ -0,0 +1,22 @@
+package tools
+
+// aliasHeader replaces the names in header that have an alias in the reader
+// options, in place.
+func aliasHeader(header []string) {
+	for i, name := range header {
+		if alias, ok := readerOptions.Aliases[name]; ok {
+			header[i] = alias
+		}
+	}
+}
+
+// originalName returns the header name in the file whose alias is name, or
+// name itself when it is not an alias.
+func originalName(name string) string {
+	for original, alias := range readerOptions.Aliases {
+		if alias == name {
+			return original
+		}
+	}
+	return name
+}
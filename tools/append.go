@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/text/transform"
+)
+
+// appendMu serialises appends made by this process. Appends from other
+// processes are kept out by an exclusive flock held for the same duration.
+var appendMu sync.Mutex
+
+// AppendRecord appends fields as a new data row at the end of the CSV file at
+// filePath, quoting them as needed and using the configured delimiter. The
+// number of fields must match the header. Gzip-compressed and fixed-width
+// files cannot be appended to.
+func AppendRecord(filePath string, fields []string) error {
+	if len(readerOptions.FixedWidth) > 0 {
+		return errors.New("cannot append to a fixed-width file")
+	}
+
+	appendMu.Lock()
+	defer appendMu.Unlock()
+
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("could not open csv file: %w", err)
+	}
+	defer file.Close()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("could not lock csv file: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	compressed, err := isGzip(file, filePath)
+	if err != nil {
+		return err
+	}
+	if compressed {
+		return errors.New("cannot append to a compressed csv file")
+	}
+
+	header, err := newCSVReader(file).Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("cannot append to a csv file without a header")
+	}
+	if err != nil {
+		return fmt.Errorf("could not read csv header: %w", err)
+	}
+	if len(fields) != len(header) {
+		return fmt.Errorf("record has %d fields but the header has %d", len(fields), len(header))
+	}
+	// The writer does not quote a leading comment character, so such a row
+	// would be read back as a comment and lost.
+	if c := readerOptions.Comment; c != 0 && strings.HasPrefix(fields[0], string(c)) {
+		return fmt.Errorf("the first field cannot start with the comment character %q", c)
+	}
+
+	end, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("could not seek csv file: %w", err)
+	}
+
+	// Make sure the new row starts on its own line even if the last row was
+	// written without a trailing newline.
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, end-1); err != nil {
+		return fmt.Errorf("could not read csv file: %w", err)
+	}
+	if last[0] != '\n' {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			return fmt.Errorf("could not write csv file: %w", err)
+		}
+	}
+
+	var out io.WriteCloser = nopWriteCloser{file}
+	if readerOptions.Encoding != nil {
+		out = transform.NewWriter(file, readerOptions.Encoding.NewEncoder())
+	}
+	defer invalidateCache(filePath)
+
+	w := csv.NewWriter(out)
+	w.Comma = readerOptions.Comma
+	if err := w.Write(fields); err != nil {
+		return fmt.Errorf("could not write csv file: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write csv file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("could not write csv file: %w", err)
+	}
+	return nil
+}
+
+// nopWriteCloser adds a no-op Close to a writer that is closed elsewhere.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// RecordFromMap orders the values of record by the header of the CSV file at
+// filePath, leaving columns that are not in record empty. A key that is not a
+// header column is an error.
+func RecordFromMap(ctx context.Context, filePath string, record map[string]string) ([]string, error) {
+	header, err := readHeader(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, len(header))
+	for column, value := range record {
+		index, err := columnIndex(header, column)
+		if err != nil {
+			return nil, err
+		}
+		fields[index] = value
+	}
+	return fields, nil
+}
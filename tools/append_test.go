@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppendRecordConcurrent(t *testing.T) {
+	const n = 50
+	path := writeFixture(t, "records.csv", "id,note\n")
+
+	// Long notes with quotes and line breaks make any interleaving of two
+	// writes show up as a malformed or mismatched row.
+	note := func(i int) string {
+		return strings.Repeat(fmt.Sprintf("row %d, \"quoted\"\n", i), 200)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- AppendRecord(path, []string{fmt.Sprint(i), note(i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AppendRecord: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening result: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("result is not well-formed CSV: %v", err)
+	}
+	if len(rows) != n+1 {
+		t.Fatalf("result has %d rows, want %d", len(rows), n+1)
+	}
+	seen := make(map[string]bool)
+	for _, row := range rows[1:] {
+		var i int
+		if _, err := fmt.Sscan(row[0], &i); err != nil || seen[row[0]] {
+			t.Fatalf("unexpected or repeated id %q", row[0])
+		}
+		seen[row[0]] = true
+		if row[1] != note(i) {
+			t.Errorf("row %d has a corrupted note", i)
+		}
+	}
+}
+
+func TestAppendRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		fields  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", content: "id,name\n1,a\n", fields: []string{"2", "b"}, want: "id,name\n1,a\n2,b\n"},
+		{name: "no trailing newline", content: "id,name\n1,a", fields: []string{"2", "b"}, want: "id,name\n1,a\n2,b\n"},
+		{name: "quoting", content: "id,name\n", fields: []string{"2", "b, \"c\""}, want: "id,name\n2,\"b, \"\"c\"\"\"\n"},
+		{name: "too few fields", content: "id,name\n", fields: []string{"2"}, wantErr: true},
+		{name: "no header", content: "", fields: []string{"2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			err := AppendRecord(path, tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AppendRecord error = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading result: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// cache holds parsed CSV files in memory. It is nil unless EnableCache has
+// been called, in which case every reader function consults it first.
+var cache *recordCache
+
+// EnableCache makes the reader functions keep each CSV file they read parsed
+// in memory until the file changes. Changes are detected with filesystem
+// notifications; where those are unavailable the file's size and modification
+// time are compared on every read instead. Like SetReaderOptions it is meant
+// to be called once at startup.
+func EnableCache() {
+	c := &recordCache{
+		entries: make(map[string]*cacheEntry),
+		watched: make(map[string]bool),
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("file notifications unavailable, falling back to modification time checks", "error", err)
+	} else {
+		c.watcher = watcher
+		go c.watch()
+	}
+
+	cache = c
+}
+
+// recordCache maps absolute file paths to their parsed rows.
+type recordCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+	// gen counts invalidations, so that a load which raced with a change is
+	// not stored.
+	gen uint64
+	// watched records, per directory, whether notifications are delivered
+	// for it.
+	watched map[string]bool
+	watcher *fsnotify.Watcher
+}
+
+// cacheEntry is one parsed file. rows includes the header row.
+type cacheEntry struct {
+	rows    [][]string
+	modTime time.Time
+	size    int64
+	// polled entries are checked against the file on every read because no
+	// notifications are delivered for their directory.
+	polled bool
+}
+
+// cachedRows returns every row of the CSV file at filePath, header included,
+// from the cache. It reports false when caching is disabled or the file could
+// not be loaded cleanly, in which case the caller reads the file itself and
+// reports any error in its own way.
+func cachedRows(ctx context.Context, filePath string) ([][]string, bool) {
+	if cache == nil {
+		return nil, false
+	}
+	rows, err := cache.get(ctx, filePath)
+	if err != nil {
+		return nil, false
+	}
+	return rows, true
+}
+
+// invalidateCache drops any cached copy of the file at filePath.
+func invalidateCache(filePath string) {
+	if cache == nil {
+		return
+	}
+	if key, err := filepath.Abs(filePath); err == nil {
+		cache.invalidate(key)
+	}
+}
+
+func (c *recordCache) get(ctx context.Context, filePath string) ([][]string, error) {
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	entry, gen := c.entries[key], c.gen
+	c.mu.RUnlock()
+
+	if entry != nil {
+		if !entry.polled {
+			return entry.rows, nil
+		}
+		info, err := os.Stat(key)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Equal(entry.modTime) && info.Size() == entry.size {
+			return entry.rows, nil
+		}
+	}
+
+	// Start watching before reading so that a change made during the read is
+	// not missed.
+	polled := !c.watchDir(filepath.Dir(key))
+
+	info, err := os.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	if limit := readerOptions.MaxFileBytes; limit > 0 && info.Size() > limit {
+		return nil, ErrFileTooLarge
+	}
+	rows, err := readAllRows(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.entries[key] = &cacheEntry{rows: rows, modTime: info.ModTime(), size: info.Size(), polled: polled}
+	}
+	c.mu.Unlock()
+	return rows, nil
+}
+
+// watchDir subscribes to notifications for dir, watching the directory rather
+// than the file so that files replaced by a rename are noticed. It reports
+// whether notifications are being delivered.
+func (c *recordCache) watchDir(dir string) bool {
+	if c.watcher == nil {
+		return false
+	}
+
+	c.mu.RLock()
+	ok, seen := c.watched[dir]
+	c.mu.RUnlock()
+	if seen {
+		return ok
+	}
+
+	err := c.watcher.Add(dir)
+	if err != nil {
+		slog.Warn("could not watch directory, falling back to modification time checks", "dir", dir, "error", err)
+	}
+
+	c.mu.Lock()
+	c.watched[dir] = err == nil
+	c.mu.Unlock()
+	return err == nil
+}
+
+// watch invalidates entries as notifications arrive. If notifications are
+// lost, everything is invalidated since any file may have changed.
+func (c *recordCache) watch() {
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			c.invalidate(event.Name)
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("file notification error, clearing csv cache", "error", err)
+			c.invalidateAll()
+		}
+	}
+}
+
+func (c *recordCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.gen++
+}
+
+// ClearCache drops every cached file, so the next read of each parses it
+// again. It does nothing when the cache is disabled.
+func ClearCache() {
+	if cache != nil {
+		cache.invalidateAll()
+	}
+}
+
+func (c *recordCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.gen++
+}
+
+// readAllRows parses every row of the CSV file at filePath, failing on any
+// malformed row.
+func readAllRows(ctx context.Context, filePath string) ([][]string, error) {
+	file, err := openFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := newCSVReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not read csv file: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// enableTestCache turns the record cache on for the duration of the test,
+// with filesystem notifications when watched is set and modification time
+// checks otherwise.
+func enableTestCache(t *testing.T, watched bool) {
+	t.Helper()
+	if watched {
+		EnableCache()
+	} else {
+		cache = &recordCache{entries: make(map[string]*cacheEntry), watched: make(map[string]bool)}
+	}
+	c := cache
+	t.Cleanup(func() {
+		if c.watcher != nil {
+			c.watcher.Close()
+		}
+		cache = nil
+	})
+}
+
+func TestCacheSeesChanges(t *testing.T) {
+	for _, watched := range []bool{true, false} {
+		name := "polled"
+		if watched {
+			name = "watched"
+		}
+		t.Run(name, func(t *testing.T) {
+			enableTestCache(t, watched)
+			ctx := context.Background()
+			path := writeFixture(t, "records.csv", "id,name\n1,a\n2,b\n")
+
+			records, err := GetLastNRecords(ctx, path, 10)
+			if err != nil {
+				t.Fatalf("GetLastNRecords: %v", err)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, []string{"id", "1", "2"}) {
+				t.Fatalf("first read ids = %q", got)
+			}
+			if _, ok := cachedRows(ctx, path); !ok {
+				t.Fatal("file was not cached")
+			}
+
+			if err := os.WriteFile(path, []byte("id,name\n1,a\n2,b\n3,c\n"), 0o600); err != nil {
+				t.Fatalf("rewriting fixture: %v", err)
+			}
+			want := []string{"id", "1", "2", "3"}
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				records, err := GetLastNRecords(ctx, path, 10)
+				if err != nil {
+					t.Fatalf("GetLastNRecords after the change: %v", err)
+				}
+				got := ids(records)
+				if reflect.DeepEqual(got, want) {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("ids after the change = %q, want %q", got, want)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestCacheSkipsLargeFiles(t *testing.T) {
+	enableTestCache(t, true)
+	setReaderOptions(t, ReaderOptions{MaxFileBytes: 10})
+	ctx := context.Background()
+	path := writeFixture(t, "records.csv", "id,name\n1,a\n2,b\n")
+
+	if _, err := cache.get(ctx, path); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("cache.get = %v, want ErrFileTooLarge", err)
+	}
+	if _, ok := cachedRows(ctx, path); ok {
+		t.Error("cachedRows returned a file over MaxFileBytes")
+	}
+	cache.mu.RLock()
+	n := len(cache.entries)
+	cache.mu.RUnlock()
+	if n != 0 {
+		t.Errorf("cache holds %d entries, want none", n)
+	}
+}
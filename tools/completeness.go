@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Completeness reports how many cells of each column of a CSV file are empty.
+type Completeness struct {
+	Rows    int                  `json:"rows"`
+	Columns []CompletenessColumn `json:"columns"`
+}
+
+// CompletenessColumn is the number and percentage of data rows whose cell in
+// Column is empty.
+type CompletenessColumn struct {
+	Column       string  `json:"column"`
+	Empty        int     `json:"empty"`
+	EmptyPercent float64 `json:"empty_percent"`
+}
+
+// ColumnCompleteness streams the CSV file at filePath once and counts the
+// empty cells of every column, in header order. A cell is empty when it holds
+// only white space or the row is too short to have it. The percentages are of
+// the number of data rows rounded to two decimals, and zero when there are
+// none.
+func ColumnCompleteness(ctx context.Context, filePath string) (*Completeness, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	result := &Completeness{Columns: make([]CompletenessColumn, len(reader.Header))}
+	for i, name := range reader.Header {
+		result.Columns[i].Column = name
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Rows++
+		for i := range result.Columns {
+			if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+				result.Columns[i].Empty++
+			}
+		}
+	}
+
+	if result.Rows > 0 {
+		for i := range result.Columns {
+			percent := 100 * float64(result.Columns[i].Empty) / float64(result.Rows)
+			result.Columns[i].EmptyPercent = math.Round(percent*100) / 100
+		}
+	}
+	return result, nil
+}
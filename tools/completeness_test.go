@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestColumnCompleteness(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Completeness
+		// wantFilled is the number of non-blank cells per column.
+		wantFilled []int
+	}{
+		{
+			name:    "varied empty cells",
+			content: "id,name,dose,note\n1,Ann,10,\n2,, ,\n3,Cy,5,\n4,Di,,ok\n5,\t,2,\n6,Fay,1,fine\n",
+			want: Completeness{Rows: 6, Columns: []CompletenessColumn{
+				{Column: "id"},
+				{Column: "name", Empty: 2, EmptyPercent: 33.33},
+				{Column: "dose", Empty: 2, EmptyPercent: 33.33},
+				{Column: "note", Empty: 4, EmptyPercent: 66.67},
+			}},
+			wantFilled: []int{6, 4, 4, 2},
+		},
+		{
+			name:    "header only",
+			content: "id,name\n",
+			want: Completeness{Columns: []CompletenessColumn{
+				{Column: "id"},
+				{Column: "name"},
+			}},
+			wantFilled: []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			got, err := ColumnCompleteness(context.Background(), path)
+			if err != nil {
+				t.Fatalf("ColumnCompleteness: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ColumnCompleteness = %+v, want %+v", *got, tt.want)
+			}
+			filled := make([]int, len(got.Columns))
+			for i, column := range got.Columns {
+				filled[i] = got.Rows - column.Empty
+			}
+			if !reflect.DeepEqual(filled, tt.wantFilled) {
+				t.Errorf("non-blank cells = %v, want %v", filled, tt.wantFilled)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CountRecords streams the CSV file at filePath once and returns the number of
+// data rows, excluding the header. When column is non-empty it also returns
+// the number of rows whose column equals value; otherwise matched equals
+// total.
+func CountRecords(ctx context.Context, filePath, column, value string) (total, matched int, err error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer reader.Close()
+
+	index := -1
+	if column != "" {
+		index, err = queryableIndex(reader.Header, column)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		total++
+		if index < 0 || (index < len(record) && record[index] == value) {
+			matched++
+		}
+	}
+
+	return total, matched, nil
+}
+
+// CountDataRows returns the number of data rows in the CSV file at filePath,
+// excluding the header. When lenient is set, rows that fail to parse are not
+// counted rather than failing the count, matching GetLastNRecordsLenient.
+func CountDataRows(ctx context.Context, filePath string, lenient bool) (int, error) {
+	if rows, ok := cachedRows(ctx, filePath); ok {
+		return max(len(rows)-1, 0), nil
+	}
+
+	file, err := openFile(ctx, filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := newCSVReader(file)
+	rows := 0
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if lenient && errors.As(err, &parseErr) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read csv file: %w", err)
+		}
+		rows++
+	}
+
+	return max(rows-1, 0), nil
+}
@@ -1,33 +1,738 @@
 package tools
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
-func GetLastNRecords(filePath string, n int) ([][]string, error) {
-	file, err := os.Open(filePath)
+// ReaderOptions controls how every reader function in this package parses
+// CSV files.
+type ReaderOptions struct {
+	// Comma is the field delimiter. It defaults to ','.
+	Comma rune
+
+	// Comment, when not zero, starts a comment line: lines beginning with it
+	// are skipped, as csv.Reader.Comment does, so they are never counted or
+	// returned as rows. It must differ from Comma.
+	Comment rune
+
+	// DateLayout is an additional time.Parse layout tried before the standard
+	// ones when a cell is interpreted as a date. It is empty by default.
+	DateLayout string
+
+	// Encoding is the character encoding of the files. Nil means UTF-8.
+	Encoding encoding.Encoding
+
+	// LazyQuotes lets a quote appear in an unquoted field and a non-doubled
+	// quote appear in a quoted field, as csv.Reader.LazyQuotes does. Malformed
+	// rows are then read rather than rejected, but a stray quote can make a
+	// field swallow the delimiters and line breaks that follow it.
+	LazyQuotes bool
+
+	// TrimSpace removes leading and trailing white space from every cell,
+	// header names included. Cells are returned verbatim by default.
+	TrimSpace bool
+
+	// Redact maps column names to a redaction mode, RedactFull or
+	// RedactLast4. The cells of those columns are masked as they are read, so
+	// no reader function, filter included, ever sees the original values.
+	// Columns may be named by their alias.
+	Redact map[string]string
+
+	// Aliases maps header names in the files to the names the reader
+	// functions report them under. Columns can be selected by either name.
+	Aliases map[string]string
+
+	// Queryable, when not empty, is the set of columns, by header name or
+	// alias, that the reader functions may filter, sort and aggregate on.
+	// Naming any other column for those is an error, and searches skip its
+	// cells.
+	Queryable map[string]bool
+
+	// MaxFileBytes, when positive, is the largest file CheckFileSize accepts
+	// and the cache holds. Larger files are still streamed.
+	MaxFileBytes int64
+
+	// OpenRetries is how many times opening a file is retried after a
+	// transient failure, such as one caused by a writer replacing the file.
+	OpenRetries int
+
+	// FixedWidth, when not empty, is the layout of the files, which are then
+	// read as fixed-width text rather than CSV. Such files have no header
+	// line: the column names are the header. Comma and LazyQuotes do not
+	// apply.
+	FixedWidth []FixedWidthColumn
+}
+
+var readerOptions = ReaderOptions{Comma: ',', OpenRetries: DefaultOpenRetries}
+
+// SetReaderOptions replaces the options used by all reader functions. It is
+// meant to be called once at startup, before any file is read.
+func SetReaderOptions(opts ReaderOptions) {
+	readerOptions = opts
+}
+
+// Delimiter returns the field delimiter the reader functions are configured
+// with, so that output written back as CSV round-trips.
+func Delimiter() rune {
+	return readerOptions.Comma
+}
+
+// ValidDelimiter reports whether r can be used as a CSV field delimiter.
+func ValidDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// newCSVReader returns a csv.Reader over r configured with the package reader
+// options, decoding r to UTF-8 if another encoding is configured. When a
+// fixed-width layout is configured it reads r as fixed-width text instead.
+func newCSVReader(r io.Reader) *csvReader {
+	if readerOptions.Encoding != nil {
+		r = transform.NewReader(r, readerOptions.Encoding.NewDecoder())
+	}
+	if len(readerOptions.FixedWidth) > 0 {
+		return &csvReader{recordSource: newFixedWidthReader(r, readerOptions.FixedWidth), trimSpace: readerOptions.TrimSpace}
+	}
+	reader := csv.NewReader(r)
+	reader.Comma = readerOptions.Comma
+	reader.Comment = readerOptions.Comment
+	reader.LazyQuotes = readerOptions.LazyQuotes
+	return &csvReader{recordSource: reader, trimSpace: readerOptions.TrimSpace}
+}
+
+// recordSource is the parser under a csvReader: a csv.Reader or a
+// fixedWidthReader.
+type recordSource interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
+// csvReader is a csv.Reader that also applies the cell-level reader options.
+// The first record it reads is taken to be the header, which decides the
+// columns to redact and is returned with column aliases applied, unless
+// setHeader was called first.
+type csvReader struct {
+	recordSource
+	trimSpace bool
+
+	sawHeader bool
+	masks     map[int]string
+}
+
+// setHeader supplies the header for a reader positioned past it.
+func (r *csvReader) setHeader(header []string) {
+	r.sawHeader = true
+	r.masks = redactionsFor(header)
+}
+
+// Read reads one record like csv.Reader.Read.
+func (r *csvReader) Read() ([]string, error) {
+	record, err := r.recordSource.Read()
+	if err != nil && record == nil && !r.sawHeader && len(readerOptions.Redact) > 0 && !errors.Is(err, io.EOF) {
+		// Without the header the redacted columns are unknown, so the error
+		// must not be skippable like a malformed data row.
+		return nil, fmt.Errorf("could not read csv header, which is needed for redaction: %v", err)
+	}
+	r.clean(record)
+	return record, err
+}
+
+// ReadAll reads the remaining records like csv.Reader.ReadAll.
+func (r *csvReader) ReadAll() ([][]string, error) {
+	records := [][]string{}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// clean applies the cell-level options to record in place.
+func (r *csvReader) clean(record []string) {
+	if record == nil {
+		return
+	}
+	if r.trimSpace {
+		trimCells(record)
+	}
+	if !r.sawHeader {
+		r.setHeader(record)
+		aliasHeader(record)
+		return
+	}
+	redactRecord(record, r.masks)
+}
+
+// trimCells removes the white space around every cell of record in place.
+func trimCells(record []string) {
+	for i, cell := range record {
+		record[i] = strings.TrimSpace(cell)
+	}
+}
+
+// GetLastNRecords returns the last n records of the CSV file at filePath.
+// The file is streamed in a single forward pass and only the most recent n
+// records are kept in a ring buffer, so memory use is bounded by n rather
+// than by the size of the file. Any malformed row fails the whole read.
+func GetLastNRecords(ctx context.Context, filePath string, n int) ([][]string, error) {
+	records, _, err := lastNRecords(ctx, filePath, n, false)
+	return records, err
+}
+
+// GetLastNRecordsLenient is like GetLastNRecords but skips rows that fail to
+// parse, such as rows with the wrong number of fields, instead of failing. It
+// returns the number of rows that were skipped.
+func GetLastNRecordsLenient(ctx context.Context, filePath string, n int) ([][]string, int, error) {
+	return lastNRecords(ctx, filePath, n, true)
+}
+
+func lastNRecords(ctx context.Context, filePath string, n int, lenient bool) ([][]string, int, error) {
+	if rows, ok := cachedRows(ctx, filePath); ok {
+		return tailOf(rows, n), 0, nil
+	}
+
+	file, err := openFile(ctx, filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	if n <= 0 {
+		return [][]string{}, 0, nil
+	}
+
+	reader := newCSVReader(file)
+	ring := make([][]string, n)
+	total := 0
+	skipped := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if lenient && errors.As(err, &parseErr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not read csv file: %w", err)
+		}
+		ring[total%n] = record
+		total++
+	}
+
+	return unwindRing(ring, total), skipped, nil
+}
+
+// unwindRing returns the records held in ring in file order, given the total
+// number of records that were written into it.
+func unwindRing(ring [][]string, total int) [][]string {
+	size := len(ring)
+	if total < size {
+		return ring[:total]
+	}
+
+	records := make([][]string, 0, size)
+	start := total % size
+	records = append(records, ring[start:]...)
+	records = append(records, ring[:start]...)
+	return records
+}
+
+// tailOf returns a copy of the last n of rows.
+func tailOf(rows [][]string, n int) [][]string {
+	if n <= 0 {
+		return [][]string{}
+	}
+	if n > len(rows) {
+		n = len(rows)
+	}
+	return append([][]string{}, rows[len(rows)-n:]...)
+}
+
+// tailChunkSize is the number of bytes read per step when scanning a file
+// backwards for record boundaries.
+const tailChunkSize = 64 * 1024
+
+// GetLastNRecordsSeek returns the last n records of the CSV file at filePath
+// by seeking to the end of the file and reading backwards until n record
+// boundaries have been found, so only the tail of the file is parsed. Line
+// breaks inside quoted fields are not boundaries. If the file is
+// gzip-compressed, if LazyQuotes is set, which makes quotes ambiguous, if the
+// files are fixed-width, or if the tail region does not parse cleanly, it
+// falls back to a full forward scan.
+func GetLastNRecordsSeek(ctx context.Context, filePath string, n int) ([][]string, error) {
+	records, _, err := lastNRecordsSeek(ctx, filePath, n, false)
+	return records, err
+}
+
+// GetLastNRecordsSeekLenient is like GetLastNRecordsSeek, but when it has to
+// fall back to a forward scan it skips malformed rows the way
+// GetLastNRecordsLenient does. It returns the number of rows that were
+// skipped.
+func GetLastNRecordsSeekLenient(ctx context.Context, filePath string, n int) ([][]string, int, error) {
+	return lastNRecordsSeek(ctx, filePath, n, true)
+}
+
+func lastNRecordsSeek(ctx context.Context, filePath string, n int, lenient bool) ([][]string, int, error) {
+	if n <= 0 {
+		return [][]string{}, 0, nil
+	}
+	if rows, ok := cachedRows(ctx, filePath); ok {
+		return tailOf(rows, n), 0, nil
+	}
+
+	file, err := openRetrying(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("could not open csv file: %w", err)
+		return nil, 0, err
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	// Compressed streams cannot be read backwards, and with lazy quotes a
+	// quote may be literal text, so quoted line breaks cannot be told apart
+	// from record boundaries. A fixed-width reader always begins with the
+	// header from the layout, which would be mistaken for a tail row.
+	compressed, err := isGzip(file, filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	if compressed || readerOptions.LazyQuotes || len(readerOptions.FixedWidth) > 0 {
+		return lastNRecords(ctx, filePath, n, lenient)
+	}
+
+	offset, err := findTailOffset(ctx, file, n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("could not seek csv file: %w", err)
+	}
+
+	var tail io.Reader = contextReader{ctx: ctx, r: file}
+	if offset == 0 {
+		tail = skipBOM(tail)
+	}
+	reader := newCSVReader(tail)
+	// Past the header, the first row read is data: it must neither be taken
+	// for the header, which would have aliases applied to its cells, nor
+	// leave the redacted columns unknown.
+	if offset > 0 {
+		header, err := readHeader(ctx, filePath)
+		if err != nil {
+			return nil, 0, err
+		}
+		reader.setHeader(header)
+	}
 	records, err := reader.ReadAll()
+	if err != nil || (len(records) < n && offset > 0) {
+		return lastNRecords(ctx, filePath, n, lenient)
+	}
+
+	if len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, 0, nil
+}
+
+// findTailOffset returns the byte offset at which the last n records of file
+// begin. A newline only counts as a record boundary when the quotes after it
+// are balanced: the end of a well-formed file is outside any quoted field, so
+// an odd number of quotes after a newline means it is inside one. Escaped
+// quotes come in pairs and do not change the balance. A trailing newline at
+// the very end of the file does not count as a boundary. A line that starts
+// with the comment character at a record boundary is a comment: it is not a
+// record and its quotes are not counted.
+func findTailOffset(ctx context.Context, file *os.File, n int) (int64, error) {
+	info, err := file.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("could not read csv file: %w", err)
+		return 0, fmt.Errorf("could not stat csv file: %w", err)
+	}
+
+	end := info.Size()
+	buf := make([]byte, tailChunkSize)
+	newlines := 0
+	trailing := true
+	quoted := false
+
+	var comment []byte
+	if readerOptions.Comment != 0 {
+		comment = utf8.AppendRune(nil, readerOptions.Comment)
+	}
+	// lineQuoted is the balance of the quotes in the line after the newline
+	// being looked for, and prefix the first bytes of that line.
+	lineQuoted := false
+	var prefix [utf8.UTFMax]byte
+	prefixLen := 0
+
+	for end > 0 {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		start := end - tailChunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("could not read csv file: %w", err)
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				if chunk[i] == '"' {
+					quoted = !quoted
+					lineQuoted = !lineQuoted
+				}
+				copy(prefix[1:], prefix[:len(prefix)-1])
+				prefix[0] = chunk[i]
+				prefixLen = min(prefixLen+1, len(prefix))
+				trailing = false
+				continue
+			}
+
+			// The line ends outside a quoted field when the quotes after it
+			// are balanced, and then it is a comment if it starts with one.
+			isComment := comment != nil && quoted == lineQuoted && bytes.HasPrefix(prefix[:prefixLen], comment)
+			if isComment {
+				quoted = false
+			}
+			lineQuoted = false
+			prefixLen = 0
+			if trailing || quoted || isComment {
+				continue
+			}
+			newlines++
+			if newlines == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+
+	return 0, nil
+}
+
+// TailFunc returns the last n records of a CSV file, counting the header row
+// as an ordinary record. GetLastNRecords and GetLastNRecordsSeek are both
+// TailFuncs.
+type TailFunc func(ctx context.Context, filePath string, n int) ([][]string, error)
+
+// GetLastNRecordsWithHeader treats the first row of the CSV file at filePath
+// as a header and returns the last n data rows as maps keyed by column name,
+// along with the ordered header. A file with a header but no data rows yields
+// an empty slice and the header.
+func GetLastNRecordsWithHeader(ctx context.Context, filePath string, n int) ([]map[string]string, []string, error) {
+	records, header, err := TailWithHeader(ctx, GetLastNRecords, filePath, n)
+	if err != nil {
+		return nil, nil, err
+	}
+	return RecordsToMaps(header, records), header, nil
+}
+
+// TailWithHeader reads the last n data rows of the CSV file at filePath with
+// the given TailFunc, excluding the header row, and returns them along with
+// the header.
+func TailWithHeader(ctx context.Context, tail TailFunc, filePath string, n int) ([][]string, []string, error) {
+	header, err := readHeader(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil || n <= 0 {
+		return [][]string{}, header, nil
+	}
+
+	// Reading one record more than needed means the first record returned is
+	// either the header itself (when the file holds at most n data rows) or a
+	// data row older than the requested window; either way it is dropped.
+	records, err := tail(ctx, filePath, n+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) > 0 {
+		records = records[1:]
+	}
+
+	return records, header, nil
+}
+
+// GetFirstNRecords returns the first n data rows of the CSV file at filePath,
+// excluding the header row, along with the header. It stops reading as soon as
+// n rows have been read.
+func GetFirstNRecords(ctx context.Context, filePath string, n int) ([][]string, []string, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	records := [][]string{}
+	for len(records) < n {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record)
 	}
 
-	totalRecords := len(records)
-	if totalRecords == 0 {
-		return [][]string{}, nil
+	return records, reader.Header, nil
+}
+
+// GetRecordsPage skips the first offset data rows of the CSV file at filePath
+// and returns up to limit of the rows that follow, along with the header and
+// the total number of data rows in the file. An offset beyond the end of the
+// file yields an empty page.
+func GetRecordsPage(ctx context.Context, filePath string, offset, limit int) ([][]string, []string, int, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, 0, err
 	}
+	defer reader.Close()
 
-	startIndex := totalRecords - n
-	if startIndex < 0 {
-		startIndex = 0
+	records := [][]string{}
+	total := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if total >= offset && len(records) < limit {
+			records = append(records, record)
+		}
+		total++
 	}
 
-	return records[startIndex:], nil
+	return records, reader.Header, total, nil
+}
+
+// GetTailRange returns up to count data rows of the CSV file at filePath that
+// end fromEnd rows before the last one, so a fromEnd of zero is the plain
+// tail, along with the header and the total number of data rows. It streams
+// the file through a ring buffer of fromEnd+count rows. A range reaching past
+// the first data row is clamped to it, and one lying wholly before it is
+// empty.
+func GetTailRange(ctx context.Context, filePath string, fromEnd, count int) ([][]string, []string, int, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer reader.Close()
+
+	// The ring grows as rows are read rather than being allocated up front,
+	// so a large fromEnd on a small file costs no more than the file.
+	size := fromEnd + count
+	if size < 0 {
+		size = math.MaxInt
+	}
+	ring := [][]string{}
+	total := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if len(ring) < size {
+			ring = append(ring, record)
+		} else {
+			ring[total%size] = record
+		}
+		total++
+	}
+
+	records := unwindRing(ring, total)
+	records = records[:max(len(records)-fromEnd, 0)]
+	if len(records) > count {
+		records = records[len(records)-count:]
+	}
+	return records, reader.Header, total, nil
+}
+
+// RecordsToMaps converts rows into maps keyed by the matching header column.
+// Fields beyond the end of the header are ignored.
+func RecordsToMaps(header []string, records [][]string) []map[string]string {
+	maps := make([]map[string]string, 0, len(records))
+	for _, record := range records {
+		m := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				m[column] = record[i]
+			}
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+// readHeader returns the first record of the CSV file at filePath, or nil if
+// the file is empty.
+func readHeader(ctx context.Context, filePath string) ([]string, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return reader.Header, nil
+}
+
+// GetHeader returns the header row of the CSV file at filePath, reading no
+// further than that row, or ErrEmptyFile if the file is empty.
+func GetHeader(ctx context.Context, filePath string) ([]string, error) {
+	header, err := readHeader(ctx, filePath)
+	if err == nil && header == nil {
+		return nil, ErrEmptyFile
+	}
+	return header, err
+}
+
+// Errors returned by CheckData for files that hold no data rows.
+var (
+	ErrEmptyFile  = errors.New("file is empty")
+	ErrNoDataRows = errors.New("no data rows (header only)")
+)
+
+// CheckData returns ErrEmptyFile if the CSV file at filePath has no rows at
+// all, ErrNoDataRows if it has only a header row, and nil if it has at least
+// one data row. It reads no further than the first data row.
+func CheckData(ctx context.Context, filePath string) error {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if reader.Header == nil {
+		return ErrEmptyFile
+	}
+	if _, err := reader.Read(); errors.Is(err, io.EOF) {
+		return ErrNoDataRows
+	}
+	return nil
+}
+
+// cacheCheckInterval is how many cached rows recordReader returns between
+// checks of its context.
+const cacheCheckInterval = 1024
+
+// recordReader streams the data rows of a CSV file whose first row is the
+// header, either from the file itself or from the cache.
+type recordReader struct {
+	file   io.ReadCloser
+	reader *csvReader
+
+	// rows holds the data rows not yet read when the file came from the
+	// cache, in which case file and reader are nil. ctx is checked every
+	// cacheCheckInterval rows, as no file reads are made to notice it.
+	rows   [][]string
+	cached bool
+	ctx    context.Context
+	read   int
+
+	// Header is the first row of the file, or nil if the file is empty.
+	Header []string
+}
+
+// openRecords opens the CSV file at filePath and reads its header row. The
+// caller must Close the returned reader.
+func openRecords(ctx context.Context, filePath string) (*recordReader, error) {
+	if rows, ok := cachedRows(ctx, filePath); ok {
+		r := &recordReader{cached: true, ctx: ctx}
+		if len(rows) > 0 {
+			r.Header, r.rows = rows[0], rows[1:]
+		}
+		return r, nil
+	}
+
+	file, err := openFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &recordReader{file: file, reader: newCSVReader(file)}
+	header, err := r.reader.Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+	r.Header = header
+	return r, nil
+}
+
+// Read returns the next data row, or io.EOF once the file is exhausted.
+func (r *recordReader) Read() ([]string, error) {
+	if r.Header == nil {
+		return nil, io.EOF
+	}
+	if r.cached {
+		if len(r.rows) == 0 {
+			return nil, io.EOF
+		}
+		r.read++
+		if r.read%cacheCheckInterval == 0 {
+			if err := r.ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		record := r.rows[0]
+		r.rows = r.rows[1:]
+		return record, nil
+	}
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read csv file: %w", err)
+	}
+	return record, nil
+}
+
+// Close closes the underlying file, if any.
+func (r *recordReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// columnIndex returns the position of column in header, or an error listing
+// the valid column names if it is not present. A column that has an alias may
+// also be named by its original header name. A nil header, from an empty
+// file, yields ErrEmptyFile.
+func columnIndex(header []string, column string) (int, error) {
+	if header == nil {
+		return -1, ErrEmptyFile
+	}
+	alias, aliased := readerOptions.Aliases[column]
+	for i, name := range header {
+		if name == column || aliased && name == alias {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("column %q does not exist; valid columns are: %s", column, strings.Join(header, ", "))
 }
@@ -0,0 +1,543 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeFixture writes content to a file named name in a temporary directory
+// and returns its path.
+func writeFixture(tb testing.TB, name, content string) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		tb.Fatalf("writing fixture: %v", err)
+	}
+	return path
+}
+
+// setReaderOptions replaces the package reader options for the duration of
+// the test.
+func setReaderOptions(tb testing.TB, opts ReaderOptions) {
+	tb.Helper()
+	saved := readerOptions
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	SetReaderOptions(opts)
+	tb.Cleanup(func() { SetReaderOptions(saved) })
+}
+
+func TestGetLastNRecords(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,name\n1,a\n2,b\n3,c\n")
+
+	tests := []struct {
+		n    int
+		want [][]string
+	}{
+		{n: 0, want: [][]string{}},
+		{n: 1, want: [][]string{{"3", "c"}}},
+		{n: 2, want: [][]string{{"2", "b"}, {"3", "c"}}},
+		{n: 4, want: [][]string{{"id", "name"}, {"1", "a"}, {"2", "b"}, {"3", "c"}}},
+		{n: 10, want: [][]string{{"id", "name"}, {"1", "a"}, {"2", "b"}, {"3", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			got, err := GetLastNRecords(context.Background(), path, tt.n)
+			if err != nil {
+				t.Fatalf("GetLastNRecords: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetLastNRecords(%d) = %q, want %q", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeLargeFixture writes a CSV file of rows data rows.
+func writeLargeFixture(tb testing.TB, rows int) string {
+	tb.Helper()
+	var b strings.Builder
+	b.WriteString("id,patient,medication,dose,date\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "%d,patient-%d,medication-%d,%dmg,2024-01-%02d\n", i, i%5000, i%300, 50+i%20, 1+i%28)
+	}
+	return writeFixture(tb, "large.csv", b.String())
+}
+
+// readAllTail is the approach GetLastNRecords replaced: parse the whole file
+// into memory and slice off the tail.
+func readAllTail(filePath string, n int) ([][]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := newCSVReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return records[max(len(records)-n, 0):], nil
+}
+
+func BenchmarkLastNRecords(b *testing.B) {
+	path := writeLargeFixture(b, 1_000_000)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := readAllTail(path, 100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("RingBuffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := GetLastNRecords(context.Background(), path, 100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGetLastNRecordsSeekMatchesScan(t *testing.T) {
+	var large strings.Builder
+	large.WriteString("id,value\n")
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&large, "%d,value-%d\n", i, i)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "trailing newline", content: "id,name\n1,a\n2,b\n3,c\n"},
+		{name: "no trailing newline", content: "id,name\n1,a\n2,b\n3,c"},
+		{name: "crlf", content: "id,name\r\n1,a\r\n2,b\r\n3,c\r\n"},
+		{name: "header only", content: "id,name\n"},
+		{name: "empty", content: ""},
+		{name: "spans chunks", content: large.String()},
+	}
+	for _, tt := range tests {
+		path := writeFixture(t, "records.csv", tt.content)
+		for _, n := range []int{1, 2, 3, 5, 15000} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, n), func(t *testing.T) {
+				want, err := GetLastNRecords(context.Background(), path, n)
+				if err != nil {
+					t.Fatalf("GetLastNRecords: %v", err)
+				}
+				got, err := GetLastNRecordsSeek(context.Background(), path, n)
+				if err != nil {
+					t.Fatalf("GetLastNRecordsSeek: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("GetLastNRecordsSeek(%d) returned %d records, scan returned %d; got %.3q", n, len(got), len(want), got)
+				}
+			})
+		}
+	}
+}
+
+func TestGetLastNRecordsSeekFallsBackOnParseError(t *testing.T) {
+	// The tail holds a row with a bare quote, which only parses with the
+	// lenient scan.
+	path := writeFixture(t, "records.csv", "id,name\n1,a\n2,b\"c\n3,d\n")
+
+	if _, err := GetLastNRecordsSeek(context.Background(), path, 2); err == nil {
+		t.Fatal("GetLastNRecordsSeek succeeded on a malformed tail, want the error of the strict scan")
+	}
+	got, skipped, err := GetLastNRecordsSeekLenient(context.Background(), path, 2)
+	if err != nil {
+		t.Fatalf("GetLastNRecordsSeekLenient: %v", err)
+	}
+	if want := [][]string{{"1", "a"}, {"3", "d"}}; !reflect.DeepEqual(got, want) || skipped != 1 {
+		t.Errorf("GetLastNRecordsSeekLenient = %q, %d skipped; want %q, 1 skipped", got, skipped, want)
+	}
+}
+
+func TestLazyQuotes(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,note\n1,5\" tall\n2,\"said \"no\" twice\"\n")
+
+	tests := []struct {
+		name    string
+		lazy    bool
+		want    [][]string
+		wantErr bool
+	}{
+		{name: "off", lazy: false, wantErr: true},
+		{name: "on", lazy: true, want: [][]string{{"id", "note"}, {"1", `5" tall`}, {"2", `said "no" twice`}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{LazyQuotes: tt.lazy})
+
+			for _, read := range []struct {
+				name string
+				tail TailFunc
+			}{
+				{name: "scan", tail: GetLastNRecords},
+				{name: "seek", tail: GetLastNRecordsSeek},
+			} {
+				got, err := read.tail(context.Background(), path, 10)
+				if tt.wantErr {
+					if err == nil {
+						t.Errorf("%s: read %q, want a parse error", read.name, got)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", read.name, err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s = %q, want %q", read.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindTailOffset(t *testing.T) {
+	const multiline = "id,note\n1,\"first\nsecond\"\n2,\"a \"\"quoted\"\"\nline\"\n3,plain\n"
+
+	tests := []struct {
+		name    string
+		content string
+		comment rune
+		n       int
+		// want is the text the offset must point at the start of, or "" for
+		// offset 0.
+		want string
+	}{
+		{name: "plain", content: "id\n1\n2\n3\n", n: 2, want: "2\n3\n"},
+		{name: "multi-line field", content: multiline, n: 2, want: "2,\"a \"\"quoted"},
+		{name: "multi-line field at the start", content: multiline, n: 3, want: "1,\"first"},
+		{name: "multi-line fields only", content: multiline, n: 4, want: ""},
+		{name: "comment with a quote", content: "id,note\n1,a\n# 5\" tall\n2,b\n3,c\n", comment: '#', n: 3, want: "1,a"},
+		{name: "comment with quotes only", content: "id,note\n1,a\n# \"\n# \"\"\"\n2,b\n", comment: '#', n: 2, want: "1,a"},
+		{name: "multi-byte comment", content: "id\n1\n§ \"\n2\n", comment: '§', n: 2, want: "1\n§"},
+		{name: "comment character in a quoted field", content: "id,note\n1,\"x\n# not a comment \"\"\n\"\n2,b\n", comment: '#', n: 2, want: "1,\"x"},
+		{name: "comment character without comments", content: "id,note\n1,a\n#2,\"b\"\n3,c\n", n: 2, want: "#2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{Comment: tt.comment})
+			path := writeFixture(t, "records.csv", tt.content)
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			offset, err := findTailOffset(context.Background(), file, tt.n)
+			if err != nil {
+				t.Fatalf("findTailOffset: %v", err)
+			}
+			want := int64(0)
+			if tt.want != "" {
+				want = int64(strings.Index(tt.content, tt.want))
+			}
+			if offset != want {
+				t.Errorf("findTailOffset(%d) = %d (%.10q), want %d (%.10q)", tt.n, offset, tt.content[offset:], want, tt.content[want:])
+			}
+		})
+	}
+}
+
+func TestGetLastNRecordsSeekMatchesScanWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ReaderOptions
+		content string
+	}{
+		{
+			name:    "multi-line fields",
+			content: "id,note\n1,\"first\nsecond\"\n2,\"a \"\"quoted\"\"\nline\"\n3,plain\n",
+		},
+		{
+			name:    "comments with quotes",
+			opts:    ReaderOptions{Comment: '#'},
+			content: "id,note\n1,a\n# 5\" tall\n2,b\n# \"\n3,c\n",
+		},
+		{
+			// A tail row holding the original name of an aliased column
+			// must not be mistaken for the header and renamed.
+			name:    "aliases",
+			opts:    ReaderOptions{Aliases: map[string]string{"status": "state"}},
+			content: "id,status\n1,active\n2,status\n3,status\n",
+		},
+		{
+			name:    "redaction",
+			opts:    ReaderOptions{Redact: map[string]string{"name": RedactFull}},
+			content: "id,name\n1,Ann\n2,Bob\n3,Cy\n",
+		},
+	}
+	for _, tt := range tests {
+		setReaderOptions(t, tt.opts)
+		path := writeFixture(t, "records.csv", tt.content)
+		for _, n := range []int{1, 2, 3, 10} {
+			t.Run(fmt.Sprintf("%s/%d", tt.name, n), func(t *testing.T) {
+				want, err := GetLastNRecords(context.Background(), path, n)
+				if err != nil {
+					t.Fatalf("GetLastNRecords: %v", err)
+				}
+				got, err := GetLastNRecordsSeek(context.Background(), path, n)
+				if err != nil {
+					t.Fatalf("GetLastNRecordsSeek: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("GetLastNRecordsSeek(%d) = %q, scan returned %q", n, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{Comment: '#'})
+	path := writeFixture(t, "records.csv", "# exported 2024-09-08\n# source: ward A\nid,note\n1,a\n# checked, ok\n2,#b\n3,c\n#4,d\n")
+	ctx := context.Background()
+	wantRecords := [][]string{{"1", "a"}, {"2", "#b"}, {"3", "c"}}
+
+	total, err := CountDataRows(ctx, path, false)
+	if err != nil || total != 3 {
+		t.Errorf("CountDataRows = %d, %v; want 3", total, err)
+	}
+	first, header, err := GetFirstNRecords(ctx, path, 10)
+	if err != nil {
+		t.Fatalf("GetFirstNRecords: %v", err)
+	}
+	if want := []string{"id", "note"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+	if !reflect.DeepEqual(first, wantRecords) {
+		t.Errorf("GetFirstNRecords = %q, want %q", first, wantRecords)
+	}
+	for _, read := range []struct {
+		name string
+		tail TailFunc
+	}{
+		{name: "scan", tail: GetLastNRecords},
+		{name: "seek", tail: GetLastNRecordsSeek},
+	} {
+		last, _, err := TailWithHeader(ctx, read.tail, path, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", read.name, err)
+		}
+		if !reflect.DeepEqual(last, wantRecords[1:]) {
+			t.Errorf("%s tail = %q, want %q", read.name, last, wantRecords[1:])
+		}
+	}
+	matches, _, matched, err := SearchRecords(ctx, path, "checked", 10, false)
+	if err != nil || matched != 0 || len(matches) != 0 {
+		t.Errorf("SearchRecords found %d rows in a comment, %v; want none", matched, err)
+	}
+}
+
+func TestDelimiters(t *testing.T) {
+	tests := []struct {
+		name    string
+		comma   rune
+		content string
+	}{
+		{name: "tab", comma: '\t', content: "id\tname\tnote\n1\tAnn\ta, b\n2\tBob\t\"x\ty\"\n3\tCy\tplain\n"},
+		{name: "semicolon", comma: ';', content: "id;name;note\n1;Ann;a, b\n2;Bob;\"x;y\"\n3;Cy;plain\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{Comma: tt.comma})
+			path := writeFixture(t, "records.csv", tt.content)
+			ctx := context.Background()
+			sep := string(tt.comma)
+			wantHeader := []string{"id", "name", "note"}
+			wantRecords := [][]string{{"1", "Ann", "a, b"}, {"2", "Bob", "x" + sep + "y"}, {"3", "Cy", "plain"}}
+
+			if got := Delimiter(); got != tt.comma {
+				t.Errorf("Delimiter = %q, want %q", got, tt.comma)
+			}
+			first, header, err := GetFirstNRecords(ctx, path, 10)
+			if err != nil {
+				t.Fatalf("GetFirstNRecords: %v", err)
+			}
+			if !reflect.DeepEqual(header, wantHeader) {
+				t.Errorf("header = %q, want %q", header, wantHeader)
+			}
+			if !reflect.DeepEqual(first, wantRecords) {
+				t.Errorf("GetFirstNRecords = %q, want %q", first, wantRecords)
+			}
+			for _, read := range []struct {
+				name string
+				tail TailFunc
+			}{
+				{name: "scan", tail: GetLastNRecords},
+				{name: "seek", tail: GetLastNRecordsSeek},
+			} {
+				last, _, err := TailWithHeader(ctx, read.tail, path, 2)
+				if err != nil {
+					t.Fatalf("%s: %v", read.name, err)
+				}
+				if !reflect.DeepEqual(last, wantRecords[1:]) {
+					t.Errorf("%s tail = %q, want %q", read.name, last, wantRecords[1:])
+				}
+			}
+			matches, _, err := FilterRecords(ctx, path, "name", "Bob", 10, false, false)
+			if err != nil {
+				t.Fatalf("FilterRecords: %v", err)
+			}
+			if !reflect.DeepEqual(matches, wantRecords[1:2]) {
+				t.Errorf("FilterRecords = %q, want %q", matches, wantRecords[1:2])
+			}
+		})
+	}
+}
+
+func TestDelimiterMismatch(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{Comma: ';'})
+	path := writeFixture(t, "records.csv", "id,name\n1,Ann\n")
+
+	_, header, err := GetFirstNRecords(context.Background(), path, 10)
+	if err != nil {
+		t.Fatalf("GetFirstNRecords: %v", err)
+	}
+	if want := []string{"id,name"}; !reflect.DeepEqual(header, want) {
+		t.Errorf("header read with ';' = %q, want the whole line %q", header, want)
+	}
+}
+
+func TestGetLastNRecordsLenient(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,name\n1,a\n2,b,extra\n3,c\n4\n5,e\n")
+	want := [][]string{{"id", "name"}, {"1", "a"}, {"3", "c"}, {"5", "e"}}
+
+	for _, read := range []struct {
+		name    string
+		strict  TailFunc
+		lenient func(context.Context, string, int) ([][]string, int, error)
+	}{
+		{name: "scan", strict: GetLastNRecords, lenient: GetLastNRecordsLenient},
+		{name: "seek", strict: GetLastNRecordsSeek, lenient: GetLastNRecordsSeekLenient},
+	} {
+		t.Run(read.name, func(t *testing.T) {
+			if got, err := read.strict(context.Background(), path, 10); err == nil {
+				t.Errorf("strict read %q, want a parse error", got)
+			}
+
+			got, skipped, err := read.lenient(context.Background(), path, 10)
+			if err != nil {
+				t.Fatalf("lenient: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("lenient = %q, want %q", got, want)
+			}
+			if skipped != 2 {
+				t.Errorf("skipped = %d, want 2", skipped)
+			}
+		})
+	}
+}
+
+func TestCheckData(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    error
+	}{
+		{name: "zero bytes", content: "", want: ErrEmptyFile},
+		{name: "header only", content: "id,name\n", want: ErrNoDataRows},
+		{name: "header without a line break", content: "id,name", want: ErrNoDataRows},
+		{name: "one data row", content: "id,name\n1,a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			if err := CheckData(context.Background(), path); !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("CheckData = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := GetHeader(context.Background(), writeFixture(t, "empty.csv", "")); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("GetHeader on a zero-byte file = %v, want ErrEmptyFile", err)
+	}
+}
+
+func TestGetHeader(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "header only", content: "id,name\n", want: []string{"id", "name"}},
+		{name: "with data", content: "id,name\n1,a\n2,b\n", want: []string{"id", "name"}},
+		// Rows past the header would fail to parse, so reading them at
+		// all would fail the call.
+		{name: "malformed rows after the header", content: "id,name\n1,a,extra\n2,\"never closed\n3,c\n", want: []string{"id", "name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			got, err := GetHeader(ctx, path)
+			if err != nil {
+				t.Fatalf("GetHeader: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetHeader = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	path := writeFixture(t, "records.csv", "id,name\n1,a,extra\n")
+	if _, _, err := GetFirstNRecords(ctx, path, 1); err == nil {
+		t.Error("GetFirstNRecords succeeded on a malformed first row")
+	}
+}
+
+func TestGetTailRange(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	rows := func(from, to int) []string {
+		out := []string{}
+		for i := from; i <= to; i++ {
+			out = append(out, strconv.Itoa(i))
+		}
+		return out
+	}
+
+	tests := []struct {
+		name           string
+		fromEnd, count int
+		want           []string
+	}{
+		{name: "plain tail", fromEnd: 0, count: 3, want: rows(8, 10)},
+		{name: "interior", fromEnd: 3, count: 4, want: rows(4, 7)},
+		{name: "second page back", fromEnd: 2, count: 2, want: rows(7, 8)},
+		{name: "ending at the first row", fromEnd: 9, count: 1, want: rows(1, 1)},
+		{name: "clamped at the first row", fromEnd: 7, count: 5, want: rows(1, 3)},
+		{name: "whole file", fromEnd: 0, count: 10, want: rows(1, 10)},
+		{name: "more than the file", fromEnd: 0, count: 100, want: rows(1, 10)},
+		{name: "wholly before the first row", fromEnd: 10, count: 2, want: []string{}},
+		{name: "huge range", fromEnd: math.MaxInt, count: math.MaxInt, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, header, total, err := GetTailRange(context.Background(), path, tt.fromEnd, tt.count)
+			if err != nil {
+				t.Fatalf("GetTailRange: %v", err)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTailRange(%d, %d) = %q, want %q", tt.fromEnd, tt.count, got, tt.want)
+			}
+			if !reflect.DeepEqual(header, []string{"id"}) || total != 10 {
+				t.Errorf("header, total = %q, %d; want [id], 10", header, total)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ParseDate parses value with the configured DateLayout, if any, and then with
+// each of the standard date layouts, returning the first successful result.
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayoutsFor(readerOptions) {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a recognised date", value)
+}
+
+// dateLayoutsFor returns the layouts to try when parsing a date, with the
+// configured layout taking precedence over the standard ones.
+func dateLayoutsFor(opts ReaderOptions) []string {
+	if opts.DateLayout == "" {
+		return dateLayouts
+	}
+	return append([]string{opts.DateLayout}, dateLayouts...)
+}
+
+// FilterByDateRange returns up to limit data rows of the CSV file at filePath
+// whose column holds a date between from and to inclusive, along with the
+// header. Empty cells are skipped; any other cell that does not parse as a
+// date fails the read with an error naming the offending value. A limit of
+// zero or less means DefaultFilterLimit.
+func FilterByDateRange(ctx context.Context, filePath, column string, from, to time.Time, limit int) ([][]string, []string, error) {
+	if limit <= 0 {
+		limit = DefaultFilterLimit
+	}
+
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, column)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matches := [][]string{}
+	row := 0
+	for len(matches) < limit {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row++
+		if index >= len(record) || record[index] == "" {
+			continue
+		}
+
+		t, err := ParseDate(record[index])
+		if err != nil {
+			return nil, nil, fmt.Errorf("data row %d, column %q: %w", row, column, err)
+		}
+		if !t.Before(from) && !t.After(to) {
+			matches = append(matches, record)
+		}
+	}
+
+	return matches, reader.Header, nil
+}
@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterByDateRange(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,date\n1,2023-12-31\n2,2024-01-01\n3,2024-01-15T08:30:00Z\n4,\n5,2024-01-31\n6,2024-02-01\n")
+	day := func(value string) time.Time {
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		limit    int
+		wantIDs  []string
+	}{
+		{name: "boundaries included", from: "2024-01-01", to: "2024-01-31", wantIDs: []string{"2", "3", "5"}},
+		{name: "single day", from: "2024-01-31", to: "2024-01-31", wantIDs: []string{"5"}},
+		{name: "no matches", from: "2025-01-01", to: "2025-12-31", wantIDs: []string{}},
+		{name: "limit", from: "2023-01-01", to: "2024-12-31", limit: 2, wantIDs: []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, header, err := FilterByDateRange(context.Background(), path, "date", day(tt.from), day(tt.to), tt.limit)
+			if err != nil {
+				t.Fatalf("FilterByDateRange: %v", err)
+			}
+			if want := []string{"id", "date"}; !reflect.DeepEqual(header, want) {
+				t.Errorf("header = %q, want %q", header, want)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestFilterByDateRangeErrors(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,date\n1,2024-01-01\n2,\n3,next tuesday\n")
+	from, to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	_, _, err := FilterByDateRange(context.Background(), path, "date", from, to, 0)
+	if err == nil || !strings.Contains(err.Error(), `data row 3, column "date"`) || !strings.Contains(err.Error(), "next tuesday") {
+		t.Errorf("FilterByDateRange on an unparseable cell = %v, want an error naming data row 3 and the value", err)
+	}
+	if _, _, err := FilterByDateRange(context.Background(), path, "missing", from, to, 0); err == nil {
+		t.Error("FilterByDateRange succeeded on an unknown column")
+	}
+}
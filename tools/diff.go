@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultDiffLimit is the number of added, removed and changed records each
+// that DiffDatasets lists when the caller does not specify a limit.
+const DefaultDiffLimit = 100
+
+// Diff is the difference between two versions of a CSV file. When their
+// columns differ, SchemaChanged is set, AddedColumns and RemovedColumns name
+// the difference and no records are compared. Otherwise Added, Removed and
+// Changed list up to the limit of each kind, while the counts cover them all.
+type Diff struct {
+	Key            string              `json:"key"`
+	SchemaChanged  bool                `json:"schema_changed"`
+	AddedColumns   []string            `json:"added_columns,omitempty"`
+	RemovedColumns []string            `json:"removed_columns,omitempty"`
+	Added          []map[string]string `json:"added"`
+	Removed        []map[string]string `json:"removed"`
+	Changed        []ChangedRecord     `json:"changed"`
+	AddedCount     int                 `json:"added_count"`
+	RemovedCount   int                 `json:"removed_count"`
+	ChangedCount   int                 `json:"changed_count"`
+	Truncated      bool                `json:"truncated"`
+}
+
+// ChangedRecord is a record present in both files under Key whose cells
+// differ, with the old and new value of each changed column.
+type ChangedRecord struct {
+	Key     string                `json:"key"`
+	Changes map[string]CellChange `json:"changes"`
+}
+
+// CellChange is the value of a cell before and after.
+type CellChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DiffDatasets compares the CSV files at fromPath and toPath, matching their
+// data rows on the key column, which must hold a unique value in every row
+// of each file. Columns are matched by name, so reordering them is not a
+// change. The from file is held in memory and the to file is streamed. At
+// most limit records of each kind are listed; zero or less means
+// DefaultDiffLimit.
+func DiffDatasets(ctx context.Context, fromPath, toPath, key string, limit int) (*Diff, error) {
+	if limit <= 0 {
+		limit = DefaultDiffLimit
+	}
+	if err := CheckFileSize(fromPath); err != nil {
+		return nil, err
+	}
+
+	from, err := openRecords(ctx, fromPath)
+	if err != nil {
+		return nil, err
+	}
+	defer from.Close()
+	to, err := openRecords(ctx, toPath)
+	if err != nil {
+		return nil, err
+	}
+	defer to.Close()
+
+	diff := &Diff{Key: key, Added: []map[string]string{}, Removed: []map[string]string{}, Changed: []ChangedRecord{}}
+	diff.AddedColumns = missingColumns(to.Header, from.Header)
+	diff.RemovedColumns = missingColumns(from.Header, to.Header)
+	if len(diff.AddedColumns) > 0 || len(diff.RemovedColumns) > 0 {
+		diff.SchemaChanged = true
+		return diff, nil
+	}
+
+	fromIndex, err := queryableIndex(from.Header, key)
+	if err != nil {
+		return nil, err
+	}
+	toIndex, err := queryableIndex(to.Header, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// old holds the from rows by key; keys holds them in file order so the
+	// removed rows are listed in that order.
+	old := make(map[string][]string)
+	keys := []string{}
+	for {
+		record, err := from.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := cell(record, fromIndex)
+		if _, ok := old[value]; ok {
+			return nil, fmt.Errorf("key %q appears more than once in the from dataset", value)
+		}
+		old[value] = record
+		keys = append(keys, value)
+	}
+
+	seen := make(map[string]bool)
+	for {
+		record, err := to.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := cell(record, toIndex)
+		if seen[value] {
+			return nil, fmt.Errorf("key %q appears more than once in the to dataset", value)
+		}
+		seen[value] = true
+
+		before, ok := old[value]
+		if !ok {
+			diff.AddedCount++
+			if len(diff.Added) < limit {
+				diff.Added = append(diff.Added, RecordsToMaps(to.Header, [][]string{record})[0])
+			}
+			continue
+		}
+		delete(old, value)
+
+		changes := changedCells(from.Header, before, to.Header, record)
+		if len(changes) == 0 {
+			continue
+		}
+		diff.ChangedCount++
+		if len(diff.Changed) < limit {
+			diff.Changed = append(diff.Changed, ChangedRecord{Key: value, Changes: changes})
+		}
+	}
+
+	for _, value := range keys {
+		record, ok := old[value]
+		if !ok {
+			continue
+		}
+		diff.RemovedCount++
+		if len(diff.Removed) < limit {
+			diff.Removed = append(diff.Removed, RecordsToMaps(from.Header, [][]string{record})[0])
+		}
+	}
+
+	diff.Truncated = diff.AddedCount > limit || diff.RemovedCount > limit || diff.ChangedCount > limit
+	return diff, nil
+}
+
+// missingColumns returns the names in header that are not in other.
+func missingColumns(header, other []string) []string {
+	present := make(map[string]bool, len(other))
+	for _, name := range other {
+		present[name] = true
+	}
+	var missing []string
+	for _, name := range header {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// changedCells compares two rows column by column, matching the columns of
+// the two headers by name, and returns the cells that differ.
+func changedCells(fromHeader, from, toHeader, to []string) map[string]CellChange {
+	before := RecordsToMaps(fromHeader, [][]string{from})[0]
+	var changes map[string]CellChange
+	for i, name := range toHeader {
+		after := cell(to, i)
+		if before[name] == after {
+			continue
+		}
+		if changes == nil {
+			changes = make(map[string]CellChange)
+		}
+		changes[name] = CellChange{From: before[name], To: after}
+	}
+	return changes
+}
+
+// cell returns the field at index of record, or "" if the record is short.
+func cell(record []string, index int) string {
+	if index < len(record) {
+		return record[index]
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffDatasets(t *testing.T) {
+	from := writeFixture(t, "yesterday.csv", "id,name,dose\n1,Ann,10\n2,Bob,20\n3,Cy,30\n4,Di,40\n")
+	// The columns are reordered, which is not a change: 1 is unchanged, 2
+	// changed dose, 3 was removed, 4 changed name and dose and 5 was added.
+	to := writeFixture(t, "today.csv", "dose,id,name\n10,1,Ann\n25,2,Bob\n45,4,Dee\n50,5,Ed\n")
+
+	got, err := DiffDatasets(context.Background(), from, to, "id", 0)
+	if err != nil {
+		t.Fatalf("DiffDatasets: %v", err)
+	}
+	want := &Diff{
+		Key:     "id",
+		Added:   []map[string]string{{"id": "5", "name": "Ed", "dose": "50"}},
+		Removed: []map[string]string{{"id": "3", "name": "Cy", "dose": "30"}},
+		Changed: []ChangedRecord{
+			{Key: "2", Changes: map[string]CellChange{"dose": {From: "20", To: "25"}}},
+			{Key: "4", Changes: map[string]CellChange{"dose": {From: "40", To: "45"}, "name": {From: "Di", To: "Dee"}}},
+		},
+		AddedCount:   1,
+		RemovedCount: 1,
+		ChangedCount: 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffDatasets = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiffDatasetsLimit(t *testing.T) {
+	from := writeFixture(t, "yesterday.csv", "id,dose\n1,1\n2,2\n3,3\n")
+	to := writeFixture(t, "today.csv", "id,dose\n1,9\n2,9\n3,9\n4,4\n")
+
+	got, err := DiffDatasets(context.Background(), from, to, "id", 2)
+	if err != nil {
+		t.Fatalf("DiffDatasets: %v", err)
+	}
+	if len(got.Changed) != 2 || got.ChangedCount != 3 || !got.Truncated {
+		t.Errorf("changed = %d listed of %d, truncated = %v; want 2 of 3, true", len(got.Changed), got.ChangedCount, got.Truncated)
+	}
+	if len(got.Added) != 1 || got.AddedCount != 1 {
+		t.Errorf("added = %d listed of %d, want 1 of 1", len(got.Added), got.AddedCount)
+	}
+}
+
+func TestDiffDatasetsSchemaChange(t *testing.T) {
+	from := writeFixture(t, "yesterday.csv", "id,name,dose\n1,Ann,10\n")
+	to := writeFixture(t, "today.csv", "id,name,unit\n1,Ann,mg\n")
+
+	got, err := DiffDatasets(context.Background(), from, to, "id", 0)
+	if err != nil {
+		t.Fatalf("DiffDatasets: %v", err)
+	}
+	if !got.SchemaChanged || !reflect.DeepEqual(got.AddedColumns, []string{"unit"}) || !reflect.DeepEqual(got.RemovedColumns, []string{"dose"}) {
+		t.Errorf("DiffDatasets = %+v, want unit added and dose removed", got)
+	}
+	if len(got.Added)+len(got.Removed)+len(got.Changed) != 0 {
+		t.Errorf("records were compared despite the schema change: %+v", got)
+	}
+}
+
+func TestDiffDatasetsErrors(t *testing.T) {
+	unique := writeFixture(t, "unique.csv", "id,dose\n1,1\n2,2\n")
+	duplicated := writeFixture(t, "duplicated.csv", "id,dose\n1,1\n1,2\n")
+
+	tests := []struct {
+		name     string
+		from, to string
+		key      string
+		wantErr  string
+	}{
+		{name: "duplicate key in from", from: duplicated, to: unique, key: "id", wantErr: "more than once in the from dataset"},
+		{name: "duplicate key in to", from: unique, to: duplicated, key: "id", wantErr: "more than once in the to dataset"},
+		{name: "unknown key", from: unique, to: unique, key: "missing", wantErr: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DiffDatasets(context.Background(), tt.from, tt.to, tt.key, 0)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DiffDatasets = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+)
+
+// DefaultDistinctLimit is the number of values returned by the distinct_values
+// tool when the caller does not specify a limit.
+const DefaultDistinctLimit = 100
+
+// ValueCount is a distinct column value and, when requested, the number of
+// data rows holding it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count,omitempty"`
+}
+
+// DistinctValues streams the CSV file at filePath once and returns every
+// distinct value of column, including the empty string for blank cells. With
+// withCounts set the values carry their row counts and are sorted by
+// descending frequency, ties broken by value; otherwise they are sorted by
+// value alone.
+func DistinctValues(ctx context.Context, filePath, column string, withCounts bool) ([]ValueCount, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, column)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		if index < len(record) {
+			value = record[index]
+		}
+		counts[value]++
+	}
+
+	values := make([]ValueCount, 0, len(counts))
+	for value, count := range counts {
+		vc := ValueCount{Value: value}
+		if withCounts {
+			vc.Count = count
+		}
+		values = append(values, vc)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values, nil
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDistinctValues(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,ward\n1,B\n2,A\n3,\n4,B\n5,C\n6,B\n7,\n")
+
+	tests := []struct {
+		name       string
+		withCounts bool
+		want       []ValueCount
+	}{
+		{
+			name: "values only",
+			want: []ValueCount{{Value: ""}, {Value: "A"}, {Value: "B"}, {Value: "C"}},
+		},
+		{
+			name:       "with counts",
+			withCounts: true,
+			want:       []ValueCount{{Value: "B", Count: 3}, {Value: "", Count: 2}, {Value: "A", Count: 1}, {Value: "C", Count: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DistinctValues(context.Background(), path, "ward", tt.withCounts)
+			if err != nil {
+				t.Fatalf("DistinctValues: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DistinctValues = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistinctValuesUnknownColumn(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,ward\n1,A\n")
+	if _, err := DistinctValues(context.Background(), path, "missing", false); err == nil {
+		t.Error("DistinctValues succeeded on an unknown column")
+	}
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ExportFilter selects the rows ExportJSONL writes. When Column is set, a row
+// must have Value in it; when DateColumn is set, its date must fall between
+// From and To inclusive. Blank date cells never match. The zero value matches
+// every row.
+type ExportFilter struct {
+	Column     string
+	Value      string
+	DateColumn string
+	From       time.Time
+	To         time.Time
+}
+
+// ExportJSONL writes up to limit data rows of the CSV file at filePath that
+// match filter to w as JSON Lines: one object per line keyed by header name,
+// restricted to columns when it is not empty. Rows are written as they are
+// read, so the export is never held in memory. It returns the number of rows
+// written; a limit of zero or less means no limit.
+func ExportJSONL(ctx context.Context, w io.Writer, filePath string, filter ExportFilter, columns []string, limit int) (int, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	valueIndex, dateIndex := -1, -1
+	if filter.Column != "" {
+		if valueIndex, err = queryableIndex(reader.Header, filter.Column); err != nil {
+			return 0, err
+		}
+	}
+	if filter.DateColumn != "" {
+		if dateIndex, err = queryableIndex(reader.Header, filter.DateColumn); err != nil {
+			return 0, err
+		}
+	}
+	_, header, err := ProjectColumns(nil, reader.Header, columns)
+	if err != nil {
+		return 0, err
+	}
+	indexes := make([]int, len(header))
+	for i, name := range header {
+		indexes[i], _ = columnIndex(reader.Header, name)
+	}
+
+	encoder := json.NewEncoder(w)
+	object := make(map[string]string, len(header))
+	written, row := 0, 0
+	for limit <= 0 || written < limit {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		row++
+
+		if valueIndex >= 0 && (valueIndex >= len(record) || record[valueIndex] != filter.Value) {
+			continue
+		}
+		if dateIndex >= 0 {
+			if dateIndex >= len(record) || record[dateIndex] == "" {
+				continue
+			}
+			t, err := ParseDate(record[dateIndex])
+			if err != nil {
+				return written, fmt.Errorf("data row %d, column %q: %w", row, filter.DateColumn, err)
+			}
+			if t.Before(filter.From) || t.After(filter.To) {
+				continue
+			}
+		}
+
+		for i, name := range header {
+			object[name] = ""
+			if indexes[i] < len(record) {
+				object[name] = record[indexes[i]]
+			}
+		}
+		if err := encoder.Encode(object); err != nil {
+			return written, fmt.Errorf("could not write export: %w", err)
+		}
+		written++
+	}
+
+	return written, nil
+}
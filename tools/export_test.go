@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportJSONL(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,ward,date,note\n"+
+		"1,A,2024-01-05,plain\n"+
+		"2,B,2024-02-10,\"said \"\"no\"\", twice\"\n"+
+		"3,A,,\"two\nlines\"\n"+
+		"4,A,2024-03-01,tab\there \\ backslash\n"+
+		"5,B,2024-01-20,Müller ✓\n")
+	day := func(value string) time.Time {
+		d, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		filter   ExportFilter
+		columns  []string
+		limit    int
+		wantIDs  []string
+		wantKeys []string
+	}{
+		{name: "every row", wantIDs: []string{"1", "2", "3", "4", "5"}, wantKeys: []string{"date", "id", "note", "ward"}},
+		{name: "value", filter: ExportFilter{Column: "ward", Value: "A"}, wantIDs: []string{"1", "3", "4"}, wantKeys: []string{"date", "id", "note", "ward"}},
+		{name: "date range", filter: ExportFilter{DateColumn: "date", From: day("2024-01-01"), To: day("2024-01-31")}, wantIDs: []string{"1", "5"}, wantKeys: []string{"date", "id", "note", "ward"}},
+		{name: "columns", columns: []string{"id", "note"}, wantIDs: []string{"1", "2", "3", "4", "5"}, wantKeys: []string{"id", "note"}},
+		{name: "limit", limit: 2, wantIDs: []string{"1", "2"}, wantKeys: []string{"date", "id", "note", "ward"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			written, err := ExportJSONL(context.Background(), &b, path, tt.filter, tt.columns, tt.limit)
+			if err != nil {
+				t.Fatalf("ExportJSONL: %v", err)
+			}
+			if written != len(tt.wantIDs) {
+				t.Errorf("written = %d, want %d", written, len(tt.wantIDs))
+			}
+
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if len(lines) != len(tt.wantIDs) {
+				t.Fatalf("got %d lines, want %d: %q", len(lines), len(tt.wantIDs), b.String())
+			}
+			for i, line := range lines {
+				if !json.Valid([]byte(line)) {
+					t.Fatalf("line %d is not valid JSON: %s", i+1, line)
+				}
+				var object map[string]string
+				if err := json.Unmarshal([]byte(line), &object); err != nil {
+					t.Fatalf("line %d is not an object of strings: %v", i+1, err)
+				}
+				if object["id"] != tt.wantIDs[i] {
+					t.Errorf("line %d id = %q, want %q", i+1, object["id"], tt.wantIDs[i])
+				}
+				var keys []string
+				for key := range object {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				if !reflect.DeepEqual(keys, tt.wantKeys) {
+					t.Errorf("line %d keys = %q, want %q", i+1, keys, tt.wantKeys)
+				}
+			}
+		})
+	}
+}
+
+func TestExportJSONLBadDate(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,date\n1,2024-01-01\n2,soon\n")
+	var b bytes.Buffer
+	_, err := ExportJSONL(context.Background(), &b, path, ExportFilter{DateColumn: "date", To: time.Now()}, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "data row 2") {
+		t.Errorf("ExportJSONL on an unparseable date = %v, want an error naming data row 2", err)
+	}
+}
@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultOpenRetries is how many times opening a CSV file is retried after a
+// transient failure when ReaderOptions.OpenRetries is not set.
+const DefaultOpenRetries = 3
+
+// openRetryBackoff is the delay before the first retry of a failed open; it
+// doubles on each further retry.
+const openRetryBackoff = 20 * time.Millisecond
+
+// openCSV opens a file for openRetrying. Tests replace it to simulate
+// failures.
+var openCSV = os.Open
+
+// gzipMagic is the two-byte prefix every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// utf8BOM is the byte order mark Excel writes at the start of UTF-8 exports.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// openFile opens filePath for reading. Files with a .gz extension or that
+// start with the gzip magic number are decompressed transparently, and a
+// leading UTF-8 byte order mark is skipped. Reads fail with ctx's error once
+// ctx is done, so a long scan stops soon after it is cancelled.
+func openFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	file, err := openRetrying(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, err := isGzip(file, filePath)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r := contextReader{ctx: ctx, r: file}
+	if !compressed {
+		return &bomSkipper{Reader: skipBOM(r), Closer: file}, nil
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("could not open gzip stream: %w", err)
+	}
+	return &bomSkipper{Reader: skipBOM(gz), Closer: &gzipFile{Reader: gz, file: file}}, nil
+}
+
+// ErrFileTooLarge is returned by CheckFileSize for a file over the configured
+// maximum size.
+var ErrFileTooLarge = errors.New("file is larger than the configured maximum")
+
+// CheckFileSize returns ErrFileTooLarge when the file at filePath is larger
+// than ReaderOptions.MaxFileBytes. Callers that would hold the whole file in
+// memory check this first; streaming readers do not need to.
+func CheckFileSize(filePath string) error {
+	if readerOptions.MaxFileBytes <= 0 {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("could not stat csv file: %w", err)
+	}
+	if info.Size() > readerOptions.MaxFileBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrFileTooLarge, info.Size(), readerOptions.MaxFileBytes)
+	}
+	return nil
+}
+
+// Fingerprint identifies the current version of the file at filePath by its
+// size and modification time, so that a caller can tell whether the file has
+// changed since an earlier call.
+func Fingerprint(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("could not stat csv file: %w", err)
+	}
+	return fmt.Sprintf("%x-%x", info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// openRetrying opens filePath like os.Open, retrying up to
+// ReaderOptions.OpenRetries times with backoff when the open fails with a
+// transient error, such as a stale NFS handle while a writer replaces the
+// file. Other failures, a missing file included, are returned at once.
+func openRetrying(ctx context.Context, filePath string) (*os.File, error) {
+	backoff := openRetryBackoff
+	for attempt := 0; ; attempt++ {
+		file, err := openCSV(filePath)
+		if err == nil {
+			return file, nil
+		}
+		if !transientOpenError(err) || attempt >= readerOptions.OpenRetries {
+			return nil, fmt.Errorf("could not open csv file: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("could not open csv file: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// transientOpenError reports whether err is an open failure that is likely
+// to go away by itself shortly.
+func transientOpenError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.ESTALE, syscall.ETXTBSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextReader is a reader that fails with the error of ctx once ctx is
+// done. The CSV readers read files in buffered chunks, so it is checked about
+// once per chunk rather than once per row.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// skipBOM returns a reader over r that omits a leading UTF-8 byte order mark.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// bomSkipper pairs the reader returned by skipBOM with the stream it wraps.
+type bomSkipper struct {
+	io.Reader
+	io.Closer
+}
+
+// isGzip reports whether file holds gzip-compressed data, judging by its name
+// or, failing that, by its first two bytes.
+func isGzip(file *os.File, filePath string) (bool, error) {
+	if strings.HasSuffix(strings.ToLower(filePath), ".gz") {
+		return true, nil
+	}
+
+	magic := make([]byte, len(gzipMagic))
+	n, err := file.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("could not read csv file: %w", err)
+	}
+	return n == len(gzipMagic) && bytes.Equal(magic, gzipMagic), nil
+}
+
+// gzipFile closes both the gzip stream and the file underneath it.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,306 @@
+package tools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// gzipBytes compresses content.
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatalf("compressing fixture: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("compressing fixture: %v", err)
+	}
+	return b.Bytes()
+}
+
+// writeBytes writes data to a file named name in a temporary directory and
+// returns its path.
+func writeBytes(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	return path
+}
+
+func TestGzipFiles(t *testing.T) {
+	data := gzipBytes(t, "id,name\n1,a\n2,b\n3,c\n")
+	ctx := context.Background()
+
+	for _, name := range []string{"records.csv.gz", "records.csv"} {
+		path := writeBytes(t, name, data)
+		t.Run(name, func(t *testing.T) {
+			last, err := GetLastNRecords(ctx, path, 2)
+			if err != nil {
+				t.Fatalf("GetLastNRecords: %v", err)
+			}
+			if want := [][]string{{"2", "b"}, {"3", "c"}}; !reflect.DeepEqual(last, want) {
+				t.Errorf("GetLastNRecords = %q, want %q", last, want)
+			}
+
+			seek, err := GetLastNRecordsSeek(ctx, path, 2)
+			if err != nil {
+				t.Fatalf("GetLastNRecordsSeek: %v", err)
+			}
+			if !reflect.DeepEqual(seek, last) {
+				t.Errorf("GetLastNRecordsSeek = %q, want %q", seek, last)
+			}
+
+			first, header, err := GetFirstNRecords(ctx, path, 1)
+			if err != nil {
+				t.Fatalf("GetFirstNRecords: %v", err)
+			}
+			if !reflect.DeepEqual(header, []string{"id", "name"}) || !reflect.DeepEqual(first, [][]string{{"1", "a"}}) {
+				t.Errorf("GetFirstNRecords = %q, %q", first, header)
+			}
+
+			rows, err := CountDataRows(ctx, path, false)
+			if err != nil || rows != 3 {
+				t.Errorf("CountDataRows = %d, %v; want 3", rows, err)
+			}
+
+			matches, _, err := FilterRecords(ctx, path, "name", "b", 0, false, false)
+			if err != nil || !reflect.DeepEqual(matches, [][]string{{"2", "b"}}) {
+				t.Errorf("FilterRecords = %q, %v", matches, err)
+			}
+		})
+	}
+}
+
+func TestCorruptGzipFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bad header", func(t *testing.T) {
+		path := writeBytes(t, "records.csv.gz", []byte("id,name\n1,a\n"))
+		_, err := GetLastNRecords(ctx, path, 1)
+		if !errors.Is(err, gzip.ErrHeader) {
+			t.Errorf("GetLastNRecords error = %v, want one wrapping gzip.ErrHeader", err)
+		}
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		data := gzipBytes(t, "id,name\n1,a\n2,b\n3,c\n")
+		path := writeBytes(t, "records.csv.gz", data[:len(data)-6])
+		if _, err := GetLastNRecords(ctx, path, 1); err == nil {
+			t.Error("GetLastNRecords succeeded on a truncated gzip stream")
+		}
+	})
+}
+
+// slowReader yields one line per read, pausing before each.
+type slowReader struct {
+	delay time.Duration
+	lines int
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(r.delay)
+	r.lines++
+	return copy(p, fmt.Sprintf("%d,row\n", r.lines)), nil
+}
+
+func TestContextReaderTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	slow := &slowReader{delay: 5 * time.Millisecond}
+	start := time.Now()
+	_, err := newCSVReader(contextReader{ctx: ctx, r: slow}).ReadAll()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ReadAll of an endless slow reader = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReadAll took %s to notice the deadline", elapsed)
+	}
+	if slow.lines == 0 {
+		t.Error("the reader was never read from")
+	}
+}
+
+func TestCancelledReads(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id\n1\n2\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GetLastNRecords(ctx, path, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetLastNRecords = %v, want context.Canceled", err)
+	}
+	if _, _, err := GetFirstNRecords(ctx, path, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetFirstNRecords = %v, want context.Canceled", err)
+	}
+	if _, err := GetLastNRecordsSeek(ctx, path, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetLastNRecordsSeek = %v, want context.Canceled", err)
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	const content = "\ufeffid,name\n1,a\n2,b\n"
+	ctx := context.Background()
+	wantHeader := []string{"id", "name"}
+
+	for _, fixture := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "records.csv", data: []byte(content)},
+		{name: "records.csv.gz", data: gzipBytes(t, content)},
+	} {
+		path := writeBytes(t, fixture.name, fixture.data)
+		t.Run(fixture.name, func(t *testing.T) {
+			_, header, err := GetFirstNRecords(ctx, path, 1)
+			if err != nil {
+				t.Fatalf("GetFirstNRecords: %v", err)
+			}
+			if !reflect.DeepEqual(header, wantHeader) {
+				t.Errorf("header = %q, want %q", header, wantHeader)
+			}
+
+			for _, read := range []struct {
+				name string
+				tail TailFunc
+			}{
+				{name: "scan", tail: GetLastNRecords},
+				{name: "seek", tail: GetLastNRecordsSeek},
+			} {
+				all, err := read.tail(ctx, path, 10)
+				if err != nil {
+					t.Fatalf("%s: %v", read.name, err)
+				}
+				if len(all) == 0 || !reflect.DeepEqual(all[0], wantHeader) {
+					t.Errorf("%s = %q, want the header %q first", read.name, all, wantHeader)
+				}
+			}
+
+			matches, _, err := FilterRecords(ctx, path, "id", "1", 0, false, false)
+			if err != nil || !reflect.DeepEqual(matches, [][]string{{"1", "a"}}) {
+				t.Errorf("FilterRecords on the first column = %q, %v", matches, err)
+			}
+		})
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding encoding.Encoding
+		data     []byte
+		want     []string
+	}{
+		{name: "latin1", encoding: charmap.ISO8859_1, data: []byte("id,name\n1,Jos\xe9\n2,M\xfcller\n"), want: []string{"José", "Müller"}},
+		{name: "windows-1252", encoding: charmap.Windows1252, data: []byte("id,name\n1,Fran\xe7ois\n2,\x80 5\n"), want: []string{"François", "€ 5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{Encoding: tt.encoding})
+			path := writeBytes(t, "records.csv", tt.data)
+
+			first, _, err := GetFirstNRecords(context.Background(), path, 10)
+			if err != nil {
+				t.Fatalf("GetFirstNRecords: %v", err)
+			}
+			var names []string
+			for _, record := range first {
+				names = append(names, record[1])
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("names = %q, want %q", names, tt.want)
+			}
+
+			matches, _, err := FilterRecords(context.Background(), path, "name", tt.want[0], 0, false, false)
+			if err != nil || len(matches) != 1 {
+				t.Errorf("FilterRecords(%q) = %q, %v; want one row", tt.want[0], matches, err)
+			}
+		})
+	}
+}
+
+func TestOpenRetrying(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id\n1\n")
+	failure := func(errno syscall.Errno) error {
+		return &os.PathError{Op: "open", Path: path, Err: errno}
+	}
+
+	tests := []struct {
+		name         string
+		failures     []error
+		retries      int
+		wantAttempts int
+		wantErr      error
+	}{
+		{name: "EAGAIN once", failures: []error{failure(syscall.EAGAIN)}, retries: 3, wantAttempts: 2},
+		{name: "EINTR once", failures: []error{failure(syscall.EINTR)}, retries: 3, wantAttempts: 2},
+		{name: "EAGAIN then EINTR", failures: []error{failure(syscall.EAGAIN), failure(syscall.EINTR)}, retries: 3, wantAttempts: 3},
+		{name: "retries exhausted", failures: []error{failure(syscall.EAGAIN), failure(syscall.EAGAIN), failure(syscall.EAGAIN)}, retries: 2, wantAttempts: 3, wantErr: syscall.EAGAIN},
+		{name: "missing file", failures: []error{failure(syscall.ENOENT)}, retries: 3, wantAttempts: 1, wantErr: os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{OpenRetries: tt.retries})
+			attempts := 0
+			saved := openCSV
+			openCSV = func(name string) (*os.File, error) {
+				attempts++
+				if attempts <= len(tt.failures) {
+					return nil, tt.failures[attempts-1]
+				}
+				return os.Open(name)
+			}
+			t.Cleanup(func() { openCSV = saved })
+
+			records, err := GetLastNRecords(context.Background(), path, 1)
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetLastNRecords = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLastNRecords: %v", err)
+			}
+			if want := [][]string{{"1"}}; !reflect.DeepEqual(records, want) {
+				t.Errorf("GetLastNRecords = %q, want %q", records, want)
+			}
+		})
+	}
+}
+
+func TestOpenRetryingCancelled(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{OpenRetries: 10})
+	saved := openCSV
+	openCSV = func(name string) (*os.File, error) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EAGAIN}
+	}
+	t.Cleanup(func() { openCSV = saved })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := GetLastNRecords(ctx, "records.csv", 1); !errors.Is(err, syscall.EAGAIN) {
+		t.Errorf("GetLastNRecords = %v, want the open error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retrying took %s after the context was done", elapsed)
+	}
+}
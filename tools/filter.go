@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// DefaultFilterLimit is the number of matching rows returned by FilterRecords
+// when the caller does not specify a limit.
+const DefaultFilterLimit = 100
+
+// FilterRecords returns up to limit data rows of the CSV file at filePath whose
+// column equals value, along with the header. The column is resolved by header
+// name; a limit of zero or less means DefaultFilterLimit. When ignoreCase is
+// set, values are compared case-insensitively. When glob is set, value is a
+// pattern matched against the whole cell as described by GlobMatch.
+func FilterRecords(ctx context.Context, filePath, column, value string, limit int, ignoreCase, glob bool) ([][]string, []string, error) {
+	if limit <= 0 {
+		limit = DefaultFilterLimit
+	}
+
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, column)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if glob && ignoreCase {
+		value = strings.ToLower(value)
+	}
+
+	matches := [][]string{}
+	for len(matches) < limit {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if index >= len(record) {
+			continue
+		}
+
+		cell := record[index]
+		switch {
+		case glob && ignoreCase:
+			if GlobMatch(value, strings.ToLower(cell)) {
+				matches = append(matches, record)
+			}
+		case glob:
+			if GlobMatch(value, cell) {
+				matches = append(matches, record)
+			}
+		case cell == value || (ignoreCase && strings.EqualFold(cell, value)):
+			matches = append(matches, record)
+		}
+	}
+
+	return matches, reader.Header, nil
+}
+
+// GlobMatch reports whether the whole of value matches pattern, in which *
+// matches any run of characters, including none, and ? matches exactly one
+// character. Unlike path.Match, both also match a slash, and every other
+// character, brackets and backslashes included, only matches itself, so no
+// pattern is malformed.
+func GlobMatch(pattern, value string) bool {
+	p, v := []rune(pattern), []rune(value)
+	// star is the position in p just after the last * seen and retry the
+	// position in v it is currently taken to have matched up to, so that on
+	// a mismatch the * can be made to swallow one more character.
+	star, retry := -1, 0
+	i, j := 0, 0
+	for j < len(v) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			i++
+			star, retry = i, j
+		case i < len(p) && (p[i] == '?' || p[i] == v[j]):
+			i++
+			j++
+		case star >= 0:
+			retry++
+			i, j = star, retry
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
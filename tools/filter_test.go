@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{pattern: "Amoxi*", value: "Amoxicillin", want: true},
+		{pattern: "Amoxi*", value: "Amoxi", want: true},
+		{pattern: "Amoxi*", value: "amoxicillin", want: false},
+		{pattern: "*cillin", value: "Penicillin", want: true},
+		{pattern: "*cillin", value: "Penicillins", want: false},
+		{pattern: "*mox*", value: "Amoxicillin", want: true},
+		{pattern: "A?oxicillin", value: "Amoxicillin", want: true},
+		{pattern: "A?oxicillin", value: "Aoxicillin", want: false},
+		{pattern: "A?oxicillin", value: "Ammoxicillin", want: false},
+		{pattern: "?", value: "é", want: true},
+		{pattern: "*", value: "", want: true},
+		{pattern: "", value: "", want: true},
+		{pattern: "", value: "a", want: false},
+		{pattern: "a*b*c", value: "abbbc", want: true},
+		{pattern: "a*b*c", value: "acb", want: false},
+		{pattern: "*/*", value: "ward/A", want: true},
+		{pattern: "ward?A", value: "ward/A", want: true},
+		{pattern: "[ab]", value: "[ab]", want: true},
+		{pattern: "[ab]", value: "a", want: false},
+		{pattern: `a\*`, value: `a\bc`, want: true},
+		{pattern: `a\*`, value: "a*", want: false},
+	}
+	for _, tt := range tests {
+		if got := GlobMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("GlobMatch(%q, %q) = %t, want %t", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFilterRecordsGlob(t *testing.T) {
+	path := writeFixture(t, "drugs.csv", "id,drug\n1,Amoxicillin\n2,amoxicillin\n3,Penicillin\n4,Amoxi*\n5,Ibuprofen\n")
+
+	tests := []struct {
+		name       string
+		value      string
+		ignoreCase bool
+		glob       bool
+		want       []string
+	}{
+		{name: "exact by default", value: "Amoxi*", want: []string{"4"}},
+		{name: "prefix", value: "Amoxi*", glob: true, want: []string{"1", "4"}},
+		{name: "prefix ignoring case", value: "AMOXI*", glob: true, ignoreCase: true, want: []string{"1", "2", "4"}},
+		{name: "suffix", value: "*cillin", glob: true, want: []string{"1", "2", "3"}},
+		{name: "single character", value: "?moxicillin", glob: true, want: []string{"1", "2"}},
+		{name: "no match", value: "Para*", glob: true, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, err := FilterRecords(context.Background(), path, "drug", tt.value, 0, tt.ignoreCase, tt.glob)
+			if err != nil {
+				t.Fatalf("FilterRecords: %v", err)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterRecords(%q) ids = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	path := writeFixture(t, "records.csv", " id , ward ,dose\n1, A , 42 \n2,A,7\n3,B,  42\n")
+
+	tests := []struct {
+		name       string
+		trim       bool
+		column     string
+		wantHeader []string
+		wantWard   []string
+		wantDose   []string
+	}{
+		{name: "off", column: " ward ", wantHeader: []string{" id ", " ward ", "dose"}, wantWard: []string{"2"}, wantDose: []string{}},
+		{name: "on", trim: true, column: "ward", wantHeader: []string{"id", "ward", "dose"}, wantWard: []string{"1", "2"}, wantDose: []string{"1", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{TrimSpace: tt.trim})
+			ctx := context.Background()
+
+			matches, header, err := FilterRecords(ctx, path, tt.column, "A", 0, false, false)
+			if err != nil {
+				t.Fatalf("FilterRecords: %v", err)
+			}
+			if !reflect.DeepEqual(header, tt.wantHeader) {
+				t.Errorf("header = %q, want %q", header, tt.wantHeader)
+			}
+			if got := ids(matches); !reflect.DeepEqual(got, tt.wantWard) {
+				t.Errorf("FilterRecords ids = %q, want %q", got, tt.wantWard)
+			}
+
+			matches, _, err = QueryRecords(ctx, path, []Condition{{Column: "dose", Operator: OpEq, Value: "42"}}, false, 0)
+			if err != nil {
+				t.Fatalf("QueryRecords: %v", err)
+			}
+			if got := ids(matches); !reflect.DeepEqual(got, tt.wantDose) {
+				t.Errorf("QueryRecords ids = %q, want %q", got, tt.wantDose)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FixedWidthColumn is one field of a fixed-width file: the Width characters
+// beginning at character Start of a line, counting from 1.
+type FixedWidthColumn struct {
+	Name  string
+	Start int
+	Width int
+}
+
+// ValidateFixedWidth checks that columns is a usable fixed-width layout: there
+// is at least one column, every column has a unique name, a start of at least
+// 1 and a positive width, and no two columns overlap. Gaps between columns
+// are allowed and their characters are ignored.
+func ValidateFixedWidth(columns []FixedWidthColumn) error {
+	if len(columns) == 0 {
+		return errors.New("no columns are defined")
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		switch {
+		case column.Name == "":
+			return errors.New("a column has no name")
+		case seen[column.Name]:
+			return fmt.Errorf("column %q is defined twice", column.Name)
+		case column.Start < 1:
+			return fmt.Errorf("column %q must start at 1 or later, got %d", column.Name, column.Start)
+		case column.Width < 1:
+			return fmt.Errorf("column %q must have a positive width, got %d", column.Name, column.Width)
+		}
+		seen[column.Name] = true
+	}
+
+	sorted := append([]FixedWidthColumn{}, columns...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	for i := 1; i < len(sorted); i++ {
+		prev := sorted[i-1]
+		if sorted[i].Start < prev.Start+prev.Width {
+			return fmt.Errorf("columns %q and %q overlap", prev.Name, sorted[i].Name)
+		}
+	}
+	return nil
+}
+
+// fixedWidthReader reads a fixed-width file as records. The files have no
+// header line, so the first record it returns is the names of the columns;
+// after that each line that is neither blank nor a comment is one record.
+// Cells are cut by character, not byte, and have their padding removed. A
+// line may stop short of the last column, whose cells are then empty, but
+// one with text past the end of the last column is rejected with a
+// csv.ParseError so the lenient readers skip it like any malformed row.
+type fixedWidthReader struct {
+	r       *bufio.Reader
+	columns []FixedWidthColumn
+	// end is the number of characters the columns span.
+	end int
+
+	sentHeader bool
+	line       int
+}
+
+func newFixedWidthReader(r io.Reader, columns []FixedWidthColumn) *fixedWidthReader {
+	end := 0
+	for _, column := range columns {
+		end = max(end, column.Start+column.Width-1)
+	}
+	return &fixedWidthReader{r: bufio.NewReader(r), columns: columns, end: end}
+}
+
+// Read returns the next record like csv.Reader.Read.
+func (r *fixedWidthReader) Read() ([]string, error) {
+	if !r.sentHeader {
+		r.sentHeader = true
+		header := make([]string, len(r.columns))
+		for i, column := range r.columns {
+			header[i] = column.Name
+		}
+		return header, nil
+	}
+
+	for {
+		text, err := r.r.ReadString('\n')
+		if err != nil && (text == "" || !errors.Is(err, io.EOF)) {
+			return nil, err
+		}
+		r.line++
+
+		text = strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if c := readerOptions.Comment; c != 0 && strings.HasPrefix(text, string(c)) {
+			continue
+		}
+		return r.split(text)
+	}
+}
+
+// split cuts line into the cells of the layout.
+func (r *fixedWidthReader) split(line string) ([]string, error) {
+	chars := []rune(line)
+	if len(chars) > r.end && strings.TrimSpace(string(chars[r.end:])) != "" {
+		return nil, &csv.ParseError{
+			StartLine: r.line,
+			Line:      r.line,
+			Column:    r.end + 1,
+			Err:       fmt.Errorf("line is %d characters long but the columns end at %d", len(chars), r.end),
+		}
+	}
+
+	record := make([]string, len(r.columns))
+	for i, column := range r.columns {
+		start := min(column.Start-1, len(chars))
+		end := min(start+column.Width, len(chars))
+		record[i] = strings.TrimSpace(string(chars[start:end]))
+	}
+	return record, nil
+}
+
+// FieldPos returns the line and column of the given field of the record most
+// recently read, like csv.Reader.FieldPos.
+func (r *fixedWidthReader) FieldPos(field int) (line, column int) {
+	return r.line, r.columns[field].Start
+}
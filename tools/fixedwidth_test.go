@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixedWidthLayout is the layout of fixedWidthFixture: a four-character id, a
+// ten-character name, a one-character gap and a five-character dose.
+var fixedWidthLayout = []FixedWidthColumn{
+	{Name: "id", Start: 1, Width: 4},
+	{Name: "name", Start: 5, Width: 10},
+	{Name: "dose", Start: 16, Width: 5},
+}
+
+const fixedWidthFixture = "" +
+	"1   Ann       x   10\n" +
+	"\n" +
+	"2   Zoë Müller|  2.5\r\n" +
+	"3   Cy\n" +
+	"4   Di        -    7   \n" +
+	"5   Ed             1\n"
+
+func TestFixedWidthFiles(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{FixedWidth: fixedWidthLayout})
+	path := writeFixture(t, "records.txt", fixedWidthFixture)
+	ctx := context.Background()
+	wantHeader := []string{"id", "name", "dose"}
+	wantRecords := [][]string{
+		{"1", "Ann", "10"},
+		{"2", "Zoë Müller", "2.5"},
+		{"3", "Cy", ""},
+		{"4", "Di", "7"},
+		{"5", "Ed", "1"},
+	}
+
+	header, err := GetHeader(ctx, path)
+	if err != nil || !reflect.DeepEqual(header, wantHeader) {
+		t.Errorf("GetHeader = %q, %v; want %q", header, err, wantHeader)
+	}
+	first, _, err := GetFirstNRecords(ctx, path, 10)
+	if err != nil {
+		t.Fatalf("GetFirstNRecords: %v", err)
+	}
+	if !reflect.DeepEqual(first, wantRecords) {
+		t.Errorf("GetFirstNRecords = %q, want %q", first, wantRecords)
+	}
+	for _, read := range []struct {
+		name string
+		tail TailFunc
+	}{
+		{name: "scan", tail: GetLastNRecords},
+		{name: "seek", tail: GetLastNRecordsSeek},
+	} {
+		last, _, err := TailWithHeader(ctx, read.tail, path, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", read.name, err)
+		}
+		if !reflect.DeepEqual(last, wantRecords[3:]) {
+			t.Errorf("%s tail = %q, want %q", read.name, last, wantRecords[3:])
+		}
+	}
+	if rows, err := CountDataRows(ctx, path, false); err != nil || rows != 5 {
+		t.Errorf("CountDataRows = %d, %v; want 5", rows, err)
+	}
+	matches, _, err := FilterRecords(ctx, path, "name", "Zoë Müller", 0, false, false)
+	if err != nil || !reflect.DeepEqual(matches, wantRecords[1:2]) {
+		t.Errorf("FilterRecords = %q, %v; want %q", matches, err, wantRecords[1:2])
+	}
+	stats, err := ColumnStats(ctx, path, "dose")
+	if err != nil || stats.Count != 4 || stats.Sum != 20.5 {
+		t.Errorf("ColumnStats = %+v, %v; want 4 values summing to 20.5", stats, err)
+	}
+}
+
+func TestFixedWidthOverlongLines(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{FixedWidth: fixedWidthLayout})
+	path := writeFixture(t, "records.txt", "1   Ann          10\n2   Bob          20 extra\n3   Cy           30\n")
+	ctx := context.Background()
+
+	_, _, err := GetFirstNRecords(ctx, path, 10)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("GetFirstNRecords = %v, want an error on line 2", err)
+	}
+	records, skipped, err := GetLastNRecordsLenient(ctx, path, 2)
+	if err != nil {
+		t.Fatalf("GetLastNRecordsLenient: %v", err)
+	}
+	if want := [][]string{{"1", "Ann", "10"}, {"3", "Cy", "30"}}; !reflect.DeepEqual(records, want) || skipped != 1 {
+		t.Errorf("GetLastNRecordsLenient = %q, %d skipped; want %q and 1", records, skipped, want)
+	}
+}
+
+func TestValidateFixedWidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []FixedWidthColumn
+		wantErr string
+	}{
+		{name: "valid with a gap", columns: fixedWidthLayout},
+		{name: "no columns", wantErr: "no columns"},
+		{name: "no name", columns: []FixedWidthColumn{{Start: 1, Width: 2}}, wantErr: "no name"},
+		{name: "duplicate name", columns: []FixedWidthColumn{{Name: "a", Start: 1, Width: 2}, {Name: "a", Start: 3, Width: 2}}, wantErr: "defined twice"},
+		{name: "zero start", columns: []FixedWidthColumn{{Name: "a", Start: 0, Width: 2}}, wantErr: "start at 1"},
+		{name: "zero width", columns: []FixedWidthColumn{{Name: "a", Start: 1}}, wantErr: "positive width"},
+		{name: "overlap out of order", columns: []FixedWidthColumn{{Name: "b", Start: 4, Width: 2}, {Name: "a", Start: 1, Width: 4}}, wantErr: `"a" and "b" overlap`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFixedWidth(tt.columns)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateFixedWidth: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateFixedWidth = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"html"
+	"strings"
+)
+
+// markdownEscaper escapes the characters that would break a Markdown table
+// cell: pipes end the cell and line breaks end the row.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// MarkdownTable renders records as a GitHub-flavoured Markdown table with
+// header as the header row. Records shorter than header are padded with empty
+// cells and extra fields are dropped so every row has the same width.
+func MarkdownTable(header []string, records [][]string) string {
+	var b strings.Builder
+	writeMarkdownRow(&b, header, len(header))
+
+	b.WriteString("|")
+	for range header {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+
+	for _, record := range records {
+		writeMarkdownRow(&b, record, len(header))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeMarkdownRow writes the first width cells of row as one table row.
+func writeMarkdownRow(b *strings.Builder, row []string, width int) {
+	b.WriteString("|")
+	for i := 0; i < width; i++ {
+		cell := ""
+		if i < len(row) {
+			cell = markdownEscaper.Replace(row[i])
+		}
+		b.WriteString(" ")
+		b.WriteString(cell)
+		b.WriteString(" |")
+	}
+	b.WriteString("\n")
+}
+
+// HTMLTable renders records as an HTML table with header as a row of <th>
+// cells, padding and trimming rows to the header width like MarkdownTable.
+// Every cell is HTML-escaped, so the output can be embedded in a page without
+// the data injecting markup.
+func HTMLTable(header []string, records [][]string) string {
+	var b strings.Builder
+	b.WriteString("<table>\n<thead>\n")
+	writeHTMLRow(&b, "th", header, len(header))
+	b.WriteString("</thead>\n<tbody>\n")
+	for _, record := range records {
+		writeHTMLRow(&b, "td", record, len(header))
+	}
+	b.WriteString("</tbody>\n</table>")
+	return b.String()
+}
+
+// writeHTMLRow writes the first width cells of row as one table row of tag
+// cells.
+func writeHTMLRow(b *strings.Builder, tag string, row []string, width int) {
+	b.WriteString("<tr>")
+	for i := 0; i < width; i++ {
+		cell := ""
+		if i < len(row) {
+			cell = html.EscapeString(row[i])
+		}
+		b.WriteString("<" + tag + ">" + cell + "</" + tag + ">")
+	}
+	b.WriteString("</tr>\n")
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownTable(t *testing.T) {
+	header := []string{"id", "note"}
+
+	tests := []struct {
+		name    string
+		records [][]string
+		want    string
+	}{
+		{
+			name:    "no records",
+			records: nil,
+			want:    "| id | note |\n| --- | --- |",
+		},
+		{
+			name:    "plain cells",
+			records: [][]string{{"1", "stable"}, {"2", "improving"}},
+			want:    "| id | note |\n| --- | --- |\n| 1 | stable |\n| 2 | improving |",
+		},
+		{
+			name:    "pipes and backslashes",
+			records: [][]string{{"1", `a|b\c`}},
+			want:    "| id | note |\n| --- | --- |\n| 1 | a\\|b\\\\c |",
+		},
+		{
+			name:    "line breaks",
+			records: [][]string{{"1", "one\ntwo\r\nthree\rfour"}},
+			want:    "| id | note |\n| --- | --- |\n| 1 | one<br>two<br>three<br>four |",
+		},
+		{
+			name:    "short and long rows",
+			records: [][]string{{"1"}, {"2", "b", "extra"}},
+			want:    "| id | note |\n| --- | --- |\n| 1 |  |\n| 2 | b |",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkdownTable(header, tt.records); got != tt.want {
+				t.Errorf("MarkdownTable =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLTable(t *testing.T) {
+	header := []string{"id", "note"}
+
+	tests := []struct {
+		name    string
+		records [][]string
+		want    string
+	}{
+		{
+			name:    "no records",
+			records: nil,
+			want:    "<table>\n<thead>\n<tr><th>id</th><th>note</th></tr>\n</thead>\n<tbody>\n</tbody>\n</table>",
+		},
+		{
+			name:    "script cell",
+			records: [][]string{{"1", "<script>alert(1)</script>"}},
+			want:    "<table>\n<thead>\n<tr><th>id</th><th>note</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>&lt;script&gt;alert(1)&lt;/script&gt;</td></tr>\n</tbody>\n</table>",
+		},
+		{
+			name:    "ampersands and quotes",
+			records: [][]string{{"1", `a & "b" 'c'`}},
+			want:    "<table>\n<thead>\n<tr><th>id</th><th>note</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td>a &amp; &#34;b&#34; &#39;c&#39;</td></tr>\n</tbody>\n</table>",
+		},
+		{
+			name:    "short and long rows",
+			records: [][]string{{"1"}, {"2", "b", "extra"}},
+			want:    "<table>\n<thead>\n<tr><th>id</th><th>note</th></tr>\n</thead>\n<tbody>\n<tr><td>1</td><td></td></tr>\n<tr><td>2</td><td>b</td></tr>\n</tbody>\n</table>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLTable(header, tt.records); got != tt.want {
+				t.Errorf("HTMLTable =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLTableEscapesHeader(t *testing.T) {
+	got := HTMLTable([]string{"<b>id</b>"}, nil)
+	if strings.Contains(got, "<b>") {
+		t.Errorf("HTMLTable = %s, want the header escaped", got)
+	}
+}
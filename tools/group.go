@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+)
+
+// DefaultGroupLimit is the number of groups returned by the group_count tool
+// when the caller does not specify a limit.
+const DefaultGroupLimit = 100
+
+// Group is a distinct value of the grouping column with the number of data
+// rows holding it. When a metric column was given, Sum is the total of its
+// numeric cells in those rows and Skipped the number of its cells that were
+// empty or not finite numbers.
+type Group struct {
+	Value   string   `json:"value"`
+	Count   int      `json:"count"`
+	Sum     *float64 `json:"sum,omitempty"`
+	Skipped int      `json:"skipped,omitempty"`
+}
+
+// GroupCount streams the CSV file at filePath once and counts the data rows
+// per distinct value of groupBy, blank cells included, sorted by descending
+// count with ties broken by value. When metric is not empty the numeric
+// values of that column are also summed within each group.
+func GroupCount(ctx context.Context, filePath, groupBy, metric string) ([]Group, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, groupBy)
+	if err != nil {
+		return nil, err
+	}
+	metricIndex := -1
+	if metric != "" {
+		if metricIndex, err = queryableIndex(reader.Header, metric); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make(map[string]*Group)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		if index < len(record) {
+			value = record[index]
+		}
+		group, ok := groups[value]
+		if !ok {
+			group = &Group{Value: value}
+			if metricIndex >= 0 {
+				group.Sum = new(float64)
+			}
+			groups[value] = group
+		}
+		group.Count++
+
+		if metricIndex < 0 {
+			continue
+		}
+		if metricIndex >= len(record) {
+			group.Skipped++
+			continue
+		}
+		n, ok := parseNumber(record[metricIndex])
+		if !ok {
+			group.Skipped++
+			continue
+		}
+		*group.Sum += n
+	}
+
+	result := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result, nil
+}
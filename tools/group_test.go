@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGroupCount(t *testing.T) {
+	const content = "id,ward,cost\n1,A,10\n2,B,4\n3,A,2.5\n4,,1\n5,C,x\n6,B,6\n7,A,\n"
+	sum := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		metric string
+		want   []Group
+	}{
+		{
+			name: "count only",
+			want: []Group{
+				{Value: "A", Count: 3},
+				{Value: "B", Count: 2},
+				{Value: "", Count: 1},
+				{Value: "C", Count: 1},
+			},
+		},
+		{
+			name:   "sum by metric",
+			metric: "cost",
+			want: []Group{
+				{Value: "A", Count: 3, Sum: sum(12.5), Skipped: 1},
+				{Value: "B", Count: 2, Sum: sum(10)},
+				{Value: "", Count: 1, Sum: sum(1)},
+				{Value: "C", Count: 1, Sum: sum(0), Skipped: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", content)
+			groups, err := GroupCount(context.Background(), path, "ward", tt.metric)
+			if err != nil {
+				t.Fatalf("GroupCount: %v", err)
+			}
+			if !reflect.DeepEqual(groups, tt.want) {
+				got, _ := json.Marshal(groups)
+				want, _ := json.Marshal(tt.want)
+				t.Errorf("GroupCount = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGroupCountUnknownColumn(t *testing.T) {
+	path := writeFixture(t, "records.csv", "ward,cost\nA,1\n")
+	if _, err := GroupCount(context.Background(), path, "missing", ""); err == nil {
+		t.Error("GroupCount succeeded on an unknown group_by column")
+	}
+	if _, err := GroupCount(context.Background(), path, "ward", "missing"); err == nil {
+		t.Error("GroupCount succeeded on an unknown metric column")
+	}
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sort"
+)
+
+// DefaultHistogramBins is the number of bins ColumnHistogram divides a numeric
+// column into when the caller does not specify one.
+const DefaultHistogramBins = 10
+
+// MaxHistogramBins is the largest number of bins ColumnHistogram accepts.
+const MaxHistogramBins = 1000
+
+// Histogram types reported in Histogram.Type.
+const (
+	HistogramNumeric     = "numeric"
+	HistogramCategorical = "categorical"
+)
+
+// Histogram is the distribution of the values of a column. A numeric column,
+// one whose non-blank cells are all numbers, is counted in Bins; any other
+// column in Values, most frequent first. Blank is the number of blank cells,
+// which are counted in neither.
+type Histogram struct {
+	Column string         `json:"column"`
+	Type   string         `json:"type"`
+	Bins   []HistogramBin `json:"bins,omitempty"`
+	Values []ValueCount   `json:"values,omitempty"`
+	// Distinct is the number of distinct values of a categorical column,
+	// which exceeds len(Values) when Truncated is set.
+	Distinct  int  `json:"distinct,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
+	Blank     int  `json:"blank"`
+}
+
+// HistogramBin counts the values from Min up to but excluding Max, except in
+// the last bin, which includes Max.
+type HistogramBin struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// ColumnHistogram streams the CSV file at filePath once and returns the
+// distribution of column. A numeric column is divided into bins equal-width
+// bins between its smallest and largest value; a categorical column lists at
+// most limit values. Zero or less selects DefaultHistogramBins and
+// DefaultDistinctLimit respectively. Memory use grows with the number of
+// distinct values, not with the number of rows.
+func ColumnHistogram(ctx context.Context, filePath, column string, bins, limit int) (*Histogram, error) {
+	if bins <= 0 {
+		bins = DefaultHistogramBins
+	}
+	if limit <= 0 {
+		limit = DefaultDistinctLimit
+	}
+
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, column)
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := &Histogram{Column: column}
+	counts := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if index >= len(record) || record[index] == "" {
+			histogram.Blank++
+			continue
+		}
+		counts[record[index]]++
+	}
+
+	if numbers, ok := numericCounts(counts); ok && len(numbers) > 0 {
+		histogram.Type = HistogramNumeric
+		histogram.Bins = binCounts(numbers, bins)
+		return histogram, nil
+	}
+
+	histogram.Type = HistogramCategorical
+	histogram.Distinct = len(counts)
+	for value, count := range counts {
+		histogram.Values = append(histogram.Values, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(histogram.Values, func(i, j int) bool {
+		if histogram.Values[i].Count != histogram.Values[j].Count {
+			return histogram.Values[i].Count > histogram.Values[j].Count
+		}
+		return histogram.Values[i].Value < histogram.Values[j].Value
+	})
+	if len(histogram.Values) > limit {
+		histogram.Values = histogram.Values[:limit]
+		histogram.Truncated = true
+	}
+	return histogram, nil
+}
+
+// numericCounts converts the keys of counts to numbers, merging keys that
+// spell the same number. It reports false if any key is not a number.
+func numericCounts(counts map[string]int) (map[float64]int, bool) {
+	numbers := make(map[float64]int, len(counts))
+	for value, count := range counts {
+		number, ok := parseNumber(value)
+		if !ok {
+			return nil, false
+		}
+		numbers[number] += count
+	}
+	return numbers, true
+}
+
+// binCounts divides the range of the keys of numbers into n equal-width bins
+// and counts the values in each. When every value is the same there is a
+// single bin.
+func binCounts(numbers map[float64]int, n int) []HistogramBin {
+	low, high := math.Inf(1), math.Inf(-1)
+	for number := range numbers {
+		low, high = math.Min(low, number), math.Max(high, number)
+	}
+	if low == high {
+		n = 1
+	}
+
+	width := (high - low) / float64(n)
+	bins := make([]HistogramBin, n)
+	for i := range bins {
+		bins[i].Min = low + float64(i)*width
+		bins[i].Max = low + float64(i+1)*width
+	}
+	bins[n-1].Max = high
+
+	for number, count := range numbers {
+		i := n - 1
+		if width > 0 {
+			i = min(int((number-low)/width), n-1)
+		}
+		bins[i].Count += count
+	}
+	return bins
+}
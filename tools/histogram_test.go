@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// doses returns a CSV file of one row per value, with the values in a dose
+// column.
+func doses(values ...string) string {
+	var b strings.Builder
+	b.WriteString("id,dose\n")
+	for i, value := range values {
+		fmt.Fprintf(&b, "%d,%s\n", i+1, value)
+	}
+	return b.String()
+}
+
+func TestColumnHistogram(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		bins    int
+		limit   int
+		want    Histogram
+	}{
+		{
+			name:    "numeric",
+			content: doses("0", "1", "2.5", "5", "", "7.5", "9", "10"),
+			bins:    4,
+			want: Histogram{Column: "dose", Type: HistogramNumeric, Blank: 1, Bins: []HistogramBin{
+				{Min: 0, Max: 2.5, Count: 2},
+				{Min: 2.5, Max: 5, Count: 1},
+				{Min: 5, Max: 7.5, Count: 1},
+				{Min: 7.5, Max: 10, Count: 3},
+			}},
+		},
+		{
+			name:    "last bin includes the maximum",
+			content: doses("0", "10", "10"),
+			bins:    2,
+			want: Histogram{Column: "dose", Type: HistogramNumeric, Bins: []HistogramBin{
+				{Min: 0, Max: 5, Count: 1},
+				{Min: 5, Max: 10, Count: 2},
+			}},
+		},
+		{
+			name:    "single value",
+			content: doses("3", "3.0", "03"),
+			bins:    5,
+			want:    Histogram{Column: "dose", Type: HistogramNumeric, Bins: []HistogramBin{{Min: 3, Max: 3, Count: 3}}},
+		},
+		{
+			name:    "categorical",
+			content: doses("low", "high", "", "low", "5", "low", "high"),
+			want: Histogram{Column: "dose", Type: HistogramCategorical, Distinct: 3, Blank: 1, Values: []ValueCount{
+				{Value: "low", Count: 3},
+				{Value: "high", Count: 2},
+				{Value: "5", Count: 1},
+			}},
+		},
+		{
+			name:    "categorical truncated",
+			content: doses("c", "b", "a", "b", "c", "c"),
+			limit:   2,
+			want: Histogram{Column: "dose", Type: HistogramCategorical, Distinct: 3, Truncated: true, Values: []ValueCount{
+				{Value: "c", Count: 3},
+				{Value: "b", Count: 2},
+			}},
+		},
+		{
+			name:    "blank only",
+			content: doses("", ""),
+			want:    Histogram{Column: "dose", Type: HistogramCategorical, Blank: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			got, err := ColumnHistogram(context.Background(), path, "dose", tt.bins, tt.limit)
+			if err != nil {
+				t.Fatalf("ColumnHistogram: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ColumnHistogram = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnHistogramDefaultBins(t *testing.T) {
+	path := writeFixture(t, "records.csv", "dose\n0\n100\n")
+	got, err := ColumnHistogram(context.Background(), path, "dose", 0, 0)
+	if err != nil {
+		t.Fatalf("ColumnHistogram: %v", err)
+	}
+	if len(got.Bins) != DefaultHistogramBins {
+		t.Errorf("got %d bins, want %d", len(got.Bins), DefaultHistogramBins)
+	}
+	if _, err := ColumnHistogram(context.Background(), path, "missing", 0, 0); err == nil {
+		t.Error("ColumnHistogram succeeded on an unknown column")
+	}
+}
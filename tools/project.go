@@ -0,0 +1,36 @@
+package tools
+
+// ProjectColumns restricts records to the wanted columns, in the order given,
+// resolving each by name against header. It returns the projected records and
+// the matching header, which names each column as header does even when it
+// was asked for by its original name. An empty wanted list returns records
+// and header unchanged; a name that is not in header is an error.
+func ProjectColumns(records [][]string, header, wanted []string) ([][]string, []string, error) {
+	if len(wanted) == 0 {
+		return records, header, nil
+	}
+
+	indexes := make([]int, len(wanted))
+	names := make([]string, len(wanted))
+	for i, column := range wanted {
+		index, err := columnIndex(header, column)
+		if err != nil {
+			return nil, nil, err
+		}
+		indexes[i] = index
+		names[i] = header[index]
+	}
+
+	projected := make([][]string, len(records))
+	for i, record := range records {
+		row := make([]string, len(indexes))
+		for j, index := range indexes {
+			if index < len(record) {
+				row[j] = record[index]
+			}
+		}
+		projected[i] = row
+	}
+
+	return projected, names, nil
+}
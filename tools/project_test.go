@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestProjectColumns(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{Aliases: map[string]string{"pt_nm": "patient"}})
+	header := []string{"id", "patient", "dose"}
+	records := [][]string{{"1", "Ann", "5mg"}, {"2", "Bob"}}
+
+	tests := []struct {
+		name       string
+		wanted     []string
+		wantHeader []string
+		want       [][]string
+		wantErr    bool
+	}{
+		{name: "all columns", wanted: nil, wantHeader: header, want: records},
+		{name: "reordered", wanted: []string{"dose", "id"}, wantHeader: []string{"dose", "id"}, want: [][]string{{"5mg", "1"}, {"", "2"}}},
+		{name: "by alias", wanted: []string{"patient"}, wantHeader: []string{"patient"}, want: [][]string{{"Ann"}, {"Bob"}}},
+		{name: "by original name", wanted: []string{"pt_nm", "id"}, wantHeader: []string{"patient", "id"}, want: [][]string{{"Ann", "1"}, {"Bob", "2"}}},
+		{name: "unknown column", wanted: []string{"ward"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotHeader, err := ProjectColumns(records, header, tt.wanted)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ProjectColumns = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProjectColumns: %v", err)
+			}
+			if !reflect.DeepEqual(gotHeader, tt.wantHeader) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProjectColumns = %q, %q; want %q, %q", gotHeader, got, tt.wantHeader, tt.want)
+			}
+		})
+	}
+}
+
+func TestAliasedColumnsInResults(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{Aliases: map[string]string{"pt_nm": "patient"}})
+	path := writeFixture(t, "records.csv", "id,pt_nm\n1,Ann\n2,Bob\n3,Ann\n")
+
+	for _, column := range []string{"patient", "pt_nm"} {
+		t.Run(column, func(t *testing.T) {
+			records, header, err := FilterRecords(context.Background(), path, column, "Ann", 0, false, false)
+			if err != nil {
+				t.Fatalf("FilterRecords: %v", err)
+			}
+			_, header, err = ProjectColumns(records, header, []string{column})
+			if err != nil {
+				t.Fatalf("ProjectColumns: %v", err)
+			}
+			if want := []string{"patient"}; !reflect.DeepEqual(header, want) {
+				t.Errorf("header = %q, want %q", header, want)
+			}
+			if len(records) != 2 {
+				t.Errorf("FilterRecords on %q matched %d records, want 2", column, len(records))
+			}
+		})
+	}
+}
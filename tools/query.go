@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Operators accepted in a Condition.
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpContains = "contains"
+	OpGt       = "gt"
+	OpLt       = "lt"
+	OpGte      = "gte"
+	OpLte      = "lte"
+)
+
+// Condition compares the cell of Column with Value. OpEq and OpNe compare the
+// text exactly and OpContains looks for Value as a case-insensitive substring.
+// The ordering operators compare numerically; cells that are not numbers never
+// satisfy them.
+type Condition struct {
+	Column   string
+	Operator string
+	Value    string
+}
+
+// QueryRecords returns up to limit data rows of the CSV file at filePath that
+// satisfy every condition, or any of them when matchAny is set, along with the
+// header. A limit of zero or less means DefaultFilterLimit.
+func QueryRecords(ctx context.Context, filePath string, conditions []Condition, matchAny bool, limit int) ([][]string, []string, error) {
+	if limit <= 0 {
+		limit = DefaultFilterLimit
+	}
+
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	predicates := make([]func([]string) bool, len(conditions))
+	for i, condition := range conditions {
+		if predicates[i], err = conditionPredicate(reader.Header, condition); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	matches := [][]string{}
+	for len(matches) < limit {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if matchesConditions(record, predicates, matchAny) {
+			matches = append(matches, record)
+		}
+	}
+
+	return matches, reader.Header, nil
+}
+
+// matchesConditions reports whether record satisfies all predicates, or any
+// of them when matchAny is set.
+func matchesConditions(record []string, predicates []func([]string) bool, matchAny bool) bool {
+	for _, predicate := range predicates {
+		if predicate(record) == matchAny {
+			return matchAny
+		}
+	}
+	return !matchAny
+}
+
+// conditionPredicate returns a function reporting whether a row with header
+// satisfies condition.
+func conditionPredicate(header []string, condition Condition) (func([]string) bool, error) {
+	index, err := queryableIndex(header, condition.Column)
+	if err != nil {
+		return nil, err
+	}
+	cell := func(record []string) string {
+		if index < len(record) {
+			return record[index]
+		}
+		return ""
+	}
+
+	switch condition.Operator {
+	case OpEq:
+		return func(record []string) bool { return cell(record) == condition.Value }, nil
+	case OpNe:
+		return func(record []string) bool { return cell(record) != condition.Value }, nil
+	case OpContains:
+		value := strings.ToLower(condition.Value)
+		return func(record []string) bool { return strings.Contains(strings.ToLower(cell(record)), value) }, nil
+	}
+
+	var compare func(a, b float64) bool
+	switch condition.Operator {
+	case OpGt:
+		compare = func(a, b float64) bool { return a > b }
+	case OpLt:
+		compare = func(a, b float64) bool { return a < b }
+	case OpGte:
+		compare = func(a, b float64) bool { return a >= b }
+	case OpLte:
+		compare = func(a, b float64) bool { return a <= b }
+	default:
+		return nil, fmt.Errorf("operator must be one of %s, %s, %s, %s, %s, %s or %s, got %q",
+			OpEq, OpNe, OpContains, OpGt, OpLt, OpGte, OpLte, condition.Operator)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(condition.Value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("operator %s on column %q needs a numeric value, got %q", condition.Operator, condition.Column, condition.Value)
+	}
+	return func(record []string) bool {
+		number, err := strconv.ParseFloat(strings.TrimSpace(cell(record)), 64)
+		return err == nil && compare(number, value)
+	}, nil
+}
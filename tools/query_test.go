@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestQueryRecords(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,ward,dose,note\n"+
+		"1,A,10,Daily\n"+
+		"2,B,2.5,\n"+
+		"3,A,n/a,daily dose\n"+
+		"4,C, 40 ,weekly\n"+
+		"5,A,-1,DAILY\n")
+
+	tests := []struct {
+		name       string
+		conditions []Condition
+		matchAny   bool
+		limit      int
+		wantIDs    []string
+	}{
+		{name: "eq", conditions: []Condition{{Column: "ward", Operator: OpEq, Value: "A"}}, wantIDs: []string{"1", "3", "5"}},
+		{name: "eq is case-sensitive", conditions: []Condition{{Column: "note", Operator: OpEq, Value: "daily"}}, wantIDs: []string{}},
+		{name: "eq empty cell", conditions: []Condition{{Column: "note", Operator: OpEq, Value: ""}}, wantIDs: []string{"2"}},
+		{name: "ne", conditions: []Condition{{Column: "ward", Operator: OpNe, Value: "A"}}, wantIDs: []string{"2", "4"}},
+		{name: "contains is case-insensitive", conditions: []Condition{{Column: "note", Operator: OpContains, Value: "DAIly"}}, wantIDs: []string{"1", "3", "5"}},
+		{name: "gt skips non-numeric cells", conditions: []Condition{{Column: "dose", Operator: OpGt, Value: "2.5"}}, wantIDs: []string{"1", "4"}},
+		{name: "gte includes the value", conditions: []Condition{{Column: "dose", Operator: OpGte, Value: "2.5"}}, wantIDs: []string{"1", "2", "4"}},
+		{name: "lt", conditions: []Condition{{Column: "dose", Operator: OpLt, Value: "2.5"}}, wantIDs: []string{"5"}},
+		{name: "lte includes the value", conditions: []Condition{{Column: "dose", Operator: OpLte, Value: " 2.5 "}}, wantIDs: []string{"2", "5"}},
+		{
+			name: "all",
+			conditions: []Condition{
+				{Column: "ward", Operator: OpEq, Value: "A"},
+				{Column: "note", Operator: OpContains, Value: "daily"},
+				{Column: "dose", Operator: OpGte, Value: "0"},
+			},
+			wantIDs: []string{"1"},
+		},
+		{
+			name: "any",
+			conditions: []Condition{
+				{Column: "ward", Operator: OpEq, Value: "B"},
+				{Column: "dose", Operator: OpGt, Value: "20"},
+				{Column: "note", Operator: OpEq, Value: "DAILY"},
+			},
+			matchAny: true,
+			wantIDs:  []string{"2", "4", "5"},
+		},
+		{
+			name: "all with ne and lt",
+			conditions: []Condition{
+				{Column: "ward", Operator: OpNe, Value: "C"},
+				{Column: "dose", Operator: OpLt, Value: "5"},
+			},
+			wantIDs: []string{"2", "5"},
+		},
+		{
+			name: "any with lte and contains",
+			conditions: []Condition{
+				{Column: "dose", Operator: OpLte, Value: "-1"},
+				{Column: "note", Operator: OpContains, Value: "week"},
+			},
+			matchAny: true,
+			wantIDs:  []string{"4", "5"},
+		},
+		{name: "limit", conditions: []Condition{{Column: "ward", Operator: OpEq, Value: "A"}}, limit: 2, wantIDs: []string{"1", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, err := QueryRecords(context.Background(), path, tt.conditions, tt.matchAny, tt.limit)
+			if err != nil {
+				t.Fatalf("QueryRecords: %v", err)
+			}
+			if got := ids(records); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("ids = %q, want %q", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestQueryRecordsErrors(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,dose\n1,10\n")
+
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{name: "unknown operator", condition: Condition{Column: "dose", Operator: "like", Value: "1"}},
+		{name: "non-numeric value", condition: Condition{Column: "dose", Operator: OpGt, Value: "ten"}},
+		{name: "unknown column", condition: Condition{Column: "missing", Operator: OpEq, Value: "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := QueryRecords(context.Background(), path, []Condition{tt.condition}, false, 0); err == nil {
+				t.Errorf("QueryRecords(%+v) succeeded", tt.condition)
+			}
+		})
+	}
+}
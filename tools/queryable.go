@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Queryable reports whether the reader options allow filtering on column,
+// given by its header name or its alias. Every column is queryable when no
+// allowlist is configured.
+func Queryable(column string) bool {
+	if len(readerOptions.Queryable) == 0 {
+		return true
+	}
+	if readerOptions.Queryable[column] || readerOptions.Queryable[originalName(column)] {
+		return true
+	}
+	alias, ok := readerOptions.Aliases[column]
+	return ok && readerOptions.Queryable[alias]
+}
+
+// queryableIndex is like columnIndex, but also fails when column may not be
+// filtered on.
+func queryableIndex(header []string, column string) (int, error) {
+	index, err := columnIndex(header, column)
+	if err != nil {
+		return -1, err
+	}
+	if !Queryable(header[index]) {
+		var allowed []string
+		for _, name := range header {
+			if Queryable(name) {
+				allowed = append(allowed, name)
+			}
+		}
+		return -1, fmt.Errorf("column %q cannot be queried; queryable columns are: %s", column, strings.Join(allowed, ", "))
+	}
+	return index, nil
+}
+
+// queryableColumns returns the positions in header of the columns that may
+// be filtered on, or nil when every column may be.
+func queryableColumns(header []string) []int {
+	if len(readerOptions.Queryable) == 0 {
+		return nil
+	}
+	indexes := []int{}
+	for i, name := range header {
+		if Queryable(name) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
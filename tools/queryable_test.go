@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestQueryableColumns(t *testing.T) {
+	setReaderOptions(t, ReaderOptions{
+		Queryable: map[string]bool{"ward": true, "dose": true},
+		Aliases:   map[string]string{"dose_mg": "dose"},
+	})
+	path := writeFixture(t, "records.csv", "id,ward,dose_mg,notes\n1,A,5,private\n2,B,10,confidential\n3,A,,private\n")
+	ctx := context.Background()
+
+	calls := map[string]func(column string) error{
+		"FilterRecords": func(column string) error {
+			_, _, err := FilterRecords(ctx, path, column, "A", 0, false, false)
+			return err
+		},
+		"CountRecords": func(column string) error {
+			_, _, err := CountRecords(ctx, path, column, "A")
+			return err
+		},
+		"DistinctValues": func(column string) error {
+			_, err := DistinctValues(ctx, path, column, false)
+			return err
+		},
+		"GroupCount": func(column string) error {
+			_, err := GroupCount(ctx, path, column, "")
+			return err
+		},
+		"GroupCount metric": func(column string) error {
+			_, err := GroupCount(ctx, path, "ward", column)
+			return err
+		},
+		"ColumnStats": func(column string) error {
+			_, err := ColumnStats(ctx, path, column)
+			return err
+		},
+		"ColumnHistogram": func(column string) error {
+			_, err := ColumnHistogram(ctx, path, column, 0, 0)
+			return err
+		},
+		"SortRecords": func(column string) error {
+			records, header, err := GetFirstNRecords(ctx, path, 10)
+			if err != nil {
+				return err
+			}
+			return SortRecords(records, header, column, false)
+		},
+	}
+
+	tests := []struct {
+		column  string
+		allowed bool
+	}{
+		{column: "ward", allowed: true},
+		{column: "dose", allowed: true},
+		{column: "dose_mg", allowed: true},
+		{column: "notes", allowed: false},
+		{column: "id", allowed: false},
+	}
+	for name, call := range calls {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.column, func(t *testing.T) {
+				err := call(tt.column)
+				if tt.allowed {
+					if err != nil && strings.Contains(err.Error(), "cannot be queried") {
+						t.Errorf("queryable column %q was rejected: %v", tt.column, err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), "cannot be queried") {
+					t.Errorf("error for column %q = %v, want it rejected as not queryable", tt.column, err)
+				}
+			})
+		}
+	}
+}
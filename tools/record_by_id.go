@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// GetRecordByID returns the first data row of the CSV file at filePath whose
+// idColumn equals id, along with the header. It stops reading at the first
+// match. The record is nil when no row matches.
+func GetRecordByID(ctx context.Context, filePath, idColumn, id string) ([]string, []string, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, idColumn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, reader.Header, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if index < len(record) && record[index] == id {
+			return record, reader.Header, nil
+		}
+	}
+}
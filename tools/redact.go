@@ -0,0 +1,88 @@
+package tools
+
+// Redaction modes for ReaderOptions.Redact.
+const (
+	// RedactFull replaces the whole cell with RedactedPlaceholder.
+	RedactFull = "full"
+	// RedactLast4 keeps the last four characters, as is usual for
+	// identifiers such as social security numbers.
+	RedactLast4 = "last4"
+)
+
+// RedactedPlaceholder replaces the redacted part of a cell.
+const RedactedPlaceholder = "***"
+
+// ValidRedaction reports whether mode is a redaction mode.
+func ValidRedaction(mode string) bool {
+	return mode == RedactFull || mode == RedactLast4
+}
+
+// redactionsFor maps the positions of the redacted columns in header to their
+// redaction mode. It returns nil when no column of header is redacted.
+func redactionsFor(header []string) map[int]string {
+	if len(readerOptions.Redact) == 0 {
+		return nil
+	}
+	var masks map[int]string
+	for i, name := range header {
+		mode, ok := redactionFor(name)
+		if !ok {
+			continue
+		}
+		if masks == nil {
+			masks = make(map[int]string)
+		}
+		masks[i] = mode
+	}
+	return masks
+}
+
+// redactCell masks value according to mode. Empty cells stay empty.
+func redactCell(value, mode string) string {
+	if value == "" {
+		return ""
+	}
+	if mode == RedactLast4 {
+		runes := []rune(value)
+		if len(runes) > 4 {
+			return RedactedPlaceholder + string(runes[len(runes)-4:])
+		}
+	}
+	return RedactedPlaceholder
+}
+
+// redactRecord masks the cells of record at the positions in masks, in place.
+func redactRecord(record []string, masks map[int]string) {
+	for i, mode := range masks {
+		if i < len(record) {
+			record[i] = redactCell(record[i], mode)
+		}
+	}
+}
+
+// redactionFor returns the redaction mode of the column with header name or
+// alias name, if it is redacted.
+func redactionFor(name string) (string, bool) {
+	if mode, ok := readerOptions.Redact[name]; ok {
+		return mode, true
+	}
+	if alias, ok := readerOptions.Aliases[name]; ok {
+		if mode, ok := readerOptions.Redact[alias]; ok {
+			return mode, true
+		}
+	}
+	mode, ok := readerOptions.Redact[originalName(name)]
+	return mode, ok
+}
+
+// Redacted reports whether the reader options redact column, given by its
+// header name or its alias.
+func Redacted(column string) bool {
+	_, ok := redactionFor(column)
+	return ok
+}
+
+// RedactsAny reports whether the reader options redact any column.
+func RedactsAny() bool {
+	return len(readerOptions.Redact) > 0
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedactionAcrossReaders(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,name,ssn\n1,Ann,123-45-6789\n2,Bob,987-65-4321\n3,Cy,\n")
+
+	tests := []struct {
+		name    string
+		opts    ReaderOptions
+		column  string
+		wantSSN []string
+	}{
+		{
+			name:    "full",
+			opts:    ReaderOptions{Redact: map[string]string{"ssn": RedactFull}},
+			column:  "ssn",
+			wantSSN: []string{"***", "***", ""},
+		},
+		{
+			name:    "last4",
+			opts:    ReaderOptions{Redact: map[string]string{"ssn": RedactLast4}},
+			column:  "ssn",
+			wantSSN: []string{"***6789", "***4321", ""},
+		},
+		{
+			name:    "by alias",
+			opts:    ReaderOptions{Aliases: map[string]string{"ssn": "social"}, Redact: map[string]string{"social": RedactFull}},
+			column:  "social",
+			wantSSN: []string{"***", "***", ""},
+		},
+		{
+			name:    "by header name of an aliased column",
+			opts:    ReaderOptions{Aliases: map[string]string{"ssn": "social"}, Redact: map[string]string{"ssn": RedactLast4}},
+			column:  "social",
+			wantSSN: []string{"***6789", "***4321", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setReaderOptions(t, tt.opts)
+			ctx := context.Background()
+			ssns := func(records [][]string) []string {
+				out := make([]string, len(records))
+				for i, record := range records {
+					out[i] = record[2]
+				}
+				return out
+			}
+
+			for _, read := range []struct {
+				name string
+				tail TailFunc
+			}{
+				{name: "scan", tail: GetLastNRecords},
+				{name: "seek", tail: GetLastNRecordsSeek},
+			} {
+				last, _, err := TailWithHeader(ctx, read.tail, path, 3)
+				if err != nil {
+					t.Fatalf("%s: %v", read.name, err)
+				}
+				if got := ssns(last); !reflect.DeepEqual(got, tt.wantSSN) {
+					t.Errorf("%s tail = %q, want %q", read.name, got, tt.wantSSN)
+				}
+			}
+
+			matches, header, err := FilterRecords(ctx, path, "name", "Ann", 0, false, false)
+			if err != nil {
+				t.Fatalf("FilterRecords: %v", err)
+			}
+			if header[2] != tt.column {
+				t.Errorf("header = %q, want %q third", header, tt.column)
+			}
+			if got := ssns(matches); !reflect.DeepEqual(got, tt.wantSSN[:1]) {
+				t.Errorf("FilterRecords = %q, want %q", got, tt.wantSSN[:1])
+			}
+			for _, column := range []string{"ssn", tt.column} {
+				matches, _, err := FilterRecords(ctx, path, column, "123-45-6789", 0, false, false)
+				if err == nil && len(matches) != 0 {
+					t.Errorf("FilterRecords on %s matched the original value: %q", column, matches)
+				}
+			}
+
+			matches, _, _, err = SearchRecords(ctx, path, "123-45", 0, false)
+			if err != nil || len(matches) != 0 {
+				t.Errorf("SearchRecords found the original ssn: %q, %v", matches, err)
+			}
+			matches, _, _, err = SearchRecords(ctx, path, "Bob", 0, false)
+			if err != nil {
+				t.Fatalf("SearchRecords: %v", err)
+			}
+			if got := ssns(matches); !reflect.DeepEqual(got, tt.wantSSN[1:2]) {
+				t.Errorf("SearchRecords = %q, want %q", got, tt.wantSSN[1:2])
+			}
+
+			var b bytes.Buffer
+			if _, err := ExportJSONL(ctx, &b, path, ExportFilter{}, nil, 0); err != nil {
+				t.Fatalf("ExportJSONL: %v", err)
+			}
+			if strings.Contains(b.String(), "45-6789") || strings.Contains(b.String(), "65-4321") {
+				t.Errorf("ExportJSONL leaked an ssn: %s", b.String())
+			}
+			if want := `"` + tt.column + `":"` + tt.wantSSN[0] + `"`; !strings.Contains(b.String(), want) {
+				t.Errorf("ExportJSONL = %s, want it to hold %s", b.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// SampleRecords returns a uniform random sample of n data rows of the CSV file
+// at filePath, along with the header. The file is read in a single pass with
+// reservoir sampling, so only n rows are held in memory however large it is.
+// The randomness comes from rng, which makes the sample reproducible for a
+// given seed. The rows are returned in file order; when the file has n rows
+// or fewer, all of them are.
+func SampleRecords(ctx context.Context, filePath string, n int, rng *rand.Rand) ([][]string, []string, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	type sampled struct {
+		row    int
+		record []string
+	}
+	reservoir := make([]sampled, 0, n)
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(reservoir) < n {
+			reservoir = append(reservoir, sampled{row, record})
+		} else if i := rng.Int63n(int64(row) + 1); i < int64(n) {
+			reservoir[i] = sampled{row, record}
+		}
+	}
+
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].row < reservoir[j].row })
+	records := make([][]string, len(reservoir))
+	for i, s := range reservoir {
+		records[i] = s.record
+	}
+	return records, reader.Header, nil
+}
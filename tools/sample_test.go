@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestSampleRecords(t *testing.T) {
+	path := writeLargeFixture(t, 1000)
+
+	sample := func(n int, seed int64) [][]string {
+		t.Helper()
+		records, header, err := SampleRecords(context.Background(), path, n, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("SampleRecords: %v", err)
+		}
+		if header[0] != "id" {
+			t.Fatalf("header = %q, want the fixture header", header)
+		}
+		return records
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		want int
+	}{
+		{name: "smaller than the file", n: 10, want: 10},
+		{name: "the whole file", n: 1000, want: 1000},
+		{name: "larger than the file", n: 5000, want: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := sample(tt.n, 42)
+			if len(first) != tt.want {
+				t.Fatalf("sampled %d records, want %d", len(first), tt.want)
+			}
+			if again := sample(tt.n, 42); !reflect.DeepEqual(again, first) {
+				t.Errorf("the same seed gave different samples: %q and %q", ids(first), ids(again))
+			}
+
+			prev := -1
+			for _, record := range first {
+				id, err := strconv.Atoi(record[0])
+				if err != nil {
+					t.Fatalf("sampled record %q is not a data row", record)
+				}
+				if id <= prev {
+					t.Fatalf("sample is not in file order: %q", ids(first))
+				}
+				prev = id
+			}
+		})
+	}
+
+	if a, b := sample(10, 1), sample(10, 2); reflect.DeepEqual(a, b) {
+		t.Errorf("seeds 1 and 2 gave the same sample %q", ids(a))
+	}
+}
+
+// ids returns the first cell of each record.
+func ids(records [][]string) []string {
+	out := make([]string, len(records))
+	for i, record := range records {
+		out[i] = record[0]
+	}
+	return out
+}
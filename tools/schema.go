@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Column types reported by DescribeCSV.
+const (
+	TypeInteger = "integer"
+	TypeFloat   = "float"
+	TypeDate    = "date"
+	TypeString  = "string"
+)
+
+// SchemaSampleSize is the number of data rows DescribeCSV inspects when
+// inferring column types.
+const SchemaSampleSize = 100
+
+// dateLayouts are the formats a cell may be in to be considered a date.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Schema describes the columns and size of a CSV file.
+type Schema struct {
+	Columns     []ColumnSchema `json:"columns"`
+	RecordCount int            `json:"record_count"`
+}
+
+// ColumnSchema is the name and inferred type of a single column.
+type ColumnSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// DescribeCSV returns the header of the CSV file at filePath, the type of each
+// column inferred from the first SchemaSampleSize data rows, and the total
+// number of data rows. Inference is conservative: a column only gets a type
+// other than string if every non-empty sampled value parses as that type.
+func DescribeCSV(ctx context.Context, filePath string) (*Schema, error) {
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	candidates := make([]columnCandidates, len(reader.Header))
+	for i := range candidates {
+		candidates[i] = columnCandidates{integer: true, float: true, date: true}
+	}
+
+	count := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if count < SchemaSampleSize {
+			for i := range candidates {
+				if i < len(record) {
+					candidates[i].observe(record[i])
+				}
+			}
+		}
+		count++
+	}
+
+	schema := &Schema{Columns: make([]ColumnSchema, 0, len(reader.Header)), RecordCount: count}
+	for i, name := range reader.Header {
+		schema.Columns = append(schema.Columns, ColumnSchema{Name: name, Type: candidates[i].inferred()})
+	}
+	return schema, nil
+}
+
+// columnCandidates tracks which types every value seen so far in a column
+// could still be.
+type columnCandidates struct {
+	seen    bool
+	integer bool
+	float   bool
+	date    bool
+}
+
+func (c *columnCandidates) observe(value string) {
+	if value == "" {
+		return
+	}
+	c.seen = true
+	if c.integer {
+		_, err := strconv.ParseInt(value, 10, 64)
+		c.integer = err == nil
+	}
+	if c.float {
+		_, err := strconv.ParseFloat(value, 64)
+		c.float = err == nil
+	}
+	if c.date {
+		c.date = isDate(value)
+	}
+}
+
+func (c *columnCandidates) inferred() string {
+	switch {
+	case !c.seen:
+		return TypeString
+	case c.integer:
+		return TypeInteger
+	case c.float:
+		return TypeFloat
+	case c.date:
+		return TypeDate
+	}
+	return TypeString
+}
+
+// isDate reports whether value parses with any of the known date layouts.
+func isDate(value string) bool {
+	_, err := ParseDate(value)
+	return err == nil
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// SearchRecords returns up to limit data rows of the CSV file at filePath in
+// which any queryable cell contains query, compared case-insensitively, along
+// with the header and the total number of matching rows in the file. When
+// useRegex is set, query is instead compiled as a case-insensitive regular
+// expression. A limit of zero or less means DefaultFilterLimit.
+func SearchRecords(ctx context.Context, filePath, query string, limit int, useRegex bool) ([][]string, []string, int, error) {
+	if limit <= 0 {
+		limit = DefaultFilterLimit
+	}
+
+	match, err := cellMatcher(query, useRegex)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer reader.Close()
+
+	searchable := queryableColumns(reader.Header)
+	matches := [][]string{}
+	matched := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		if matchesAnyCell(record, searchable, match) {
+			if len(matches) < limit {
+				matches = append(matches, record)
+			}
+			matched++
+		}
+	}
+
+	return matches, reader.Header, matched, nil
+}
+
+// matchesAnyCell reports whether any cell of record at the positions in
+// indexes satisfies match. Nil indexes means every cell.
+func matchesAnyCell(record []string, indexes []int, match func(string) bool) bool {
+	if indexes == nil {
+		for _, cell := range record {
+			if match(cell) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, i := range indexes {
+		if i < len(record) && match(record[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// cellMatcher returns a function reporting whether a cell matches query.
+func cellMatcher(query string, useRegex bool) (func(string) bool, error) {
+	if useRegex {
+		re, err := regexp.Compile("(?i)" + query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		return re.MatchString, nil
+	}
+
+	query = strings.ToLower(query)
+	return func(cell string) bool {
+		return strings.Contains(strings.ToLower(cell), query)
+	}, nil
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortRecords sorts records in place by column, resolved by name against
+// header. The column's type is inferred from its values the same way
+// DescribeCSV does, so integer, float and date columns sort by value and
+// anything else sorts lexically. Empty cells always sort last. The sort is
+// stable, so records with equal values keep their file order in both
+// directions.
+func SortRecords(records [][]string, header []string, column string, desc bool) error {
+	index, err := queryableIndex(header, column)
+	if err != nil {
+		return err
+	}
+
+	cell := func(record []string) string {
+		if index < len(record) {
+			return record[index]
+		}
+		return ""
+	}
+
+	candidates := columnCandidates{integer: true, float: true, date: true}
+	for _, record := range records {
+		candidates.observe(cell(record))
+	}
+	compare := comparerFor(candidates.inferred())
+
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := cell(records[i]), cell(records[j])
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		if desc {
+			return compare(b, a) < 0
+		}
+		return compare(a, b) < 0
+	})
+	return nil
+}
+
+// comparerFor returns a function ordering two non-empty cells of a column of
+// the given type. Every value is known to parse as that type.
+func comparerFor(columnType string) func(a, b string) int {
+	switch columnType {
+	case TypeInteger, TypeFloat:
+		return func(a, b string) int {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case TypeDate:
+		return func(a, b string) int {
+			x, _ := ParseDate(a)
+			y, _ := ParseDate(b)
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare
+}
@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortRecords(t *testing.T) {
+	header := []string{"id", "value"}
+
+	tests := []struct {
+		name    string
+		values  []string
+		desc    bool
+		wantIDs []string
+	}{
+		{name: "integers by value", values: []string{"10", "9", "100", "-1"}, wantIDs: []string{"3", "1", "0", "2"}},
+		{name: "floats by value", values: []string{"2.5", "10", "-0.5", "1e1"}, wantIDs: []string{"2", "0", "1", "3"}},
+		{name: "text lexically", values: []string{"10", "9", "b", "100"}, wantIDs: []string{"0", "3", "1", "2"}},
+		{name: "dates by value", values: []string{"2024-03-01", "2023-12-31", "2024-01-15"}, wantIDs: []string{"1", "2", "0"}},
+		{name: "empty cells last", values: []string{"", "2", "", "1"}, wantIDs: []string{"3", "1", "0", "2"}},
+		{name: "empty cells last descending", values: []string{"", "2", "", "1"}, desc: true, wantIDs: []string{"1", "3", "0", "2"}},
+		{name: "ties keep file order", values: []string{"b", "a", "b", "a"}, wantIDs: []string{"1", "3", "0", "2"}},
+		{name: "ties keep file order descending", values: []string{"b", "a", "b", "a"}, desc: true, wantIDs: []string{"0", "2", "1", "3"}},
+		{name: "numeric ties keep file order", values: []string{"1", "1.0", "01", "0"}, wantIDs: []string{"3", "0", "1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([][]string, len(tt.values))
+			for i, value := range tt.values {
+				records[i] = []string{string(rune('0' + i)), value}
+			}
+			if err := SortRecords(records, header, "value", tt.desc); err != nil {
+				t.Fatalf("SortRecords: %v", err)
+			}
+			ids := make([]string, len(records))
+			for i, record := range records {
+				ids[i] = record[0]
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("order = %q, want %q", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestSortRecordsUnknownColumn(t *testing.T) {
+	if err := SortRecords([][]string{{"1"}}, []string{"id"}, "missing", false); err == nil {
+		t.Error("SortRecords succeeded on an unknown column")
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Stats summarises the numeric values of a single column.
+type Stats struct {
+	Column  string  `json:"column"`
+	Count   int     `json:"count"`
+	Skipped int     `json:"skipped"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Sum     float64 `json:"sum"`
+	Mean    float64 `json:"mean"`
+	Median  float64 `json:"median"`
+}
+
+// ColumnStats streams the CSV file at filePath once and summarises the values
+// of column. Cells that are empty or are not finite numbers are skipped and
+// counted in Stats.Skipped. Only the parsed values of the column are buffered,
+// which is what the median needs, but that is still one per row, so a file
+// over ReaderOptions.MaxFileBytes is refused. It is an error for the column to
+// hold no numeric values at all.
+func ColumnStats(ctx context.Context, filePath, column string) (*Stats, error) {
+	if err := CheckFileSize(filePath); err != nil {
+		return nil, err
+	}
+	reader, err := openRecords(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	index, err := queryableIndex(reader.Header, column)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Column: column}
+	var values []float64
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if index >= len(record) {
+			stats.Skipped++
+			continue
+		}
+
+		value, ok := parseNumber(record[index])
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+
+		if len(values) == 0 || value < stats.Min {
+			stats.Min = value
+		}
+		if len(values) == 0 || value > stats.Max {
+			stats.Max = value
+		}
+		stats.Sum += value
+		values = append(values, value)
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("column %q has no numeric values", column)
+	}
+
+	stats.Count = len(values)
+	stats.Mean = stats.Sum / float64(stats.Count)
+	stats.Median = median(values)
+	return stats, nil
+}
+
+// parseNumber parses cell as a finite number. NaN and the infinities, which
+// strconv.ParseFloat accepts, are rejected: one of them would turn every
+// aggregate into a value that cannot be encoded as JSON.
+func parseNumber(cell string) (float64, bool) {
+	value, err := strconv.ParseFloat(cell, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// median returns the median of values, sorting them in place.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
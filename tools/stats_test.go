@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestColumnStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Stats
+	}{
+		{
+			name:    "odd count",
+			content: "dose\n3\n1\n2\n",
+			want:    Stats{Column: "dose", Count: 3, Min: 1, Max: 3, Sum: 6, Mean: 2, Median: 2},
+		},
+		{
+			name:    "even count",
+			content: "dose\n4\n1\n3\n2\n",
+			want:    Stats{Column: "dose", Count: 4, Min: 1, Max: 4, Sum: 10, Mean: 2.5, Median: 2.5},
+		},
+		{
+			name:    "blank and non-numeric cells",
+			content: "dose,name\n10,a\n,b\nn/a,c\n-2.5,d\n",
+			want:    Stats{Column: "dose", Count: 2, Skipped: 2, Min: -2.5, Max: 10, Sum: 7.5, Mean: 3.75, Median: 3.75},
+		},
+		{
+			name:    "NaN and infinities",
+			content: "dose\n1\nNaN\nInf\n-Infinity\n+inf\n3\n",
+			want:    Stats{Column: "dose", Count: 2, Skipped: 4, Min: 1, Max: 3, Sum: 4, Mean: 2, Median: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			got, err := ColumnStats(context.Background(), path, "dose")
+			if err != nil {
+				t.Fatalf("ColumnStats: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ColumnStats = %+v, want %+v", *got, tt.want)
+			}
+			if _, err := json.Marshal(got); err != nil {
+				t.Errorf("stats cannot be encoded: %v", err)
+			}
+		})
+	}
+}
+
+func TestColumnStatsErrors(t *testing.T) {
+	path := writeFixture(t, "records.csv", "dose,name\n,a\nNaN,b\n")
+	if _, err := ColumnStats(context.Background(), path, "dose"); err == nil {
+		t.Error("ColumnStats succeeded on a column without numeric values")
+	}
+	if _, err := ColumnStats(context.Background(), path, "missing"); err == nil {
+		t.Error("ColumnStats succeeded on an unknown column")
+	}
+}
+
+func TestGroupCountSkipsNonFiniteMetrics(t *testing.T) {
+	path := writeFixture(t, "records.csv", "ward,cost\nA,10\nB,5\nA,NaN\nA,Inf\nA,2.5\nB,\n")
+
+	groups, err := GroupCount(context.Background(), path, "ward", "cost")
+	if err != nil {
+		t.Fatalf("GroupCount: %v", err)
+	}
+	sum := func(v float64) *float64 { return &v }
+	want := []Group{
+		{Value: "A", Count: 4, Sum: sum(12.5), Skipped: 2},
+		{Value: "B", Count: 2, Sum: sum(5), Skipped: 1},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		got, _ := json.Marshal(groups)
+		t.Errorf("GroupCount = %s", got)
+	}
+	if _, err := json.Marshal(groups); err != nil {
+		t.Errorf("groups cannot be encoded: %v", err)
+	}
+}
+
+func TestColumnStatsMaxFileBytes(t *testing.T) {
+	content := "dose\n1\n2\n"
+	path := writeFixture(t, "records.csv", content)
+
+	tests := []struct {
+		limit   int64
+		wantErr bool
+	}{
+		{limit: 0},
+		{limit: int64(len(content))},
+		{limit: int64(len(content) - 1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt.limit, 10), func(t *testing.T) {
+			setReaderOptions(t, ReaderOptions{MaxFileBytes: tt.limit})
+			_, err := ColumnStats(context.Background(), path, "dose")
+			if tt.wantErr != errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("ColumnStats with a limit of %d = %v, want ErrFileTooLarge: %t", tt.limit, err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("ColumnStats: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultValidationProblems is the number of problems ValidateCSV lists
+// before it only counts them.
+const DefaultValidationProblems = 20
+
+// Validation is the result of checking a CSV file for defects.
+type Validation struct {
+	// HeaderPresent reports whether the first row looks like a header: every
+	// name is non-empty and unique and none is a number or a date.
+	HeaderPresent bool     `json:"header_present"`
+	Header        []string `json:"header"`
+	// Rows is the number of data rows, malformed ones included.
+	Rows          int `json:"rows"`
+	MalformedRows int `json:"malformed_rows"`
+	// Problems lists the first problems found, in file order. Truncated is
+	// set when there were more than were listed.
+	Problems   []RowProblem  `json:"problems"`
+	Truncated  bool          `json:"truncated"`
+	EmptyCells []EmptyColumn `json:"empty_cells"`
+}
+
+// RowProblem is a defect found on a line of the file.
+type RowProblem struct {
+	Line    int    `json:"line"`
+	Problem string `json:"problem"`
+}
+
+// EmptyColumn is the number of data rows whose cell in Column is empty.
+type EmptyColumn struct {
+	Column string `json:"column"`
+	Empty  int    `json:"empty"`
+}
+
+// ValidateCSV reads the whole CSV file at filePath, bypassing the cache, and
+// reports its defects: rows whose field count differs from the header, rows
+// that cannot be parsed, header problems, and the number of empty cells in
+// each column. At most maxProblems problems are listed; zero or less means
+// DefaultValidationProblems. The file is never modified.
+func ValidateCSV(ctx context.Context, filePath string, maxProblems int) (*Validation, error) {
+	if maxProblems <= 0 {
+		maxProblems = DefaultValidationProblems
+	}
+
+	file, err := openFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := newCSVReader(file)
+	if parser, ok := reader.recordSource.(*csv.Reader); ok {
+		parser.FieldsPerRecord = -1
+	}
+
+	result := &Validation{Problems: []RowProblem{}, EmptyCells: []EmptyColumn{}}
+	report := func(line int, format string, args ...any) {
+		if len(result.Problems) < maxProblems {
+			result.Problems = append(result.Problems, RowProblem{Line: line, Problem: fmt.Sprintf(format, args...)})
+		} else {
+			result.Truncated = true
+		}
+	}
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		report(1, "file is empty")
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read csv header: %w", err)
+	}
+	result.Header = header
+	result.HeaderPresent = checkHeader(header, func(problem string) { report(1, "%s", problem) })
+	for _, name := range header {
+		result.EmptyCells = append(result.EmptyCells, EmptyColumn{Column: name})
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Rows++
+			result.MalformedRows++
+			report(parseErr.StartLine, "%v", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read csv file: %w", err)
+		}
+
+		result.Rows++
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			result.MalformedRows++
+			report(line, "has %d fields, header has %d", len(record), len(header))
+		}
+		for i := range result.EmptyCells {
+			if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+				result.EmptyCells[i].Empty++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// checkHeader reports the problems that make header unlikely to be a header
+// row, returning whether there were none.
+func checkHeader(header []string, report func(string)) bool {
+	ok := true
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			report(fmt.Sprintf("header column %d is empty", i+1))
+			ok = false
+		case seen[name]:
+			report(fmt.Sprintf("header column %q is duplicated", name))
+			ok = false
+		case isNumber(name) || isDate(name):
+			report(fmt.Sprintf("header column %q looks like data; the file may have no header row", name))
+			ok = false
+		}
+		seen[name] = true
+	}
+	return ok
+}
+
+func isNumber(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestValidateCSV(t *testing.T) {
+	path := writeFixture(t, "records.csv", "id,name,note\n1,a,x\n2,b\n3,\"bad\"quote,y\n4,,z\n5,e,f,g\n6,\"multi\nline\",\n7, ,w\n")
+
+	got, err := ValidateCSV(context.Background(), path, 0)
+	if err != nil {
+		t.Fatalf("ValidateCSV: %v", err)
+	}
+	want := &Validation{
+		HeaderPresent: true,
+		Header:        []string{"id", "name", "note"},
+		Rows:          7,
+		MalformedRows: 3,
+		Problems: []RowProblem{
+			{Line: 3, Problem: "has 2 fields, header has 3"},
+			{Line: 4, Problem: `extraneous or missing " in quoted-field`},
+			{Line: 6, Problem: "has 4 fields, header has 3"},
+		},
+		EmptyCells: []EmptyColumn{{Column: "id"}, {Column: "name", Empty: 2}, {Column: "note", Empty: 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateCSV = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateCSVProblems(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		maxProblems   int
+		wantHeader    bool
+		wantProblems  []RowProblem
+		wantTruncated bool
+	}{
+		{
+			name:         "empty file",
+			content:      "",
+			wantProblems: []RowProblem{{Line: 1, Problem: "file is empty"}},
+		},
+		{
+			name:    "header problems",
+			content: "id,,id,2024-01-01\n1,a,b,c\n",
+			wantProblems: []RowProblem{
+				{Line: 1, Problem: "header column 2 is empty"},
+				{Line: 1, Problem: `header column "id" is duplicated`},
+				{Line: 1, Problem: `header column "2024-01-01" looks like data; the file may have no header row`},
+			},
+		},
+		{
+			name:          "truncated",
+			content:       "id,name\n1\n2\n3\n4,d\n",
+			maxProblems:   2,
+			wantHeader:    true,
+			wantProblems:  []RowProblem{{Line: 2, Problem: "has 1 fields, header has 2"}, {Line: 3, Problem: "has 1 fields, header has 2"}},
+			wantTruncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFixture(t, "records.csv", tt.content)
+			got, err := ValidateCSV(context.Background(), path, tt.maxProblems)
+			if err != nil {
+				t.Fatalf("ValidateCSV: %v", err)
+			}
+			if got.HeaderPresent != tt.wantHeader {
+				t.Errorf("header present = %v, want %v", got.HeaderPresent, tt.wantHeader)
+			}
+			if !reflect.DeepEqual(got.Problems, tt.wantProblems) {
+				t.Errorf("problems = %+v, want %+v", got.Problems, tt.wantProblems)
+			}
+			if got.Truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", got.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}